@@ -1,7 +1,14 @@
 package cmd
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+
 	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
 	"github.com/cnosuke/mcp-command-exec/logger"
 	"github.com/cnosuke/mcp-command-exec/server"
 	"github.com/cockroachdb/errors"
@@ -19,7 +26,22 @@ func NewServerCommand() *cli.Command {
 				Name:    "config",
 				Aliases: []string{"c"},
 				Value:   DefaultConfigPath,
-				Usage:   "path to the configuration file",
+				Usage:   "path to the configuration file, or - to read it from stdin",
+			},
+			&cli.StringFlag{
+				Name:  "config-format",
+				Value: "yaml",
+				Usage: "format of a configuration read from stdin (yaml, json)",
+			},
+			&cli.StringFlag{
+				Name:    "env",
+				Aliases: []string{"e"},
+				EnvVars: []string{"APP_ENV"},
+				Usage:   "configuration profile; merges e.g. config.<env>.yml over the base configuration file",
+			},
+			&cli.BoolFlag{
+				Name:  "probe",
+				Usage: "check the configuration, print a readiness report and exit without serving",
 			},
 		},
 		Action: runServer,
@@ -28,22 +50,64 @@ func NewServerCommand() *cli.Command {
 
 // runServer starts the server
 func runServer(c *cli.Context) error {
-	configPath := c.String("config")
-
-	cfg, err := config.LoadConfig(configPath)
+	cfg, err := loadConfig(c)
 	if err != nil {
 		return errors.Wrap(err, "failed to load configuration file")
 	}
 
-	if err := logger.InitLogger(cfg.Debug, cfg.Log); err != nil {
+	if err := logger.InitLogger(cfg.Debug, cfg.Log, cfg.DebugLogSampleRate, cfg.LogFormat, cfg.LogLevel); err != nil {
 		return errors.Wrap(err, "failed to initialize logger")
 	}
 	defer logger.Sync()
 
+	if c.Bool("probe") {
+		return runProbe(cfg)
+	}
+
 	srv, err := server.NewServer(cfg, c.App.Name, c.App.Version)
 	if err != nil {
 		return errors.Wrap(err, "failed to create server")
 	}
+	defer srv.Shutdown()
+
+	// Flush logs and server state if the process is terminated by a signal
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, shutdownSignals...)
+	defer signal.Stop(signals)
+	go exitOnSignal(signals, srv, os.Exit)
 
 	return srv.Start()
 }
+
+// loadConfig loads the configuration from the --config file, or from stdin for "-"
+func loadConfig(c *cli.Context) (*config.Config, error) {
+	configPath := c.String("config")
+	if configPath != stdinConfigPath {
+		return config.LoadConfig(configPath, c.String("env"))
+	}
+
+	doc, err := readConfigDocument(os.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return config.LoadConfigFromReader(bytes.NewReader(doc), c.String("config-format"), c.String("env"))
+}
+
+// runProbe prints the startup readiness report and fails on critical problems
+func runProbe(cfg *config.Config) error {
+	report, err := executor.Probe(cfg)
+	if err != nil {
+		return errors.Wrap(err, "failed to run probe")
+	}
+
+	out, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal probe report")
+	}
+	fmt.Println(string(out))
+
+	if !report.OK {
+		return errors.New("probe found critical failures")
+	}
+	return nil
+}
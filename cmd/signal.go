@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"os"
+	"syscall"
+
+	"github.com/cnosuke/mcp-command-exec/logger"
+)
+
+// shutdownSignals are the signals that trigger a flush before exiting
+var shutdownSignals = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP, syscall.SIGQUIT}
+
+// shutdowner flushes server state before exit
+type shutdowner interface {
+	Shutdown() error
+}
+
+// exitOnSignal waits for a shutdown signal, flushes the server and logger, then exits
+func exitOnSignal(signals <-chan os.Signal, srv shutdowner, exit func(code int)) {
+	sig, ok := <-signals
+	if !ok {
+		return
+	}
+
+	// Errors are ignored since the process is exiting anyway
+	_ = srv.Shutdown()
+	_ = logger.Sync()
+
+	code := 1
+	if s, ok := sig.(syscall.Signal); ok {
+		code = 128 + int(s)
+	}
+	exit(code)
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// fakeShutdowner - Records whether Shutdown was called
+type fakeShutdowner struct {
+	called bool
+}
+
+func (f *fakeShutdowner) Shutdown() error {
+	f.called = true
+	return nil
+}
+
+// TestExitOnSignal - Test that a signal flushes the server before exiting
+func TestExitOnSignal(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	signals := make(chan os.Signal, 1)
+	signals <- syscall.SIGTERM
+
+	srv := &fakeShutdowner{}
+	exitCode := -1
+	exitOnSignal(signals, srv, func(code int) { exitCode = code })
+
+	assert.True(t, srv.called)
+	assert.Equal(t, 128+int(syscall.SIGTERM), exitCode)
+}
+
+// TestExitOnSignalClosed - Test that a closed channel does not exit
+func TestExitOnSignalClosed(t *testing.T) {
+	signals := make(chan os.Signal)
+	close(signals)
+
+	srv := &fakeShutdowner{}
+	exited := false
+	exitOnSignal(signals, srv, func(code int) { exited = true })
+
+	assert.False(t, srv.called)
+	assert.False(t, exited)
+}
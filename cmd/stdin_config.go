@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/cockroachdb/errors"
+)
+
+// stdinConfigPath is the --config value that reads the configuration from stdin
+const stdinConfigPath = "-"
+
+// configDocumentEnd is the YAML end-of-document marker that ends a configuration
+// read from stdin, leaving the rest of stdin to the MCP stdio transport
+var configDocumentEnd = []byte("...")
+
+// readConfigDocument reads the configuration from r up to a line containing only
+// the end-of-document marker, or to EOF. It reads one byte at a time so nothing
+// after the marker is consumed.
+func readConfigDocument(r io.Reader) ([]byte, error) {
+	var doc bytes.Buffer
+	lineStart := 0
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				line := bytes.TrimRight(doc.Bytes()[lineStart:], "\r")
+				if bytes.Equal(line, configDocumentEnd) {
+					doc.Truncate(lineStart)
+					return doc.Bytes(), nil
+				}
+				doc.WriteByte(b[0])
+				lineStart = doc.Len()
+				continue
+			}
+			doc.WriteByte(b[0])
+		}
+		if err == io.EOF {
+			return doc.Bytes(), nil
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read configuration from stdin")
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestReadConfigDocument - Test that the configuration ends at the marker without consuming what follows
+func TestReadConfigDocument(t *testing.T) {
+	r := strings.NewReader("command_exec:\n  allowed_commands: [ls]\n...\n{\"jsonrpc\":\"2.0\"}\n")
+
+	doc, err := readConfigDocument(r)
+	require.NoError(t, err)
+	assert.Equal(t, "command_exec:\n  allowed_commands: [ls]\n", string(doc))
+
+	// The MCP traffic after the marker is left unread
+	rest, err := io.ReadAll(r)
+	require.NoError(t, err)
+	assert.Equal(t, "{\"jsonrpc\":\"2.0\"}\n", string(rest))
+}
+
+// TestReadConfigDocumentEOF - Test that the configuration may also end at EOF
+func TestReadConfigDocumentEOF(t *testing.T) {
+	doc, err := readConfigDocument(bytes.NewBufferString("log: 'a.log'\nmarker: '...'"))
+	require.NoError(t, err)
+	assert.Equal(t, "log: 'a.log'\nmarker: '...'", string(doc))
+}
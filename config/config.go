@@ -1,13 +1,16 @@
 package config
 
 import (
+	"io"
 	"os"
 	"strings"
+	"testing/fstest"
 
+	"github.com/cockroachdb/errors"
 	"github.com/jinzhu/configor"
 )
 
-// Default allowed command list
+// Default allowed command list, used when default_deny is disabled and no list is configured
 var defaultAllowedCommands = []string{
 	"git",
 	"ls",
@@ -22,38 +25,224 @@ var defaultAllowedCommands = []string{
 	"pwd",
 }
 
+// DefaultBlockedEnvKeys - Environment variables that alter dynamic loader behavior
+var DefaultBlockedEnvKeys = []string{
+	"LD_PRELOAD",
+	"LD_LIBRARY_PATH",
+	"LD_AUDIT",
+	"DYLD_INSERT_LIBRARIES",
+	"DYLD_LIBRARY_PATH",
+	"DYLD_FRAMEWORK_PATH",
+	"DYLD_FALLBACK_LIBRARY_PATH",
+}
+
 // Config - Application configuration
 type Config struct {
-	Log         string `yaml:"log" env:"LOG_PATH"`
-	Debug       bool   `yaml:"debug" default:"false" env:"DEBUG"`
-	CommandExec struct {
-		AllowedCommands   []string          `yaml:"allowed_commands"`
-		DefaultWorkingDir string            `yaml:"default_working_dir" env:"DEFAULT_WORKING_DIR"`
-		AllowedDirs       []string          `yaml:"allowed_dirs"`
-		ShowWorkingDir    bool              `yaml:"show_working_dir" default:"true"`
-		SearchPaths       []string          `yaml:"search_paths"`
-		PathBehavior      string            `yaml:"path_behavior" default:"prepend"`
-		Environment       map[string]string `yaml:"environment"`
+	Log                string `yaml:"log" env:"LOG_PATH"`
+	Debug              bool   `yaml:"debug" default:"false" env:"DEBUG"`
+	DebugLogSampleRate int    `yaml:"debug_log_sample_rate" default:"1"`
+	LogFormat          string `yaml:"log_format" env:"LOG_FORMAT"`
+	LogLevel           string `yaml:"log_level" env:"LOG_LEVEL"`
+	CommandExec        struct {
+		AllowedCommands           []string            `yaml:"allowed_commands"`
+		CommandGroups             map[string][]string `yaml:"command_groups"`
+		CommandDescriptions       map[string]string   `yaml:"command_descriptions"`
+		Aliases                   map[string]string   `yaml:"aliases"`
+		MaxAliasDepth             int                 `yaml:"max_alias_depth" default:"10"`
+		DefaultWorkingDir         string              `yaml:"default_working_dir" env:"DEFAULT_WORKING_DIR"`
+		DefaultHomeDir            string              `yaml:"default_home_dir"`
+		MissingWorkingDir         string              `yaml:"missing_working_dir" default:"fallback"`
+		AllowedDirs               []string            `yaml:"allowed_dirs"`
+		ShowWorkingDir            bool                `yaml:"show_working_dir" default:"true"`
+		SearchPaths               []string            `yaml:"search_paths"`
+		BinaryChecksums           map[string]string   `yaml:"binary_checksums"`
+		VerifyEachRun             bool                `yaml:"verify_each_run" default:"false"`
+		PathBehavior              string              `yaml:"path_behavior" default:"prepend"`
+		StrictSearchPaths         bool                `yaml:"strict_search_paths" default:"false"`
+		TraversalMaxDepth         int                 `yaml:"traversal_max_depth" default:"0"`
+		TraversalCommands         []string            `yaml:"traversal_commands"`
+		CommandArgDefaults        map[string][]string `yaml:"command_arg_defaults"`
+		MaxMemoryBytes            int64               `yaml:"max_memory_bytes" default:"0"`
+		RequireAllowedBinaries    bool                `yaml:"require_allowed_binaries" default:"false"`
+		UseBuiltinLs              bool                `yaml:"use_builtin_ls" default:"false"`
+		UseBuiltinCat             bool                `yaml:"use_builtin_cat" default:"false"`
+		ResolveTimeoutMs          int                 `yaml:"resolve_timeout_ms" default:"0"`
+		LoginShell                string              `yaml:"login_shell"`
+		AllowShell                bool                `yaml:"allow_shell" default:"false"`
+		PersistentShell           bool                `yaml:"persistent_shell" default:"false"`
+		StrictConfig              bool                `yaml:"strict_config" default:"false"`
+		Environment               map[string]string   `yaml:"environment"`
+		BlockedEnvKeys            []string            `yaml:"blocked_env_keys"`
+		StripEnvKeys              []string            `yaml:"strip_env_keys"`
+		ScanOutputForSecrets      bool                `yaml:"scan_output_for_secrets" default:"false"`
+		RedactDetectedSecrets     bool                `yaml:"redact_detected_secrets" default:"false"`
+		AllowedEnvOverrides       []string            `yaml:"allowed_env_overrides"`
+		MaxEnvVars                int                 `yaml:"max_env_vars" default:"256"`
+		InlineOutputLimit         int                 `yaml:"inline_output_limit" default:"0"`
+		OutputRetentionSeconds    int                 `yaml:"output_retention_seconds" default:"600"`
+		MaxStoredOutputs          int                 `yaml:"max_stored_outputs" default:"100"`
+		HistorySize               int                 `yaml:"history_size" default:"20"`
+		HistoryExportPath         string              `yaml:"history_export_path"`
+		RestrictFileArgs          bool                `yaml:"restrict_file_args" default:"false"`
+		RejectShellMetachars      bool                `yaml:"reject_shell_metachars" default:"false"`
+		VerboseDenials            bool                `yaml:"verbose_denials" default:"false"`
+		SuggestOnDenial           bool                `yaml:"suggest_on_denial" default:"false"`
+		AllowRuntimePolicyChanges bool                `yaml:"allow_runtime_policy_changes" default:"false"`
+		RuntimePolicyScope        string              `yaml:"runtime_policy_scope" default:"server"`
+		MaxWorkingDirDepth        int                 `yaml:"max_working_dir_depth" default:"0"`
+		SessionTimeBudgetSeconds  int                 `yaml:"session_time_budget_seconds" default:"0"`
+		AllowSessionReset         bool                `yaml:"allow_session_reset" default:"false"`
+		MutatingCommands          []string            `yaml:"mutating_commands"`
+		MutationRateLimit         int                 `yaml:"mutation_rate_limit" default:"0"`
+		MutationRateWindowSeconds int                 `yaml:"mutation_rate_window_seconds" default:"60"`
+		ChangedFilesMaxScan       int                 `yaml:"changed_files_max_scan" default:"1000"`
+		DirDiffMaxFiles           int                 `yaml:"dir_diff_max_files" default:"1000"`
+		CheckWritable             bool                `yaml:"check_writable" default:"false"`
+		LogExecutions             bool                `yaml:"log_executions" default:"false"`
+		CdAllowedDirs             []string            `yaml:"cd_allowed_dirs"`
+		WorkdirAllowedDirs        []string            `yaml:"workdir_allowed_dirs"`
+		StickyWorkingDir          bool                `yaml:"sticky_working_dir" default:"false"`
+		NonzeroExitIsError        bool                `yaml:"nonzero_exit_is_error" default:"false"`
+		ScratchRoot               string              `yaml:"scratch_root"`
+		StdinMode                 string              `yaml:"stdin_mode" default:"null"`
+		InvalidUTF8               string              `yaml:"invalid_utf8" default:"reject"`
+		KillSignal                string              `yaml:"kill_signal" default:"SIGTERM"`
+		MaxOutputBytes            int                 `yaml:"max_output_bytes" default:"0"`
+		CommandMaxOutput          map[string]int      `yaml:"command_max_output"`
+		MaxOutputLines            int                 `yaml:"max_output_lines" default:"0"`
+		OutputLinesKeep           string              `yaml:"output_lines_keep" default:"head"`
+		CommandMergeOrder         map[string]string   `yaml:"command_merge_order"`
+		ChrootDir                 string              `yaml:"chroot_dir"`
+		Namespaces                []string            `yaml:"namespaces"`
+		TrimOutput                bool                `yaml:"trim_output" default:"false"`
+		SilentSuccessNote         bool                `yaml:"silent_success_note" default:"true"`
+		RetryOnOutputPattern      string              `yaml:"retry_on_output_pattern"`
+		RetryMaxAttempts          int                 `yaml:"retry_max_attempts" default:"3"`
+		RetryBackoffMs            int                 `yaml:"retry_backoff_ms" default:"200"`
+		RetryBaseDelay            string              `yaml:"retry_base_delay"`
+		RetryMaxDelay             string              `yaml:"retry_max_delay"`
+		RetryMultiplier           float64             `yaml:"retry_multiplier" default:"2"`
+		RetryJitter               float64             `yaml:"retry_jitter" default:"0"`
+		DefaultDeny               bool                `yaml:"default_deny" default:"true"`
+		SecretsDir                string              `yaml:"secrets_dir"`
+		CommandWorkingDirs        map[string]string   `yaml:"command_working_dirs"`
+		LogMaxArgs                int                 `yaml:"log_max_args" default:"20"`
+		StartupCommands           []StartupCommand    `yaml:"startup_commands"`
+		ProgressPatterns          map[string]string   `yaml:"progress_patterns"`
+		SuccessIfOutputMatches    map[string]string   `yaml:"success_if_output_matches"`
+		FatalStderrPatterns       map[string]string   `yaml:"fatal_stderr_patterns"`
+		MaxAsyncJobs              int                 `yaml:"max_async_jobs" default:"8"`
+		AsyncJobsFullMode         string              `yaml:"async_jobs_full_mode" default:"reject"`
+		MaxQueuedAsyncJobs        int                 `yaml:"max_queued_async_jobs" default:"32"`
+		AsyncJobRetentionSeconds  int                 `yaml:"async_job_retention_seconds" default:"600"`
+		FanoutMaxParallel         int                 `yaml:"fanout_max_parallel" default:"4"`
+		TimeoutSeconds            int                 `yaml:"timeout_seconds" default:"0"`
 	} `yaml:"command_exec"`
 }
 
+// StartupCommand - Operator-defined command run once before the server starts serving
+type StartupCommand struct {
+	Command        string `yaml:"command"`
+	WorkingDir     string `yaml:"working_dir"`
+	TimeoutSeconds int    `yaml:"timeout_seconds" default:"60"`
+	Required       bool   `yaml:"required" default:"false"`
+}
+
 // LoadConfig - Load configuration file
-func LoadConfig(path string) (*Config, error) {
+// If env is set, an overlay file next to it (e.g. config.prod.yml for config.yml) is merged over the base file
+func LoadConfig(path string, env string) (*Config, error) {
+	return load(&configor.Config{Environment: env}, path)
+}
+
+// LoadConfigFromReader loads the configuration from r, parsed as format
+// (yaml or json) since there is no file extension to detect it from
+func LoadConfigFromReader(r io.Reader, format string, env string) (*Config, error) {
+	// The fields only have yaml tags; JSON is valid YAML, so both use the YAML parser
+	switch format {
+	case "yaml", "yml", "json":
+	default:
+		return nil, errors.Newf("unsupported config format: %s", format)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read configuration")
+	}
+
+	// configor picks the parser from the file name, so serve the data under one
+	name := "config.yml"
+	return load(&configor.Config{
+		Environment: env,
+		FS:          fstest.MapFS{name: {Data: data}},
+	}, name)
+}
+
+// load applies defaults, the configuration file, and environment overrides
+func load(loaderConfig *configor.Config, path string) (*Config, error) {
 	cfg := &Config{}
-	cfg.CommandExec.AllowedCommands = defaultAllowedCommands
+	cfg.CommandExec.BlockedEnvKeys = DefaultBlockedEnvKeys
 
 	// Load from configuration file (overwrites defaults if exists)
-	err := configor.New(&configor.Config{
-		Debug:      false,
-		Verbose:    false,
-		Silent:     true,
-		AutoReload: false,
-	}).Load(cfg, path)
+	loaderConfig.Silent = true
+	err := configor.New(loaderConfig).Load(cfg, path)
 
 	// Override allowed command list from environment variables (if set)
 	if envAllowedCmd := os.Getenv("ALLOWED_COMMANDS"); envAllowedCmd != "" {
 		cfg.CommandExec.AllowedCommands = strings.Split(envAllowedCmd, ",")
 	}
 
+	// Expand +group references to their commands
+	allowedCommands, groupErr := expandCommandGroups(cfg.CommandExec.AllowedCommands, cfg.CommandExec.CommandGroups)
+	if groupErr != nil {
+		return nil, groupErr
+	}
+	cfg.CommandExec.AllowedCommands = allowedCommands
+
+	// Fall back to the default command list only when default_deny is disabled
+	if len(cfg.CommandExec.AllowedCommands) == 0 && !cfg.CommandExec.DefaultDeny {
+		cfg.CommandExec.AllowedCommands = defaultAllowedCommands
+	}
+
 	return cfg, err
 }
+
+// expandCommandGroups replaces each +name entry in commands with the commands
+// of that group, which may reference other groups in turn. Each command is kept
+// once, at its first position.
+func expandCommandGroups(commands []string, groups map[string][]string) ([]string, error) {
+	var expanded []string
+	seen := make(map[string]bool)
+
+	var expand func(entries []string, path []string) error
+	expand = func(entries []string, path []string) error {
+		for _, entry := range entries {
+			name, isGroup := strings.CutPrefix(entry, "+")
+			if !isGroup {
+				if !seen[entry] {
+					seen[entry] = true
+					expanded = append(expanded, entry)
+				}
+				continue
+			}
+
+			members, ok := groups[name]
+			if !ok {
+				return errors.Newf("allowed_commands references undefined command group: %s", name)
+			}
+			for _, parent := range path {
+				if parent == name {
+					return errors.Newf("command group %s includes itself", name)
+				}
+			}
+			if err := expand(members, append(path, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := expand(commands, nil); err != nil {
+		return nil, err
+	}
+	return expanded, nil
+}
@@ -0,0 +1,186 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfigFile - Write a config file into the directory
+func writeConfigFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// TestLoadConfigProfile - Test that a profile overlay is merged over the base config
+func TestLoadConfigProfile(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yml", `
+log: 'base.log'
+command_exec:
+  allowed_commands:
+    - git
+    - ls
+  default_working_dir: '/srv/base'
+  environment:
+    LANG: 'C'
+    GOPATH: '/go'
+`)
+	writeConfigFile(t, dir, "config.prod.yml", `
+command_exec:
+  allowed_commands:
+    - ls
+  environment:
+    LANG: 'en_US.UTF-8'
+`)
+
+	cfg, err := LoadConfig(path, "prod")
+	require.NoError(t, err)
+
+	// Overridden by the profile
+	assert.Equal(t, []string{"ls"}, cfg.CommandExec.AllowedCommands)
+	assert.Equal(t, "en_US.UTF-8", cfg.CommandExec.Environment["LANG"])
+
+	// Inherited from the base config
+	assert.Equal(t, "base.log", cfg.Log)
+	assert.Equal(t, "/srv/base", cfg.CommandExec.DefaultWorkingDir)
+	assert.Equal(t, "/go", cfg.CommandExec.Environment["GOPATH"])
+}
+
+// TestLoadConfigWithoutProfile - Test that other profiles are not applied
+func TestLoadConfigWithoutProfile(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yml", `
+command_exec:
+  allowed_commands:
+    - git
+`)
+	writeConfigFile(t, dir, "config.prod.yml", `
+command_exec:
+  allowed_commands:
+    - ls
+`)
+
+	cfg, err := LoadConfig(path, "staging")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git"}, cfg.CommandExec.AllowedCommands)
+}
+
+// TestLoadConfigDefaultDeny - Test that a missing allowlist stays empty under default_deny
+func TestLoadConfigDefaultDeny(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yml", `
+command_exec:
+  default_working_dir: '/tmp'
+`)
+
+	cfg, err := LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.True(t, cfg.CommandExec.DefaultDeny)
+	assert.Empty(t, cfg.CommandExec.AllowedCommands)
+}
+
+// TestLoadConfigDefaultDenyDisabled - Test that the default command list is used when default_deny is off
+func TestLoadConfigDefaultDenyDisabled(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yml", `
+command_exec:
+  default_deny: false
+`)
+
+	cfg, err := LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.False(t, cfg.CommandExec.DefaultDeny)
+	assert.Equal(t, defaultAllowedCommands, cfg.CommandExec.AllowedCommands)
+}
+
+// TestLoadConfigFromReader - Test loading a configuration that has no file to detect the format from
+func TestLoadConfigFromReader(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	cfg, err := LoadConfigFromReader(strings.NewReader(`
+log: 'stdin.log'
+command_exec:
+  allowed_commands:
+    - git
+`), "yaml", "")
+	require.NoError(t, err)
+	assert.Equal(t, "stdin.log", cfg.Log)
+	assert.Equal(t, []string{"git"}, cfg.CommandExec.AllowedCommands)
+
+	// Defaults still apply
+	assert.Equal(t, "prepend", cfg.CommandExec.PathBehavior)
+	assert.True(t, cfg.CommandExec.DefaultDeny)
+
+	cfg, err = LoadConfigFromReader(strings.NewReader(`{"command_exec": {"allowed_commands": ["ls"]}}`), "json", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ls"}, cfg.CommandExec.AllowedCommands)
+
+	_, err = LoadConfigFromReader(strings.NewReader(""), "ini", "")
+	assert.EqualError(t, err, "unsupported config format: ini")
+}
+
+// TestLoadConfigCommandGroups - Test that +group entries in allowed_commands expand to their commands
+func TestLoadConfigCommandGroups(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	dir := t.TempDir()
+	path := writeConfigFile(t, dir, "config.yml", `
+command_exec:
+  command_groups:
+    vcs: [git, hg]
+    build: [make, go, git]
+    dev: [+vcs, +build]
+  allowed_commands:
+    - ls
+    - +dev
+    - make
+`)
+
+	cfg, err := LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ls", "git", "hg", "make", "go"}, cfg.CommandExec.AllowedCommands)
+
+	// Groups are also expanded in ALLOWED_COMMANDS
+	t.Setenv("ALLOWED_COMMANDS", "+vcs,cat")
+	cfg, err = LoadConfig(path, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "hg", "cat"}, cfg.CommandExec.AllowedCommands)
+}
+
+// TestLoadConfigCommandGroupsInvalid - Test that undefined and self-including groups are rejected
+func TestLoadConfigCommandGroupsInvalid(t *testing.T) {
+	t.Setenv("ALLOWED_COMMANDS", "")
+
+	_, err := LoadConfigFromReader(strings.NewReader(`
+command_exec:
+  command_groups:
+    vcs: [git]
+  allowed_commands: [+vcs, +build]
+`), "yaml", "")
+	assert.EqualError(t, err, "allowed_commands references undefined command group: build")
+
+	_, err = LoadConfigFromReader(strings.NewReader(`
+command_exec:
+  command_groups:
+    a: [ls, +b]
+    b: [+a]
+  allowed_commands: [+a]
+`), "yaml", "")
+	assert.EqualError(t, err, "command group a includes itself")
+}
@@ -0,0 +1,45 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// defaultMaxAliasDepth is the default maximum number of chained alias expansions
+const defaultMaxAliasDepth = 10
+
+// ExpandAliases replaces the command's program with its alias from aliases,
+// repeating while the result starts with another alias. An alias whose
+// expansion starts with its own name (ls -> ls -la) stops there, as in shells.
+// Chains longer than maxDepth (default 10 when not positive) and cycles fail.
+func ExpandAliases(command string, aliases map[string]string, maxDepth int) (string, error) {
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxAliasDepth
+	}
+
+	parts := strings.Fields(command)
+	seen := make(map[string]bool)
+	for depth := 0; len(parts) > 0; depth++ {
+		name := parts[0]
+		expansion, ok := aliases[name]
+		if !ok {
+			break
+		}
+		if seen[name] {
+			return "", errors.Newf("alias cycle detected at %s", name)
+		}
+		if depth >= maxDepth {
+			return "", errors.Newf("alias expansion too deep (max %d)", maxDepth)
+		}
+		seen[name] = true
+
+		expanded := strings.Fields(expansion)
+		parts = append(expanded, parts[1:]...)
+		if len(expanded) > 0 && expanded[0] == name {
+			break
+		}
+	}
+
+	return strings.Join(parts, " "), nil
+}
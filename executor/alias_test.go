@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpandAliases - Test alias expansion, including chains within the depth
+func TestExpandAliases(t *testing.T) {
+	aliases := map[string]string{
+		"a":  "b --from-a",
+		"b":  "c --from-b",
+		"c":  "echo",
+		"ll": "ls -la",
+		"ls": "ls --color=never",
+	}
+
+	expanded, err := ExpandAliases("a x", aliases, 3)
+	require.NoError(t, err)
+	assert.Equal(t, "echo --from-b --from-a x", expanded)
+
+	// Self-referencing aliases expand once
+	expanded, err = ExpandAliases("ll dir", aliases, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "ls --color=never -la dir", expanded)
+
+	// Commands without an alias are unchanged
+	expanded, err = ExpandAliases("git status", aliases, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "git status", expanded)
+}
+
+// TestExpandAliasesLimits - Test that deep chains and cycles are rejected
+func TestExpandAliasesLimits(t *testing.T) {
+	chain := map[string]string{"a": "b", "b": "c", "c": "echo"}
+	_, err := ExpandAliases("a", chain, 2)
+	assert.EqualError(t, err, "alias expansion too deep (max 2)")
+
+	cycle := map[string]string{"a": "b x", "b": "a y"}
+	_, err = ExpandAliases("a", cycle, 0)
+	assert.EqualError(t, err, "alias cycle detected at a")
+}
@@ -0,0 +1,75 @@
+package executor
+
+import (
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// validateArgDefaults checks command_arg_defaults at startup. Each default
+// must be a single flag, with any value joined by =, so it can be compared
+// with the caller's flags.
+func (e *commandExecutor) validateArgDefaults() error {
+	for name, flags := range e.cfg.CommandExec.CommandArgDefaults {
+		for _, flag := range flags {
+			if !strings.HasPrefix(flag, "-") || flag == "-" || flag == "--" {
+				return errors.Newf("command_arg_defaults: %s default %q is not a flag (use --flag=value)", name, flag)
+			}
+		}
+	}
+	return nil
+}
+
+// applyArgDefaults inserts the command's command_arg_defaults right after the
+// program, skipping any the caller already set or negated
+func (e *commandExecutor) applyArgDefaults(parts []string) []string {
+	defaults := e.cfg.CommandExec.CommandArgDefaults[filepath.Base(parts[0])]
+	if len(defaults) == 0 {
+		return parts
+	}
+
+	// Only the options before -- are flags
+	args := parts[1:]
+	if i := slices.Index(args, "--"); i >= 0 {
+		args = args[:i]
+	}
+
+	var injected []string
+	for _, flag := range defaults {
+		if !slices.ContainsFunc(args, func(arg string) bool { return flagsConflict(flag, arg) }) {
+			injected = append(injected, flag)
+		}
+	}
+	if len(injected) == 0 {
+		return parts
+	}
+
+	e.logger.Debugw("added default arguments",
+		"command", parts[0],
+		"args", injected)
+	return slices.Concat(parts[:1], injected, parts[1:])
+}
+
+// flagsConflict reports whether arg sets the same option as flag, comparing
+// names without their =value and treating --no-x as the opposite of --x
+func flagsConflict(flag, arg string) bool {
+	if !strings.HasPrefix(arg, "-") {
+		return false
+	}
+
+	flagName, _, _ := strings.Cut(flag, "=")
+	argName, _, _ := strings.Cut(arg, "=")
+	if flagName == argName {
+		return true
+	}
+
+	positive := func(name string) string {
+		if rest, ok := strings.CutPrefix(name, "--no-"); ok {
+			return "--" + rest
+		}
+		return name
+	}
+	return strings.HasPrefix(flagName, "--") && positive(flagName) == positive(argName)
+}
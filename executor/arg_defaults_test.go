@@ -0,0 +1,58 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteArgDefaults - Test that default flags are injected unless the caller set them
+func TestExecuteArgDefaults(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.CommandArgDefaults = map[string][]string{"echo": {"--color=never"}}
+	})
+
+	// coreutils echo prints unknown options, showing what it received
+	result, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "--color=never hi\n", result.Stdout)
+	assert.Equal(t, "echo hi", result.Command)
+
+	result, err = cmdExecutor.Execute("echo --color=always hi", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "--color=always hi\n", result.Stdout)
+
+	// Other commands are unchanged
+	result, err = cmdExecutor.Execute("sh -c pwd", Options{})
+	require.NoError(t, err)
+	assert.NotContains(t, result.Stdout, "--color")
+}
+
+// TestApplyArgDefaults - Test which caller flags suppress a default
+func TestApplyArgDefaults(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.CommandArgDefaults = map[string][]string{"git": {"--no-pager", "--literal-pathspecs"}}
+	})
+
+	tests := []struct {
+		parts    []string
+		expected []string
+	}{
+		{[]string{"git", "log"}, []string{"git", "--no-pager", "--literal-pathspecs", "log"}},
+		{[]string{"/usr/bin/git", "log"}, []string{"/usr/bin/git", "--no-pager", "--literal-pathspecs", "log"}},
+		{[]string{"git", "--pager", "log"}, []string{"git", "--literal-pathspecs", "--pager", "log"}},
+		{[]string{"git", "--no-pager", "--literal-pathspecs=false", "log"}, []string{"git", "--no-pager", "--literal-pathspecs=false", "log"}},
+		{[]string{"git", "log", "--", "--pager"}, []string{"git", "--no-pager", "--literal-pathspecs", "log", "--", "--pager"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, cmdExecutor.applyArgDefaults(tt.parts), tt.parts)
+	}
+
+	// Defaults must be single flags
+	cfg := &config.Config{}
+	cfg.CommandExec.CommandArgDefaults = map[string][]string{"git": {"--format", "json"}}
+	_, err := newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, `git default "json" is not a flag`)
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// isBuiltinCommand reports whether the executor handles the program itself
+// instead of running a binary
+func (e *commandExecutor) isBuiltinCommand(name string) bool {
+	switch name {
+	case "cd", "pwd", "env":
+		return true
+	case "ls":
+		return e.cfg.CommandExec.UseBuiltinLs
+	case "cat":
+		return e.cfg.CommandExec.UseBuiltinCat
+	}
+	return false
+}
+
+// IsBuiltinCommand reports whether the command's program is handled by the
+// executor itself, as configured
+func (e *commandExecutor) IsBuiltinCommand(command string) bool {
+	parts := strings.Fields(command)
+	return len(parts) > 0 && e.isBuiltinCommand(parts[0])
+}
+
+// verifyAllowedBinaries resolves every allowed command at startup when
+// require_allowed_binaries is set, so a missing dependency fails the deploy
+// instead of the first call that needs it
+func (e *commandExecutor) verifyAllowedBinaries() error {
+	if !e.cfg.CommandExec.RequireAllowedBinaries {
+		return nil
+	}
+
+	var missing []string
+	for _, cmd := range e.GetAllowedCommands() {
+		if e.isBuiltinCommand(cmd) {
+			continue
+		}
+		if _, err := e.resolveBinaryPath(cmd); err != nil {
+			e.logger.Errorw("allowed command cannot be resolved",
+				"command", cmd,
+				"error", err)
+			missing = append(missing, cmd)
+		}
+	}
+
+	if len(missing) > 0 {
+		return errors.Newf("allowed commands not found: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
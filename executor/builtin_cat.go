@@ -0,0 +1,181 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+)
+
+// binarySniffSize is how much of a file the built-in cat inspects for binary content
+const binarySniffSize = 8000
+
+// errOutputCapped stops copying once the output limit is reached
+var errOutputCapped = errors.New("output limit reached")
+
+// cappedWriter writes to a limitedBuffer and fails once its limit is reached,
+// so a copy stops reading instead of draining the rest of a large file
+type cappedWriter struct {
+	buf *limitedBuffer
+}
+
+// Write implements io.Writer
+func (w cappedWriter) Write(p []byte) (int, error) {
+	n, err := w.buf.Write(p)
+	if err == nil && w.buf.truncated {
+		return n, errOutputCapped
+	}
+	return n, err
+}
+
+// handleCat handles cat when use_builtin_cat is set, reading one file itself so
+// its size is capped by max_output_bytes and binary content is not dumped as text.
+// --start=N and --end=N select a 1-based, inclusive line range; --base64 returns
+// binary files encoded instead of refusing them.
+func (e *commandExecutor) handleCat(command string, parts []string, workingDir string, env map[string]string) (types.CommandResult, error) {
+	result := types.CommandResult{
+		Command:    strings.Join(parts, " "),
+		WorkingDir: workingDir,
+		ExitCode:   0,
+	}
+	fail := func(err error) (types.CommandResult, error) {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
+	}
+
+	var start, end int
+	var encode bool
+	var targets []string
+	for _, arg := range parts[1:] {
+		name, value, hasValue := strings.Cut(arg, "=")
+		switch {
+		case name == "--start" && hasValue, name == "--end" && hasValue:
+			line, err := strconv.Atoi(value)
+			if err != nil || line < 1 {
+				return fail(errors.Newf("invalid line number for %s: %s", name, value))
+			}
+			if name == "--start" {
+				start = line
+			} else {
+				end = line
+			}
+		case arg == "--base64":
+			encode = true
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			return fail(errors.Newf("built-in cat does not support option %s", arg))
+		default:
+			targets = append(targets, arg)
+		}
+	}
+	if len(targets) != 1 || targets[0] == "-" {
+		return fail(errors.New("built-in cat reads exactly one file"))
+	}
+	if end > 0 && start > end {
+		return fail(errors.Newf("--start (%d) is after --end (%d)", start, end))
+	}
+	if encode && (start > 0 || end > 0) {
+		return fail(errors.New("--base64 cannot be combined with a line range"))
+	}
+
+	target := e.expandTilde(targets[0], env)
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(workingDir, target)
+	}
+	target = filepath.Clean(target)
+
+	// Check where the path really is, so a symlink cannot read outside allowed_dirs
+	resolved, err := e.fs.EvalSymlinks(target)
+	if err != nil {
+		return fail(errors.Newf("cannot access %s: no such file or directory", target))
+	}
+	if !e.IsDirectoryAllowed(resolved) {
+		e.logger.Warnw("built-in cat outside allowed directories",
+			"path", target)
+		return fail(errors.Newf("Access to directory not allowed: %s", target))
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return fail(errors.Wrapf(err, "cannot access %s", target))
+	}
+	if info.IsDir() {
+		return fail(errors.Newf("%s is a directory", target))
+	}
+
+	f, err := os.Open(resolved)
+	if err != nil {
+		return fail(errors.Wrapf(err, "cannot open %s", target))
+	}
+	defer f.Close()
+
+	r := bufio.NewReaderSize(f, binarySniffSize)
+	sniff, sniffErr := r.Peek(binarySniffSize)
+	if !encode && looksBinary(sniff, sniffErr != nil) {
+		return fail(errors.Newf("%s is a binary file; use cat --base64 to read it encoded", target))
+	}
+
+	out := &limitedBuffer{limit: e.maxOutputBytes(command)}
+	switch {
+	case encode:
+		enc := base64.NewEncoder(base64.StdEncoding, cappedWriter{buf: out})
+		_, err = io.Copy(enc, r)
+		if err == nil {
+			err = enc.Close()
+		}
+	case start > 0 || end > 0:
+		err = copyLines(cappedWriter{buf: out}, r, start, end)
+	default:
+		_, err = io.Copy(cappedWriter{buf: out}, r)
+	}
+	if err != nil && !errors.Is(err, errOutputCapped) {
+		return fail(errors.Wrapf(err, "failed to read %s", target))
+	}
+
+	result.Stdout = out.buf.String()
+	result.StdoutTruncated = out.truncated
+	return result, nil
+}
+
+// copyLines copies lines start through end (1-based, inclusive; 0 leaves that
+// side open) from r to w
+func copyLines(w io.Writer, r *bufio.Reader, start, end int) error {
+	for lineNo := 1; end == 0 || lineNo <= end; lineNo++ {
+		line, err := r.ReadBytes('\n')
+		if lineNo >= start && len(line) > 0 {
+			if _, writeErr := w.Write(line); writeErr != nil {
+				return writeErr
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// looksBinary reports whether the start of a file looks like binary content:
+// a NUL byte or invalid UTF-8. When the sniff is only part of the file it may
+// end inside a character, which is not counted against it.
+func looksBinary(sniff []byte, complete bool) bool {
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	if !complete {
+		for i := 0; i < utf8.UTFMax-1 && len(sniff) > 0 && !utf8.Valid(sniff); i++ {
+			sniff = sniff[:len(sniff)-1]
+		}
+	}
+	return !utf8.Valid(sniff)
+}
@@ -0,0 +1,94 @@
+package executor
+
+import (
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteBuiltinCat - Test reading text files, whole and by line range
+func TestExecuteBuiltinCat(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.UseBuiltinCat = true
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lines.txt"), []byte("one\ntwo\nthree\nfour"), 0644))
+
+	result, err := cmdExecutor.Execute("cat lines.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "one\ntwo\nthree\nfour", result.Stdout)
+	assert.False(t, result.StdoutTruncated)
+
+	result, err = cmdExecutor.Execute("cat --start=2 --end=3 lines.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "two\nthree\n", result.Stdout)
+
+	// Either end of the range may be open
+	result, err = cmdExecutor.Execute("cat --start=3 lines.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "three\nfour", result.Stdout)
+	result, err = cmdExecutor.Execute("cat --end=1 lines.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "one\n", result.Stdout)
+
+	_, err = cmdExecutor.Execute("cat --start=3 --end=2 lines.txt", Options{})
+	assert.ErrorContains(t, err, "is after --end")
+	_, err = cmdExecutor.Execute("cat -n lines.txt", Options{})
+	assert.ErrorContains(t, err, "does not support option -n")
+	_, err = cmdExecutor.Execute("cat lines.txt lines.txt", Options{})
+	assert.ErrorContains(t, err, "exactly one file")
+	_, err = cmdExecutor.Execute("cat .", Options{})
+	assert.ErrorContains(t, err, "is a directory")
+}
+
+// TestExecuteBuiltinCatBinary - Test that binary files are refused unless read as base64
+func TestExecuteBuiltinCatBinary(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.UseBuiltinCat = true
+	})
+	data := []byte{0x7f, 'E', 'L', 'F', 0x00, 0x01, 0xff}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "prog"), data, 0644))
+
+	_, err := cmdExecutor.Execute("cat prog", Options{})
+	assert.ErrorContains(t, err, "is a binary file")
+
+	result, err := cmdExecutor.Execute("cat --base64 prog", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(data), result.Stdout)
+
+	// Multi-byte text cut off at the end of the sniffed prefix is still text
+	text := strings.Repeat("a", binarySniffSize-1) + "é and more"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "text"), []byte(text), 0644))
+	result, err = cmdExecutor.Execute("cat text", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, text, result.Stdout)
+}
+
+// TestExecuteBuiltinCatLimits - Test the output cap and the allowed directories
+func TestExecuteBuiltinCatLimits(t *testing.T) {
+	outside := t.TempDir()
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.UseBuiltinCat = true
+		cfg.CommandExec.MaxOutputBytes = 10
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "big.txt"), []byte(strings.Repeat("x", 100)), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "secret.txt"), []byte("secret"), 0644))
+
+	result, err := cmdExecutor.Execute("cat big.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Repeat("x", 10), result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	_, err = cmdExecutor.Execute("cat "+filepath.Join(outside, "secret.txt"), Options{})
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+
+	// Symlinks are checked by their target
+	require.NoError(t, os.Symlink(filepath.Join(outside, "secret.txt"), filepath.Join(dir, "link")))
+	_, err = cmdExecutor.Execute("cat link", Options{})
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+}
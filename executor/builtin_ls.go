@@ -0,0 +1,117 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+)
+
+// builtinLsFlags are the ls options the built-in accepts. Entries are always
+// returned in full, so -l and -1 only exist to accept common invocations.
+const builtinLsFlags = "aAl1"
+
+// handleList handles ls when use_builtin_ls is set, reading the directory
+// itself and returning structured entries instead of text to parse
+func (e *commandExecutor) handleList(parts []string, workingDir string, env map[string]string) (types.CommandResult, error) {
+	result := types.CommandResult{
+		Command:    strings.Join(parts, " "),
+		WorkingDir: workingDir,
+		ExitCode:   0,
+	}
+	fail := func(err error) (types.CommandResult, error) {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
+	}
+
+	showHidden := false
+	var targets []string
+	for _, arg := range parts[1:] {
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			targets = append(targets, arg)
+			continue
+		}
+		for _, flag := range arg[1:] {
+			if !strings.ContainsRune(builtinLsFlags, flag) {
+				return fail(errors.Newf("built-in ls does not support option -%c", flag))
+			}
+			if flag == 'a' || flag == 'A' {
+				showHidden = true
+			}
+		}
+	}
+	if len(targets) > 1 {
+		return fail(errors.New("built-in ls lists one path at a time"))
+	}
+
+	target := workingDir
+	if len(targets) == 1 {
+		target = e.expandTilde(targets[0], env)
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(workingDir, target)
+		}
+	}
+	target = filepath.Clean(target)
+
+	// Check where the path really is, so a symlink cannot list outside allowed_dirs
+	resolved, err := e.fs.EvalSymlinks(target)
+	if err != nil {
+		return fail(errors.Newf("cannot access %s: no such file or directory", target))
+	}
+	if !e.IsDirectoryAllowed(resolved) {
+		e.logger.Warnw("built-in ls outside allowed directories",
+			"path", target)
+		return fail(errors.Newf("Access to directory not allowed: %s", target))
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		return fail(errors.Wrapf(err, "cannot access %s", target))
+	}
+
+	// Like ls, a file lists as itself
+	if !info.IsDir() {
+		result.Entries = []types.FileEntry{fileEntry(filepath.Base(target), info)}
+		result.Stdout = filepath.Base(target) + "\n"
+		return result, nil
+	}
+
+	dirEntries, err := os.ReadDir(resolved)
+	if err != nil {
+		return fail(errors.Wrapf(err, "cannot open directory %s", target))
+	}
+
+	var out strings.Builder
+	entries := make([]types.FileEntry, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !showHidden && strings.HasPrefix(dirEntry.Name(), ".") {
+			continue
+		}
+		// An entry removed while listing is skipped, as ls would
+		entryInfo, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		entries = append(entries, fileEntry(dirEntry.Name(), entryInfo))
+		out.WriteString(dirEntry.Name())
+		out.WriteString("\n")
+	}
+	result.Entries = entries
+	result.Stdout = out.String()
+
+	return result, nil
+}
+
+// fileEntry describes a file for the built-in ls
+func fileEntry(name string, info os.FileInfo) types.FileEntry {
+	return types.FileEntry{
+		Name:    name,
+		Size:    info.Size(),
+		Mode:    info.Mode().String(),
+		IsDir:   info.IsDir(),
+		ModTime: info.ModTime(),
+	}
+}
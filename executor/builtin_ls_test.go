@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteBuiltinLs - Test that the built-in ls returns structured entries for a known directory
+func TestExecuteBuiltinLs(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.UseBuiltinLs = true
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("hello"), 0644))
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".hidden"), nil, 0600))
+	mtime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	require.NoError(t, os.Chtimes(filepath.Join(dir, "a.txt"), mtime, mtime))
+
+	result, err := cmdExecutor.Execute("ls", Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 2)
+	assert.Equal(t, "a.txt", result.Entries[0].Name)
+	assert.Equal(t, int64(5), result.Entries[0].Size)
+	assert.Equal(t, "-rw-r--r--", result.Entries[0].Mode)
+	assert.False(t, result.Entries[0].IsDir)
+	assert.True(t, mtime.Equal(result.Entries[0].ModTime))
+	assert.Equal(t, "sub", result.Entries[1].Name)
+	assert.True(t, result.Entries[1].IsDir)
+	assert.Equal(t, "a.txt\nsub\n", result.Stdout)
+
+	// Hidden files are listed with -a
+	result, err = cmdExecutor.Execute("ls -la", Options{})
+	require.NoError(t, err)
+	assert.Len(t, result.Entries, 3)
+	assert.Equal(t, ".hidden", result.Entries[0].Name)
+
+	// A relative target and a file target
+	result, err = cmdExecutor.Execute("ls sub", Options{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Entries)
+	result, err = cmdExecutor.Execute("ls a.txt", Options{})
+	require.NoError(t, err)
+	require.Len(t, result.Entries, 1)
+	assert.Equal(t, "a.txt", result.Entries[0].Name)
+
+	// Unsupported options and missing paths fail
+	_, err = cmdExecutor.Execute("ls -R", Options{})
+	assert.ErrorContains(t, err, "does not support option -R")
+	_, err = cmdExecutor.Execute("ls missing", Options{})
+	assert.ErrorContains(t, err, "no such file or directory")
+}
+
+// TestExecuteBuiltinLsAllowedDirs - Test that the built-in ls only lists allowed directories
+func TestExecuteBuiltinLsAllowedDirs(t *testing.T) {
+	outside := t.TempDir()
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.UseBuiltinLs = true
+	})
+
+	_, err := cmdExecutor.Execute("ls "+outside, Options{})
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+
+	// Symlinks are checked by their target
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	_, err = cmdExecutor.Execute("ls link", Options{})
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+}
@@ -0,0 +1,89 @@
+package executor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// defaultChangedFilesMaxScan is the default maximum number of files scanned for changes
+const defaultChangedFilesMaxScan = 1000
+
+// fileState is the part of a file's metadata compared to detect changes
+type fileState struct {
+	size    int64
+	modTime time.Time
+	mode    fs.FileMode
+}
+
+// snapshotDir records the state of files under root, stopping after maxFiles files.
+// .git directories are skipped since they change on most git commands and can be large.
+func snapshotDir(root string, maxFiles int) map[string]fileState {
+	states := make(map[string]fileState)
+
+	_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable entries rather than failing the command
+			return nil
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" && path != root {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if len(states) >= maxFiles {
+			return filepath.SkipAll
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+
+		states[rel] = fileState{
+			size:    info.Size(),
+			modTime: info.ModTime(),
+			mode:    info.Mode(),
+		}
+		return nil
+	})
+
+	return states
+}
+
+// changedFiles returns the sorted paths that were added, removed, or modified between snapshots
+func changedFiles(before, after map[string]fileState) []string {
+	var changed []string
+
+	for path, state := range after {
+		if prev, ok := before[path]; !ok || prev != state {
+			changed = append(changed, path)
+		}
+	}
+	for path := range before {
+		if _, ok := after[path]; !ok {
+			changed = append(changed, path)
+		}
+	}
+
+	sort.Strings(changed)
+	return changed
+}
+
+// dirWritable checks that a file can be created in dir by creating and removing a temp file
+func dirWritable(dir string) error {
+	f, err := os.CreateTemp(dir, ".mcp-command-exec-probe-*")
+	if err != nil {
+		return err
+	}
+	f.Close()
+	return os.Remove(f.Name())
+}
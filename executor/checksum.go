@@ -0,0 +1,65 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// verifyChecksums checks every binary in binary_checksums at startup
+func (e *commandExecutor) verifyChecksums() error {
+	for path := range e.cfg.CommandExec.BinaryChecksums {
+		if !filepath.IsAbs(path) {
+			return errors.Newf("binary_checksums paths must be absolute: %s", path)
+		}
+		if err := e.verifyBinary(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// verifyBinary refuses a binary whose SHA-256 does not match its binary_checksums
+// entry. Binaries without an entry are not checked.
+func (e *commandExecutor) verifyBinary(path string) error {
+	expected, ok := e.cfg.CommandExec.BinaryChecksums[path]
+	if !ok {
+		return nil
+	}
+
+	actual, err := fileSHA256(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify checksum of %s", path)
+	}
+
+	if !strings.EqualFold(actual, expected) {
+		e.logger.Errorw("SECURITY ALERT: binary checksum mismatch, refusing to run it",
+			"binary_path", path,
+			"expected_sha256", expected,
+			"actual_sha256", actual)
+		return errors.Newf("checksum mismatch for %s", path)
+	}
+
+	return nil
+}
+
+// fileSHA256 returns the hex-encoded SHA-256 of the file's contents
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
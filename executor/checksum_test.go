@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// writeScript - Write an executable script and return its path and SHA-256
+func writeScript(t *testing.T, content string) (string, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "pinned")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0755))
+	sum, err := fileSHA256(path)
+	require.NoError(t, err)
+	return path, sum
+}
+
+// TestBinaryChecksumMatch - Test that a binary matching its checksum runs
+func TestBinaryChecksumMatch(t *testing.T) {
+	path, sum := writeScript(t, "#!/bin/sh\necho pinned\n")
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.BinaryChecksums = map[string]string{path: sum}
+		cfg.CommandExec.VerifyEachRun = true
+	})
+
+	result, err := cmdExecutor.Execute(path, Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "pinned\n", result.Stdout)
+}
+
+// TestBinaryChecksumTampered - Test that a modified binary is refused at startup and before runs
+func TestBinaryChecksumTampered(t *testing.T) {
+	path, sum := writeScript(t, "#!/bin/sh\necho pinned\n")
+
+	core, logs := observer.New(zap.ErrorLevel)
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.BinaryChecksums = map[string]string{path: sum}
+		cfg.CommandExec.VerifyEachRun = true
+	}, WithLogger(zap.New(core).Sugar()))
+
+	// Replaced after startup
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\necho tampered\n"), 0755))
+	result, err := cmdExecutor.Execute(path, Options{})
+	assert.EqualError(t, err, "checksum mismatch for "+path)
+	assert.Empty(t, result.Stdout)
+	assert.Equal(t, 1, logs.FilterMessage("SECURITY ALERT: binary checksum mismatch, refusing to run it").Len())
+
+	// Already modified at startup
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.BinaryChecksums = map[string]string{path: sum}
+	_, err = newCommandExecutor(cfg)
+	assert.EqualError(t, err, "checksum mismatch for "+path)
+
+	cfg.CommandExec.BinaryChecksums = map[string]string{"pinned": sum}
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "binary_checksums paths must be absolute")
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package executor
+
+// resetChildSignals is a no-op where signal dispositions are not inherited
+func resetChildSignals() {}
@@ -0,0 +1,43 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+var (
+	// sigpipeMu guards sigpipeHandled
+	sigpipeMu sync.Mutex
+
+	// sigpipeHandled is set once SIGPIPE is handled for the process, so it
+	// is registered at most once however many executors are created
+	sigpipeHandled bool
+)
+
+// resetChildSignals keeps SIGPIPE from being ignored in the commands this
+// process runs when the process itself ignores it. exec preserves ignored
+// signals but resets handled ones, so an ignored SIGPIPE is handled by
+// discarding it: the server still ignores it, while children get the default
+// disposition. Without this a program such as yes in a pipeline would see
+// EPIPE instead of dying of SIGPIPE. Other signals are left alone, since the
+// server handles its shutdown signals itself.
+func resetChildSignals() {
+	sigpipeMu.Lock()
+	defer sigpipeMu.Unlock()
+
+	if sigpipeHandled || !signal.Ignored(syscall.SIGPIPE) {
+		return
+	}
+	sigpipeHandled = true
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGPIPE)
+	go func() {
+		for range ch {
+		}
+	}()
+}
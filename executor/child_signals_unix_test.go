@@ -0,0 +1,58 @@
+//go:build unix
+
+package executor
+
+import (
+	"os/signal"
+	"runtime"
+	"syscall"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ignoreSigpipe - Ignore SIGPIPE for the test, undoing resetChildSignals afterwards
+func ignoreSigpipe(t *testing.T) {
+	signal.Ignore(syscall.SIGPIPE)
+	t.Cleanup(func() {
+		signal.Reset(syscall.SIGPIPE)
+		sigpipeMu.Lock()
+		sigpipeHandled = false
+		sigpipeMu.Unlock()
+	})
+}
+
+// TestExecuteDefaultSigpipe - Test that children die of SIGPIPE even when the server ignores it
+func TestExecuteDefaultSigpipe(t *testing.T) {
+	ignoreSigpipe(t)
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "yes", "head")
+	})
+
+	// yes is killed by SIGPIPE once head exits; with SIGPIPE ignored it would
+	// instead report the broken pipe on stderr
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "yes | head -n 1"}})
+	require.NoError(t, err)
+	assert.Equal(t, "y\n", result.Stdout)
+	assert.Empty(t, result.Stderr)
+	assert.False(t, signal.Ignored(syscall.SIGPIPE))
+}
+
+// TestResetChildSignalsOnce - Test that SIGPIPE is handled once however many executors are created
+func TestResetChildSignalsOnce(t *testing.T) {
+	ignoreSigpipe(t)
+
+	resetChildSignals()
+	require.True(t, sigpipeHandled)
+	goroutines := runtime.NumGoroutine()
+
+	for i := 0; i < 5; i++ {
+		newTestExecutor(t, nil)
+	}
+	assert.Equal(t, goroutines, runtime.NumGoroutine())
+	assert.False(t, signal.Ignored(syscall.SIGPIPE))
+}
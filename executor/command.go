@@ -1,368 +1,1636 @@
 package executor
 
 import (
-	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/fs"
+	"math/rand/v2"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/cnosuke/mcp-command-exec/config"
 	"github.com/cnosuke/mcp-command-exec/types"
 	"github.com/cockroachdb/errors"
+	"github.com/google/uuid"
 	"go.uber.org/zap"
 )
 
+// shellMetachars are the sequences rejected by reject_shell_metachars
+var shellMetachars = []string{"$(", "`", ";"}
+
+const (
+	// timeoutExitCode is reported for commands killed by their timeout, as with timeout(1)
+	timeoutExitCode = 124
+
+	// startFailedExitCode and startNotFoundExitCode are reported for commands
+	// whose process could not be started, as shells do
+	startFailedExitCode   = 126
+	startNotFoundExitCode = 127
+
+	// signaledExitCode is reported for commands terminated by a signal, which
+	// have no exit code of their own; the signal is reported separately
+	signaledExitCode = -1
+
+	// timeoutWaitDelay bounds the wait for output after a command is killed
+	timeoutWaitDelay = time.Second
+
+	// defaultMaxEnvVars is the per-call environment size allowed when max_env_vars is not set
+	defaultMaxEnvVars = 256
+
+	// silentSuccessNote is set on successful results without any output
+	silentSuccessNote = "command completed with no output"
+)
+
 // commandExecutor implements the CommandExecutor interface
 type commandExecutor struct {
 	allowedCommands   []string
+	allowedMu         sync.RWMutex
+	workingDirMu      sync.RWMutex
 	currentWorkingDir string
+	previousDir       string
+	defaultWorkingDir string
+	defaultHomeDir    string
 	allowedDirs       []string
 	showWorkingDir    bool
 	searchPaths       []string
 	pathBehavior      string
+	stdinMode         string
+	invalidUTF8       string
+	outputLinesKeep   string
+	retryPattern      *regexp.Regexp
+	retryBaseDelay    time.Duration
+	retryMaxDelay     time.Duration
+	retryMultiplier   float64
+	retryJitter       float64
+	progressPatterns  map[string]*regexp.Regexp
+	successPatterns   map[string]*regexp.Regexp
+	fatalPatterns     map[string]*regexp.Regexp
+	blockedEnvKeys    map[string]bool
+	envOverrides      map[string]bool
+	validator         CommandValidator
+	secretResolver    SecretResolver
+	resultHooks       []ResultHook
+	killSignal        syscall.Signal
+	processes         processRegistry
+	history           resultHistory
+	mutationLimiter   slidingWindowLimiter
+	persistentShell   bool
+	shells            persistentShells
+	logger            *zap.SugaredLogger
+	fs                FileSystem
+	clock             Clock
+	random            func() float64
 	cfg               *config.Config
 }
 
 // newCommandExecutor creates a new instance of commandExecutor
-func newCommandExecutor(cfg *config.Config) (*commandExecutor, error) {
-	zap.S().Infow("creating new Command Executor",
-		"allowed_commands", cfg.CommandExec.AllowedCommands)
+func newCommandExecutor(cfg *config.Config, opts ...Option) (*commandExecutor, error) {
+	// Allow embedding with options only
+	if cfg == nil {
+		cfg = &config.Config{}
+	}
+
+	e := &commandExecutor{
+		allowedCommands: cfg.CommandExec.AllowedCommands,
+		allowedDirs:     cfg.CommandExec.AllowedDirs,
+		showWorkingDir:  cfg.CommandExec.ShowWorkingDir,
+		searchPaths:     cfg.CommandExec.SearchPaths,
+		logger:          zap.S(),
+		fs:              osFileSystem{},
+		clock:           realClock{},
+		random:          rand.Float64,
+		cfg:             cfg,
+	}
+
+	// Apply functional options
+	for _, opt := range opts {
+		opt(e)
+	}
+
+	// Children start with default signal dispositions even if the server ignores some
+	resetChildSignals()
+
+	e.logger.Infow("creating new Command Executor",
+		"allowed_commands", e.allowedCommands)
+
+	if len(e.allowedCommands) == 0 {
+		e.logger.Warnw("no commands are allowed; configure allowed_commands")
+	}
+
+	workingDir := cfg.CommandExec.DefaultWorkingDir
+	if workingDir == "" {
+		// Use the HOME environment variable or a default value
+		if home := e.effectiveHome(nil); home != "" {
+			workingDir = home
+		} else {
+			workingDir = "/tmp"
+		}
+	}
+
+	// Check if the directory exists
+	if _, err := e.fs.Stat(workingDir); os.IsNotExist(err) {
+		if cfg.CommandExec.StrictConfig {
+			return nil, errors.Newf("default_working_dir does not exist: %s", workingDir)
+		}
+
+		// Fall back to default if it doesn't exist
+		workingDir = "/tmp"
+		e.logger.Warnw("Default working directory does not exist, falling back to /tmp",
+			"original_dir", cfg.CommandExec.DefaultWorkingDir)
+	}
+	e.currentWorkingDir = workingDir
+	e.defaultWorkingDir = workingDir
+
+	// Validate the cd target used when HOME is unset, which must be an allowed directory
+	if homeDir := cfg.CommandExec.DefaultHomeDir; homeDir != "" {
+		info, err := e.fs.Stat(homeDir)
+		if err != nil || !info.IsDir() || !filepath.IsAbs(homeDir) || !e.isDirectoryIn(filepath.Clean(homeDir), e.cdAllowedDirs()) {
+			if err := e.invalidSetting("default_home_dir", homeDir, workingDir); err != nil {
+				return nil, err
+			}
+		} else {
+			e.defaultHomeDir = filepath.Clean(homeDir)
+		}
+	}
+
+	// Build the environment variable blocklist
+	blockedEnvKeys := cfg.CommandExec.BlockedEnvKeys
+	if blockedEnvKeys == nil {
+		blockedEnvKeys = config.DefaultBlockedEnvKeys
+	}
+	e.blockedEnvKeys = make(map[string]bool, len(blockedEnvKeys))
+	for _, key := range blockedEnvKeys {
+		e.blockedEnvKeys[key] = true
+	}
+
+	// Build the allowlist of keys callers may set; nil leaves them unrestricted
+	if cfg.CommandExec.AllowedEnvOverrides != nil {
+		e.envOverrides = make(map[string]bool, len(cfg.CommandExec.AllowedEnvOverrides))
+		for _, key := range cfg.CommandExec.AllowedEnvOverrides {
+			e.envOverrides[key] = true
+		}
+	}
+
+	// Validate PathBehavior
+	pathBehavior := cfg.CommandExec.PathBehavior
+	if pathBehavior != "prepend" && pathBehavior != "replace" && pathBehavior != "append" {
+		if err := e.invalidSetting("path_behavior", pathBehavior, "prepend"); err != nil {
+			return nil, err
+		}
+		pathBehavior = "prepend"
+	}
+	e.pathBehavior = pathBehavior
+
+	// Validate StdinMode
+	stdinMode := cfg.CommandExec.StdinMode
+	switch stdinMode {
+	case "null", "provided":
+	case "inherit":
+		// The server's stdin is the MCP stdio transport; a command reading it
+		// would consume and corrupt protocol messages
+		return nil, errors.New(`stdin_mode "inherit" is not supported: the server's stdin is the MCP stdio transport`)
+	case "":
+		stdinMode = "null"
+	default:
+		if err := e.invalidSetting("stdin_mode", stdinMode, "null"); err != nil {
+			return nil, err
+		}
+		stdinMode = "null"
+	}
+	e.stdinMode = stdinMode
+
+	// Validate InvalidUTF8
+	invalidUTF8 := cfg.CommandExec.InvalidUTF8
+	switch invalidUTF8 {
+	case "reject", "sanitize":
+	case "":
+		invalidUTF8 = "reject"
+	default:
+		if err := e.invalidSetting("invalid_utf8", invalidUTF8, "reject"); err != nil {
+			return nil, err
+		}
+		invalidUTF8 = "reject"
+	}
+	e.invalidUTF8 = invalidUTF8
+
+	// Validate OutputLinesKeep
+	outputLinesKeep := cfg.CommandExec.OutputLinesKeep
+	switch outputLinesKeep {
+	case keepHead, keepTail:
+	case "":
+		outputLinesKeep = keepHead
+	default:
+		if err := e.invalidSetting("output_lines_keep", outputLinesKeep, keepHead); err != nil {
+			return nil, err
+		}
+		outputLinesKeep = keepHead
+	}
+	e.outputLinesKeep = outputLinesKeep
+
+	// Validate MissingWorkingDir
+	switch cfg.CommandExec.MissingWorkingDir {
+	case "", "fallback", "fail":
+	default:
+		if err := e.invalidSetting("missing_working_dir", cfg.CommandExec.MissingWorkingDir, "fallback"); err != nil {
+			return nil, err
+		}
+	}
+
+	// Validate the signal sent by KillProcess
+	killSignal, ok := killSignals[cfg.CommandExec.KillSignal]
+	if !ok {
+		if cfg.CommandExec.KillSignal != "" {
+			if err := e.invalidSetting("kill_signal", cfg.CommandExec.KillSignal, "SIGTERM"); err != nil {
+				return nil, err
+			}
+		}
+		killSignal = syscall.SIGTERM
+	}
+	e.killSignal = killSignal
+
+	// Keep recent results for LastResult
+	e.history.size = cfg.CommandExec.HistorySize
+
+	// Limit how fast mutating_commands can run
+	mutationWindow := time.Duration(cfg.CommandExec.MutationRateWindowSeconds) * time.Second
+	if mutationWindow <= 0 {
+		mutationWindow = defaultMutationRateWindow
+	}
+	e.mutationLimiter = slidingWindowLimiter{
+		limit:  cfg.CommandExec.MutationRateLimit,
+		window: mutationWindow,
+	}
+
+	// Resolve secret_refs from files under secrets_dir unless a resolver was provided
+	if e.secretResolver == nil && cfg.CommandExec.SecretsDir != "" {
+		e.secretResolver = NewFileSecretResolver(cfg.CommandExec.SecretsDir)
+	}
+
+	// Compile the pattern that marks transient failures worth retrying
+	if pattern := cfg.CommandExec.RetryOnOutputPattern; pattern != "" {
+		retryPattern, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid retry_on_output_pattern")
+		}
+		e.retryPattern = retryPattern
+	}
+
+	if err := e.validateRetryBackoff(); err != nil {
+		return nil, err
+	}
+
+	// Compile the per-command patterns that extract progress from output
+	progressPatterns, err := compileProgressPatterns(cfg.CommandExec.ProgressPatterns)
+	if err != nil {
+		return nil, err
+	}
+	e.progressPatterns = progressPatterns
+
+	// Compile the per-command patterns successful output must match
+	e.successPatterns = make(map[string]*regexp.Regexp, len(cfg.CommandExec.SuccessIfOutputMatches))
+	for name, pattern := range cfg.CommandExec.SuccessIfOutputMatches {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid success_if_output_matches entry for %s", name)
+		}
+		e.successPatterns[name] = re
+	}
+
+	// Compile the per-command patterns that make stderr fatal
+	e.fatalPatterns = make(map[string]*regexp.Regexp, len(cfg.CommandExec.FatalStderrPatterns))
+	for name, pattern := range cfg.CommandExec.FatalStderrPatterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid fatal_stderr_patterns entry for %s", name)
+		}
+		e.fatalPatterns[name] = re
+	}
+
+	// Fail clearly instead of running commands without the requested isolation
+	if err := e.validateSandbox(); err != nil {
+		return nil, err
+	}
+
+	if err := e.validateLoginShell(); err != nil {
+		return nil, err
+	}
+
+	if err := e.validatePersistentShell(); err != nil {
+		return nil, err
+	}
+
+	if err := e.validateMemoryLimit(); err != nil {
+		return nil, err
+	}
+
+	if err := e.validateTraversalLimits(); err != nil {
+		return nil, err
+	}
+
+	if err := e.validateArgDefaults(); err != nil {
+		return nil, err
+	}
+
+	// Refuse to start without the binaries of allowed commands, if required
+	if err := e.verifyAllowedBinaries(); err != nil {
+		return nil, err
+	}
+
+	// Refuse to start with pinned binaries that have been modified
+	if err := e.verifyChecksums(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// NormalizeCommand trims the command and collapses runs of whitespace, so the
+// string that is validated is exactly the one that is executed and reported
+func NormalizeCommand(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
+
+// invalidSetting handles a malformed setting: with strict_config it returns an
+// error that fails startup, otherwise it warns that the fallback is used
+func (e *commandExecutor) invalidSetting(name string, value string, fallback string) error {
+	if e.cfg.CommandExec.StrictConfig {
+		return errors.Newf("invalid %s setting: %q", name, value)
+	}
+
+	e.logger.Warnw(fmt.Sprintf("Invalid %s setting, using default '%s'", name, fallback),
+		"value", value)
+	return nil
+}
+
+// Execute executes the specified command
+func (e *commandExecutor) Execute(command string, options Options) (types.CommandResult, error) {
+	// Keep the command as received next to the effective form that runs
+	if options.RawCommand == "" {
+		options.RawCommand = command
+	}
+	command = NormalizeCommand(command)
+
+	// Identify this execution in its logs and result
+	if options.ExecutionID == "" {
+		options.ExecutionID = uuid.NewString()
+	}
+
+	// Catch invalid UTF-8 before it reaches results, where JSON would mangle it
+	if !utf8.ValidString(command) {
+		sanitized := strings.ToValidUTF8(command, "\uFFFD")
+		if e.invalidUTF8 != "sanitize" {
+			err := errors.New("command is not valid UTF-8")
+			return types.CommandResult{
+				Command:     sanitized,
+				WorkingDir:  e.GetCurrentWorkingDir(),
+				ExitCode:    1,
+				Error:       err.Error(),
+				ExecutionID: options.ExecutionID,
+				RawCommand:  options.RawCommand,
+			}, err
+		}
+		e.logger.Warnw("replacing invalid UTF-8 in command",
+			"command", sanitized)
+		command = sanitized
+	}
+
+	// Commands without a timeout of their own get the configured default
+	if options.Timeout == 0 && e.cfg.CommandExec.TimeoutSeconds > 0 {
+		options.Timeout = time.Duration(e.cfg.CommandExec.TimeoutSeconds) * time.Second
+	}
+
+	// Refuse oversized per-call environments before building anything from them
+	if maxEnvVars := e.maxEnvVars(); len(options.Env) > maxEnvVars {
+		err := errors.Newf("too many environment variables: %d (max_env_vars is %d)", len(options.Env), maxEnvVars)
+		return types.CommandResult{
+			Command:     command,
+			WorkingDir:  e.GetCurrentWorkingDir(),
+			ExitCode:    1,
+			Error:       err.Error(),
+			ExecutionID: options.ExecutionID,
+			RawCommand:  options.RawCommand,
+		}, err
+	}
+
+	// Drop per-call variables the caller may not override, before secrets are added
+	options.Env = e.filterEnvOverrides(options.Env)
+
+	// Inject resolved secrets into the child environment only
+	var secrets map[string]string
+	if len(options.SecretRefs) > 0 {
+		var err error
+		secrets, err = e.resolveSecrets(options.SecretRefs)
+		if err != nil {
+			return types.CommandResult{
+				Command:     command,
+				WorkingDir:  e.GetCurrentWorkingDir(),
+				ExitCode:    1,
+				Error:       err.Error(),
+				ExecutionID: options.ExecutionID,
+				RawCommand:  options.RawCommand,
+			}, err
+		}
+
+		env := make(map[string]string, len(options.Env)+len(secrets))
+		for k, v := range options.Env {
+			env[k] = v
+		}
+		for k, v := range secrets {
+			env[k] = v
+		}
+		options.Env = env
+
+		// Output leaving the executor while the command runs is redacted too
+		options.secrets = secrets
+		if options.Stream != nil {
+			options.Stream = newRedactingSink(options.Stream, secrets)
+		}
+	}
+
+	result, err := e.execute(command, options)
+	result.ExecutionID = options.ExecutionID
+	result.RawCommand = options.RawCommand
+	result.Label = options.Label
+	result.PolicyVersion = e.policyVersion(options.SessionPolicyVersion)
+
+	// Keep secret values out of the returned output
+	if secrets != nil {
+		result.Stdout = redactSecrets(result.Stdout, secrets)
+		result.Stderr = redactSecrets(result.Stderr, secrets)
+		result.Combined = redactSecrets(result.Combined, secrets)
+		result.Error = redactSecrets(result.Error, secrets)
+	}
+
+	// Catch credentials the command printed before they reach the client
+	if e.scanResultSecrets(&result.Stdout, &result.Stderr, &result.Combined) {
+		result.PossibleSecretsDetected = true
+		e.logger.Warnw("command output contains what looks like a secret",
+			"execution_id", options.ExecutionID,
+			"command", command,
+			"redacted", e.cfg.CommandExec.RedactDetectedSecrets)
+	}
+
+	// Trim trailing whitespace so real and built-in commands are consistent.
+	// A hex dump shows every byte, so it is left untrimmed.
+	if (options.TrimOutput || e.cfg.CommandExec.TrimOutput) && !options.HexDump {
+		result.Stdout = strings.TrimRightFunc(result.Stdout, unicode.IsSpace)
+		result.Stderr = strings.TrimRightFunc(result.Stderr, unicode.IsSpace)
+	}
+
+	// Keep stdout as the command wrote it for parse_json
+	stdout := result.Stdout
+
+	// Echo the command once, after any retries, for transcripts
+	if options.EchoCommand {
+		result.Stdout = "$ " + command + "\n" + result.Stdout
+	}
+
+	// A zero exit only counts as success if the output looks as expected, for
+	// commands whose exit code is not enough
+	result.Success = err == nil && result.ExitCode == 0
+	if result.Success && !e.outputMatchesSuccess(command, result) {
+		result.Success = false
+		result.Note = "output did not match success_if_output_matches"
+	}
+
+	// Some tools report fatal problems on stderr yet exit 0
+	if result.Success {
+		if match := e.fatalStderrMatch(command, result.Stderr); match != "" {
+			err = errors.Newf("stderr matched fatal_stderr_patterns: %q", match)
+			result.Success = false
+			result.Error = err.Error()
+		}
+	}
+
+	// Say so explicitly when a command succeeds silently, so callers don't wait for output
+	if result.Success && result.Stdout == "" && result.Stderr == "" && e.cfg.CommandExec.SilentSuccessNote {
+		result.Note = silentSuccessNote
+	}
+
+	// Show the output as bytes once it has been judged, so patterns matched the text
+	if options.HexDump {
+		limit := e.maxOutputBytes(command)
+		var truncated bool
+		result.Stdout, truncated = hexDump(stdout, limit)
+		result.StdoutTruncated = result.StdoutTruncated || truncated
+		result.Stderr, truncated = hexDump(result.Stderr, limit)
+		result.StderrTruncated = result.StderrTruncated || truncated
+		result.Combined, _ = hexDump(result.Combined, 2*limit)
+		if options.EchoCommand {
+			result.Stdout = "$ " + command + "\n" + result.Stdout
+		}
+	}
+
+	// Pass JSON output through as structure so clients need not decode it twice
+	if options.ParseJSON && result.Success && json.Valid([]byte(stdout)) {
+		result.ParsedJSON = json.RawMessage(stdout)
+	}
+
+	// Let deployments post-process the result last, so they see it as returned
+	for _, hook := range e.resultHooks {
+		hook(&result)
+	}
+
+	// Record the result as returned, so LastResult matches what the caller saw
+	e.history.add(result)
+
+	return result, err
+}
+
+// execute tokenizes the command once and dispatches it in the current or specified directory
+func (e *commandExecutor) execute(command string, options Options) (types.CommandResult, error) {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return types.CommandResult{
+			Command:    command,
+			WorkingDir: e.GetCurrentWorkingDir(),
+			ExitCode:   1,
+			Error:      "empty command",
+		}, errors.New("empty command")
+	}
+
+	// Use the literal arguments instead of the split command line if provided
+	if options.Args != nil {
+		parts = append(parts[:1], options.Args...)
+	}
+
+	// Run in a throwaway directory instead of a real one
+	if options.ScratchDir {
+		return e.executeInScratchDir(command, parts, options)
+	}
+
+	// Fall back to the command's configured default working directory
+	requested := options.WorkingDir != ""
+	if !requested {
+		options.WorkingDir = e.cfg.CommandExec.CommandWorkingDirs[parts[0]]
+	}
+
+	// If a working directory is specified, run there without changing the current one
+	if options.WorkingDir != "" {
+		if err := e.checkWorkingDir(options.WorkingDir); err != nil {
+			return types.CommandResult{
+				Command:    command,
+				WorkingDir: e.GetCurrentWorkingDir(),
+				ExitCode:   1,
+				Error:      err.Error(),
+			}, err
+		}
+
+		// With sticky_working_dir, a requested directory becomes the current one
+		if requested && e.cfg.CommandExec.StickyWorkingDir {
+			if previousDir, ok := e.stickWorkingDir(options.WorkingDir); ok {
+				result, err := e.dispatch(command, parts, filepath.Clean(options.WorkingDir), false, options)
+				if !result.WorkingDirChanged {
+					result.PreviousWorkingDir = previousDir
+					result.WorkingDirChanged = previousDir != filepath.Clean(options.WorkingDir)
+				}
+				return result, err
+			}
+		}
+
+		return e.dispatch(command, parts, options.WorkingDir, true, options)
+	}
+
+	// Recover if the current directory was removed since the last command. The
+	// command runs in this snapshot even if a concurrent cd changes it meanwhile.
+	workingDir, reset, err := e.recoverWorkingDir()
+	if err != nil {
+		return types.CommandResult{
+			Command:    command,
+			WorkingDir: workingDir,
+			ExitCode:   1,
+			Error:      err.Error(),
+		}, err
+	}
+
+	result, err := e.dispatch(command, parts, workingDir, false, options)
+	result.WorkingDirReset = reset
+	return result, err
+}
+
+// stickWorkingDir makes dir the current working directory for
+// sticky_working_dir, if cd could move there too. It returns the previous
+// current directory and whether dir was made current.
+func (e *commandExecutor) stickWorkingDir(dir string) (string, bool) {
+	dir = filepath.Clean(dir)
+	if !filepath.IsAbs(dir) || !e.isDirectoryIn(dir, e.cdAllowedDirs()) {
+		return "", false
+	}
+
+	e.workingDirMu.Lock()
+	defer e.workingDirMu.Unlock()
+
+	previousDir := e.currentWorkingDir
+	e.currentWorkingDir = dir
+	e.previousDir = previousDir
+	return previousDir, true
+}
+
+// recoverWorkingDir handles a current working directory that no longer exists, either
+// falling back to the default working directory or failing, per missing_working_dir.
+// It returns the current working directory and whether it was reset.
+func (e *commandExecutor) recoverWorkingDir() (string, bool, error) {
+	e.workingDirMu.Lock()
+	defer e.workingDirMu.Unlock()
+
+	if _, err := e.fs.Stat(e.currentWorkingDir); !os.IsNotExist(err) {
+		return e.currentWorkingDir, false, nil
+	}
+
+	if e.cfg.CommandExec.MissingWorkingDir == "fail" {
+		return e.currentWorkingDir, false, errors.Newf("current working directory no longer exists: %s", e.currentWorkingDir)
+	}
+
+	e.logger.Warnw("current working directory no longer exists, falling back to the default",
+		"working_dir", e.currentWorkingDir,
+		"default_working_dir", e.defaultWorkingDir)
+	e.currentWorkingDir = e.defaultWorkingDir
+	return e.currentWorkingDir, true, nil
+}
+
+// dispatch routes a tokenized command to a built-in handler or executes it in workingDir.
+// cd is rejected when workingDir is a temporary, per-call directory.
+func (e *commandExecutor) dispatch(command string, parts []string, workingDir string, temporary bool, options Options) (types.CommandResult, error) {
+	// The persistent shell runs everything, cd included, to keep its own state
+	if options.PersistentShell {
+		if !e.persistentShell {
+			return types.CommandResult{
+				Command:    command,
+				WorkingDir: workingDir,
+				ExitCode:   1,
+				Error:      "persistent shell is not enabled",
+			}, errors.New("persistent shell is not enabled")
+		}
+		if temporary {
+			return types.CommandResult{
+				Command:    command,
+				WorkingDir: workingDir,
+				ExitCode:   1,
+				Error:      "a temporary working directory cannot be used with the persistent shell",
+			}, errors.New("a temporary working directory cannot be used with the persistent shell")
+		}
+		return e.executeInShell(command, parts, workingDir, options)
+	}
+
+	switch parts[0] {
+	case "cd":
+		if temporary {
+			return types.CommandResult{
+				Command:    command,
+				WorkingDir: workingDir,
+				ExitCode:   1,
+				Error:      "cd command is not supported when using a temporary working directory",
+			}, errors.New("cd command is not supported with a temporary working directory")
+		}
+		return e.handleChangeDirectory(parts, options.Env)
+	case "pwd":
+		return e.handlePrintWorkingDirectory(workingDir)
+	case "env":
+		return e.handleEnvironment(parts, workingDir, options.Env)
+	case "ls":
+		if e.cfg.CommandExec.UseBuiltinLs {
+			return e.handleList(parts, workingDir, options.Env)
+		}
+	case "cat":
+		if e.cfg.CommandExec.UseBuiltinCat {
+			return e.handleCat(command, parts, workingDir, options.Env)
+		}
+	}
+
+	return e.executeWithRetry(command, parts, workingDir, options)
+}
+
+// IsCommandAllowed checks if the command is in the allowed list
+func (e *commandExecutor) IsCommandAllowed(command string) bool {
+	// Don't allow empty commands
+	if command == "" {
+		return false
+	}
+
+	// Get the first part of the command (program name)
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return false
+	}
+	programName := parts[0]
+
+	// Check if the program name is in the allowed list
+	e.allowedMu.RLock()
+	defer e.allowedMu.RUnlock()
+	return slices.Contains(e.allowedCommands, programName)
+}
+
+// Validate runs the custom validator, if one is configured
+func (e *commandExecutor) Validate(ctx context.Context, command string, options Options) error {
+	if e.validator == nil {
+		return nil
+	}
+
+	if err := e.validator.Validate(ctx, command, options); err != nil {
+		e.logger.Warnw("command rejected by validator",
+			"command", command,
+			"error", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAllowedCommands returns the list of allowed commands
+func (e *commandExecutor) GetAllowedCommands() []string {
+	e.allowedMu.RLock()
+	defer e.allowedMu.RUnlock()
+	return slices.Clone(e.allowedCommands)
+}
+
+// AllowCommand adds a program to the allowed list; it reports false if it was already allowed
+func (e *commandExecutor) AllowCommand(name string) bool {
+	e.allowedMu.Lock()
+	defer e.allowedMu.Unlock()
+
+	if slices.Contains(e.allowedCommands, name) {
+		return false
+	}
+	e.allowedCommands = append(slices.Clone(e.allowedCommands), name)
+	return true
+}
+
+// DisallowCommand removes a program from the allowed list; it reports false if it was not allowed
+func (e *commandExecutor) DisallowCommand(name string) bool {
+	e.allowedMu.Lock()
+	defer e.allowedMu.Unlock()
+
+	if !slices.Contains(e.allowedCommands, name) {
+		return false
+	}
+	e.allowedCommands = slices.DeleteFunc(slices.Clone(e.allowedCommands), func(c string) bool {
+		return c == name
+	})
+	return true
+}
+
+// GetCurrentWorkingDir returns the current working directory
+func (e *commandExecutor) GetCurrentWorkingDir() string {
+	e.workingDirMu.RLock()
+	defer e.workingDirMu.RUnlock()
+	return e.currentWorkingDir
+}
+
+// IsDirectoryAllowed checks if directory access is allowed
+func (e *commandExecutor) IsDirectoryAllowed(dir string) bool {
+	return e.isDirectoryIn(dir, e.allowedDirs)
+}
+
+// cdAllowedDirs returns the directories cd may change into
+func (e *commandExecutor) cdAllowedDirs() []string {
+	if len(e.cfg.CommandExec.CdAllowedDirs) > 0 {
+		return e.cfg.CommandExec.CdAllowedDirs
+	}
+	return e.allowedDirs
+}
+
+// workdirAllowedDirs returns the directories a temporary working_dir may point to
+func (e *commandExecutor) workdirAllowedDirs() []string {
+	if len(e.cfg.CommandExec.WorkdirAllowedDirs) > 0 {
+		return e.cfg.CommandExec.WorkdirAllowedDirs
+	}
+	return e.allowedDirs
+}
+
+// isDirectoryIn checks if the directory is within one of the allowed directories
+func (e *commandExecutor) isDirectoryIn(dir string, allowedDirs []string) bool {
+	// Directory access restriction implementation
+	// An empty allowed list denies everything under default_deny, and allows all otherwise
+	if len(allowedDirs) == 0 {
+		return !e.cfg.CommandExec.DefaultDeny
+	}
+
+	// Check if it matches the allowed list
+	for _, allowedDir := range allowedDirs {
+		if dirWithin(dir, allowedDir) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// cleanDir normalizes a directory for comparison: absolute, without trailing
+// slashes or . and .. elements
+func cleanDir(dir string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		return abs
+	}
+	return filepath.Clean(dir)
+}
+
+// dirWithin reports whether dir is root or a directory below it. Matching is
+// by path element, so /home/user2 is not within /home/user.
+func dirWithin(dir string, root string) bool {
+	rel, err := filepath.Rel(cleanDir(root), cleanDir(dir))
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// effectiveHome returns the HOME that commands run with.
+// Per-command env takes precedence over the config environment, which takes precedence over the server's HOME.
+func (e *commandExecutor) effectiveHome(env map[string]string) string {
+	if home := env["HOME"]; home != "" {
+		return home
+	}
+	if home := e.cfg.CommandExec.Environment["HOME"]; home != "" {
+		return home
+	}
+	return os.Getenv("HOME")
+}
+
+// expandTilde expands a leading ~ in the path to the effective HOME
+func (e *commandExecutor) expandTilde(path string, env map[string]string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+
+	home := e.effectiveHome(env)
+	if home == "" {
+		return path
+	}
+
+	return filepath.Join(home, path[1:])
+}
+
+// checkWorkingDirDepth checks the directory depth below the nearest of the allowed directories
+func (e *commandExecutor) checkWorkingDirDepth(dir string, allowedDirs []string) error {
+	maxDepth := e.cfg.CommandExec.MaxWorkingDirDepth
+	if maxDepth <= 0 {
+		return nil
+	}
+
+	// Find the nearest (longest) allowed directory containing dir
+	root := ""
+	for _, allowedDir := range allowedDirs {
+		if allowedDir = cleanDir(allowedDir); dirWithin(dir, allowedDir) && len(allowedDir) > len(root) {
+			root = allowedDir
+		}
+	}
+	if root == "" {
+		return nil
+	}
+
+	rel, err := filepath.Rel(root, cleanDir(dir))
+	if err != nil || rel == "." {
+		return nil
+	}
+
+	depth := len(strings.Split(rel, string(filepath.Separator)))
+	if depth > maxDepth {
+		return fmt.Errorf("directory %s is %d levels below %s, exceeding the maximum depth of %d",
+			dir, depth, root, maxDepth)
+	}
+
+	return nil
+}
+
+// handleChangeDirectory handles the cd command
+func (e *commandExecutor) handleChangeDirectory(parts []string, env map[string]string) (types.CommandResult, error) {
+	// Hold the lock throughout so concurrent cds apply one after the other
+	e.workingDirMu.Lock()
+	defer e.workingDirMu.Unlock()
+
+	previousDir := e.currentWorkingDir
+	result := types.CommandResult{
+		Command:    strings.Join(parts, " "),
+		WorkingDir: e.currentWorkingDir,
+		ExitCode:   0,
+	}
+
+	// Resolve the target. A bare cd goes home, and HOME can be overridden per
+	// call, so the home directory gets the same checks as an explicit argument
+	newDir, err := e.changeDirectoryTarget(parts, env)
+	if err != nil {
+		result.Error = err.Error()
+		result.ExitCode = 1
+		return result, err
+	}
+
+	// Normalize path (resolve symlinks, etc.) and check that the directory exists
+	var stat os.FileInfo
+	var statErr error
+	lookupErr := e.withResolveTimeout("cd "+newDir, func() error {
+		if evalDir, evalErr := e.fs.EvalSymlinks(newDir); evalErr == nil {
+			newDir = evalDir
+		}
+		stat, statErr = e.fs.Stat(newDir)
+		return nil
+	})
+	if lookupErr != nil {
+		result.Error = lookupErr.Error()
+		result.ExitCode = 1
+		return result, lookupErr
+	}
+
+	if statErr != nil || !stat.IsDir() {
+		errMsg := fmt.Sprintf("Directory does not exist: %s", newDir)
+		result.Error = errMsg
+		result.ExitCode = 1
+		return result, errors.New(errMsg)
+	}
+
+	// Check access permissions
+	if !e.isDirectoryIn(newDir, e.cdAllowedDirs()) {
+		errMsg := fmt.Sprintf("Access to directory not allowed: %s", newDir)
+		result.Error = errMsg
+		result.ExitCode = 1
+		return result, errors.New(errMsg)
+	}
+
+	// Check directory depth
+	if err := e.checkWorkingDirDepth(newDir, e.cdAllowedDirs()); err != nil {
+		result.Error = err.Error()
+		result.ExitCode = 1
+		return result, err
+	}
+
+	// Update working directory
+	e.currentWorkingDir = newDir
+	result.Stdout = fmt.Sprintf("Changed directory to %s", newDir)
+	result.WorkingDir = newDir
+
+	// Let clients notice navigation explicitly, and remember where cd - returns to
+	result.PreviousWorkingDir = previousDir
+	result.WorkingDirChanged = e.currentWorkingDir != previousDir
+	e.previousDir = previousDir
+
+	return result, nil
+}
+
+// ChangeDirectoryTarget returns the directory the cd command would move to,
+// without moving, with env filtered as Execute filters it
+func (e *commandExecutor) ChangeDirectoryTarget(command string, env map[string]string) (string, error) {
+	env = e.filterEnvOverrides(env)
+
+	e.workingDirMu.RLock()
+	defer e.workingDirMu.RUnlock()
+	return e.changeDirectoryTarget(strings.Fields(command), env)
+}
+
+// changeDirectoryTarget returns the directory cd with parts moves to, before
+// symlinks are resolved: home without an argument, the previous directory for
+// -, and otherwise the argument with ~ expanded. Minimal containers often have
+// no HOME, so home falls back to default_home_dir and then default_working_dir.
+// The caller holds workingDirMu.
+func (e *commandExecutor) changeDirectoryTarget(parts []string, env map[string]string) (string, error) {
+	if len(parts) < 2 {
+		home := e.effectiveHome(env)
+		if home == "" {
+			home = e.defaultHomeDir
+		}
+		if home == "" {
+			home = e.defaultWorkingDir
+		}
+		return home, nil
+	}
+
+	if parts[1] == "-" {
+		if e.previousDir == "" {
+			return "", errors.New("no previous directory to change to")
+		}
+		return e.previousDir, nil
+	}
+
+	targetDir := e.expandTilde(parts[1], env)
+	if filepath.IsAbs(targetDir) {
+		return targetDir, nil
+	}
+	return filepath.Join(e.currentWorkingDir, targetDir), nil
+}
+
+// handlePrintWorkingDirectory handles the pwd command
+func (e *commandExecutor) handlePrintWorkingDirectory(workingDir string) (types.CommandResult, error) {
+	result := types.CommandResult{
+		Command:    "pwd",
+		WorkingDir: workingDir,
+		ExitCode:   0,
+		Stdout:     workingDir,
+	}
+	return result, nil
+}
+
+// handleEnvironment handles the env command by printing the environment commands would receive
+func (e *commandExecutor) handleEnvironment(parts []string, workingDir string, env map[string]string) (types.CommandResult, error) {
+	result := types.CommandResult{
+		Command:    strings.Join(parts, " "),
+		WorkingDir: workingDir,
+		ExitCode:   0,
+	}
+
+	// Running other programs through env would bypass the allowlist
+	if len(parts) > 1 {
+		err := errors.New("env built-in does not accept arguments")
+		result.Error = err.Error()
+		result.ExitCode = 1
+		return result, err
+	}
+
+	vars := e.buildEnvironment(env)
+	sort.Strings(vars)
+
+	var out strings.Builder
+	for _, kv := range vars {
+		key, value, _ := strings.Cut(kv, "=")
+		if isSensitiveEnvKey(key) {
+			value = redactedValue
+		}
+		fmt.Fprintf(&out, "%s=%s\n", key, value)
+	}
+	result.Stdout = out.String()
+
+	return result, nil
+}
+
+// executeCommand executes the specified command with the tokenized parts
+func (e *commandExecutor) executeCommand(command string, parts []string, workingDir string, options Options) (types.CommandResult, error) {
+	logger := e.logger.With("execution_id", options.ExecutionID)
+	profiledAt := e.clock.Now()
+
+	// Initialize command execution result
+	result := types.CommandResult{
+		Command:    command,
+		WorkingDir: workingDir,
+		ExitCode:   0,
+	}
+
+	// Add the flags configured to keep the command's output stable
+	parts = e.applyArgDefaults(parts)
+
+	// Resolve absolute path for the command, unless the login shell resolves it
+	// with the PATH its rc files set up
+	binaryPath := e.cfg.CommandExec.LoginShell
+	var err error
+	if binaryPath == "" {
+		binaryPath, err = e.resolveBinaryPath(parts[0])
+		if err != nil {
+			// Nothing was found to run, so the process never started
+			return types.CommandResult{
+				Command:     command,
+				WorkingDir:  workingDir,
+				ExitCode:    startNotFoundExitCode,
+				Error:       err.Error(),
+				StartFailed: true,
+			}, err
+		}
+	}
+	args := parts[1:]
+	resolvedAt := e.clock.Now()
+
+	// Keep commands that walk directory trees from wandering the whole filesystem
+	args = e.limitTraversal(parts[0], args)
+
+	// Re-check pinned binaries in case they were replaced after startup
+	if e.cfg.CommandExec.VerifyEachRun {
+		if err := e.verifyBinary(binaryPath); err != nil {
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	// Check that file arguments stay within the allowed directories
+	if e.cfg.CommandExec.RestrictFileArgs {
+		if err := e.checkFileArgs(args, workingDir); err != nil {
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	// Fail up front instead of deep inside the tool when the working directory is read-only
+	if e.cfg.CommandExec.CheckWritable && e.isMutatingCommand(command) {
+		if err := dirWritable(workingDir); err != nil {
+			logger.Debugw("working directory is not writable",
+				"working_dir", workingDir,
+				"error", err)
+			err = errors.Newf("working directory is read-only: %s", workingDir)
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	// Reject shell syntax aimed at allowed tools that run a shell internally
+	if e.cfg.CommandExec.RejectShellMetachars {
+		if err := e.checkShellMetachars(args); err != nil {
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	// Throttle commands that modify files, after every other check has passed
+	if e.isMutatingCommand(command) && !e.mutationLimiter.allow(e.clock.Now()) {
+		logger.Warnw("mutation rate limit exceeded",
+			"command", command,
+			"limit", e.mutationLimiter.limit,
+			"window", e.mutationLimiter.window)
+		err := errors.Newf("mutation rate limit exceeded: at most %d mutating commands per %s",
+			e.mutationLimiter.limit, e.mutationLimiter.window)
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
+	}
+	validatedAt := e.clock.Now()
+
+	// Execute the command directly without using a shell
+	logger.Debugw("executing binary",
+		"binary_path", binaryPath,
+		"args", e.loggedArgs(args),
+		"working_dir", workingDir,
+		"custom_env", options.Env != nil)
+
+	// Kill the command if it runs past its timeout
+	ctx := context.Background()
+	if options.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+		defer cancel()
+	}
+
+	execArgs := args
+	if e.cfg.CommandExec.LoginShell != "" {
+		execArgs = loginShellArgs(append([]string{parts[0]}, args...))
+	}
+	cmd := exec.CommandContext(ctx, binaryPath, execArgs...)
+
+	// Don't wait forever for output from processes that outlive the killed command
+	if options.Timeout > 0 {
+		cmd.WaitDelay = timeoutWaitDelay
+	}
+
+	// Important: Set the working directory
+	cmd.Dir = e.sandboxDir(workingDir)
+
+	// Run inside chroot_dir and new namespaces when configured
+	cmd.SysProcAttr = e.sandboxAttr()
+
+	// Set environment variables (pass additional env vars)
+	cmd.Env = e.buildEnvironment(options.Env)
 
-	workingDir := cfg.CommandExec.DefaultWorkingDir
-	if workingDir == "" {
-		// Use the HOME environment variable or a default value
-		if home := os.Getenv("HOME"); home != "" {
-			workingDir = home
-		} else {
-			workingDir = "/tmp"
+	// Report how the command was resolved only on request, since PATH can reveal local details
+	if options.Explain {
+		result.Explain = &types.ExplainTrace{
+			BinaryPath: binaryPath,
+			Path:       envValue(cmd.Env, "PATH"),
 		}
 	}
 
-	// Check if the directory exists
-	if _, err := os.Stat(workingDir); os.IsNotExist(err) {
-		// Fall back to default if it doesn't exist
-		workingDir = "/tmp"
-		zap.S().Warnw("Default working directory does not exist, falling back to /tmp",
-			"original_dir", cfg.CommandExec.DefaultWorkingDir)
+	// Capture stdout and stderr, capped at the configured output size and line count
+	limit := e.maxOutputBytes(command)
+	lineLimit, keepLines, err := e.outputLineLimit(options)
+	if err != nil {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
 	}
-
-	// Validate PathBehavior
-	pathBehavior := cfg.CommandExec.PathBehavior
-	if pathBehavior != "prepend" && pathBehavior != "replace" && pathBehavior != "append" {
-		zap.S().Warnw("Invalid path_behavior setting, using default 'prepend'",
-			"value", pathBehavior)
-		pathBehavior = "prepend"
+	stdout := &limitedBuffer{limit: limit}
+	stderr := &limitedBuffer{limit: limit}
+	var killedForOutput atomic.Bool
+	var stdoutWriter, stderrWriter io.Writer = stdout, stderr
+
+	// Forward output to the stream sink as it is produced. Output goes through
+	// writers rather than StdoutPipe/StderrPipe, so exec drains both pipes in
+	// their own goroutines and Wait returns only once both are drained; a
+	// command filling one pipe cannot block behind a read of the other.
+	if options.Stream != nil {
+		// Stop a streaming command once it exceeds the output cap
+		killOnTruncate := func() {
+			logger.Warnw("streamed output exceeded the limit, killing command",
+				"command", command,
+				"limit", limit)
+			killedForOutput.Store(true)
+			cmd.Process.Kill()
+		}
+		stdoutWriter = &streamWriter{stream: StreamStdout, buf: stdout, sink: options.Stream, onTruncate: killOnTruncate}
+		stderrWriter = &streamWriter{stream: StreamStderr, buf: stderr, sink: options.Stream, onTruncate: killOnTruncate}
+
+		// Also report progress parsed from the output, if the sink supports it
+		progressSink, ok := options.Stream.(ProgressSink)
+		if pattern := e.progressPatterns[parts[0]]; ok && pattern != nil {
+			stdoutWriter = io.MultiWriter(stdoutWriter, &progressWriter{pattern: pattern, sink: progressSink})
+			stderrWriter = io.MultiWriter(stderrWriter, &progressWriter{pattern: pattern, sink: progressSink})
+		}
 	}
 
-	return &commandExecutor{
-		allowedCommands:   cfg.CommandExec.AllowedCommands,
-		currentWorkingDir: workingDir,
-		allowedDirs:       cfg.CommandExec.AllowedDirs,
-		showWorkingDir:    cfg.CommandExec.ShowWorkingDir,
-		searchPaths:       cfg.CommandExec.SearchPaths,
-		pathBehavior:      pathBehavior,
-		cfg:               cfg,
-	}, nil
-}
+	// Also write output to the tee file if requested
+	if options.TeeFile != "" {
+		// A discarded stream would silently never reach the tee file
+		if options.DiscardStdout || options.DiscardStderr {
+			err := errors.New("tee_file cannot be combined with capture_stdout or capture_stderr set to false")
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
 
-// Execute executes the specified command
-func (e *commandExecutor) Execute(command string, options Options) (types.CommandResult, error) {
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: e.currentWorkingDir,
-			ExitCode:   1,
-			Error:      "empty command",
-		}, errors.New("empty command")
+		teeFile, err := e.openTeeFile(options.TeeFile, workingDir)
+		if err != nil {
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+		defer teeFile.Close()
+
+		// Each stream gets its own redactor, since a secret split across writes
+		// to one stream can be interrupted by output on the other
+		var stdoutTee, stderrTee io.Writer = teeFile, teeFile
+		if options.secrets != nil {
+			stdoutRedactor := newRedactingWriter(teeFile, options.secrets)
+			stderrRedactor := newRedactingWriter(teeFile, options.secrets)
+			defer stderrRedactor.Flush()
+			defer stdoutRedactor.Flush()
+			stdoutTee, stderrTee = stdoutRedactor, stderrRedactor
+		}
+		stdoutWriter = io.MultiWriter(stdoutWriter, stdoutTee)
+		stderrWriter = io.MultiWriter(stderrWriter, stderrTee)
 	}
 
-	// If a working directory is specified
-	if options.WorkingDir != "" {
-		return e.executeInDirectory(command, options.WorkingDir, options.Env)
+	// Capture both streams in one buffer, in the order they were written
+	mergeOrder, err := e.mergeOrder(parts[0], options.MergeOrder)
+	if err != nil {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
 	}
-
-	// Special handling for the cd command
-	if isChangeDirectoryCommand(command) {
-		return e.handleChangeDirectory(parts)
+	var combined *limitedBuffer
+	if mergeOrder == mergeInterleaved {
+		combined = &limitedBuffer{limit: 2 * limit}
+		combinedWriter := &lockedWriter{buf: combined}
+		stdoutWriter = io.MultiWriter(stdoutWriter, combinedWriter)
+		stderrWriter = io.MultiWriter(stderrWriter, combinedWriter)
 	}
 
-	// Special handling for the pwd command
-	if isPrintWorkingDirectoryCommand(command) {
-		return e.handlePrintWorkingDirectory()
+	// Drop streams the caller did not ask for
+	if options.DiscardStdout {
+		stdoutWriter = io.Discard
+	}
+	if options.DiscardStderr {
+		stderrWriter = io.Discard
 	}
 
-	// Execute other commands
-	return e.executeCommand(command, e.currentWorkingDir, options.Env)
-}
+	cmd.Stdout = stdoutWriter
+	cmd.Stderr = stderrWriter
 
-// IsCommandAllowed checks if the command is in the allowed list
-func (e *commandExecutor) IsCommandAllowed(command string) bool {
-	// Don't allow empty commands
-	if command == "" {
-		return false
+	// Interactive commands get immediate EOF instead of blocking on input
+	switch e.stdinMode {
+	case "provided":
+		cmd.Stdin = strings.NewReader(options.Stdin)
+	default:
+		cmd.Stdin = strings.NewReader("")
 	}
 
-	// Get the first part of the command (program name)
-	parts := strings.Fields(command)
-	if len(parts) == 0 {
-		return false
+	logger.Debugw("executing command",
+		"binary_path", binaryPath,
+		"args", e.loggedArgs(args),
+		"working_dir", workingDir)
+
+	// Snapshot the working directory to report files changed by mutating commands
+	var before map[string]fileState
+	mutating := e.isMutatingCommand(command)
+	if mutating {
+		before = snapshotDir(workingDir, e.changedFilesMaxScan())
 	}
-	programName := parts[0]
 
-	// Check if the program name is in the allowed list
-	for _, allowed := range e.allowedCommands {
-		if programName == allowed {
-			return true
+	// Execute command
+	startedAt := e.clock.Now()
+	err = cmd.Start()
+	started := err == nil
+	runningAt := e.clock.Now()
+	killedByRequest := false
+	if started {
+		// Track the process while it runs so it can be listed and killed by its execution ID
+		e.processes.add(options.ExecutionID, command, cmd.Process, startedAt, options.SessionID)
+
+		// A command that cannot be held to max_memory_bytes is stopped, not left uncapped
+		if limitErr := e.limitMemory(cmd.Process.Pid); limitErr != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			err = limitErr
+		} else {
+			err = cmd.Wait()
 		}
+		killedByRequest = e.processes.remove(options.ExecutionID)
 	}
 
-	return false
-}
-
-// GetAllowedCommands returns the list of allowed commands
-func (e *commandExecutor) GetAllowedCommands() []string {
-	return e.allowedCommands
-}
-
-// GetCurrentWorkingDir returns the current working directory
-func (e *commandExecutor) GetCurrentWorkingDir() string {
-	return e.currentWorkingDir
-}
+	// Judged now, so a deadline passing during the work after Wait is not a timeout
+	deadlineExceeded := errors.Is(ctx.Err(), context.DeadlineExceeded)
 
-// IsDirectoryAllowed checks if directory access is allowed
-func (e *commandExecutor) IsDirectoryAllowed(dir string) bool {
-	// Directory access restriction implementation
-	// Allow all if the allowed list is empty
-	if len(e.allowedDirs) == 0 {
-		return true
-	}
+	finishedAt := e.clock.Now()
+	duration := finishedAt.Sub(startedAt)
 
-	// Check if it matches the allowed list
-	for _, allowedDir := range e.allowedDirs {
-		if strings.HasPrefix(dir, allowedDir) {
-			return true
+	// Break the time down by phase, to show e.g. slow resolution on network search paths
+	if options.Profile {
+		result.Timings = &types.ExecutionTimings{
+			ResolveUs:  resolvedAt.Sub(profiledAt).Microseconds(),
+			ValidateUs: validatedAt.Sub(resolvedAt).Microseconds(),
+			StartUs:    runningAt.Sub(validatedAt).Microseconds(),
+			RunUs:      finishedAt.Sub(runningAt).Microseconds(),
+			TotalUs:    finishedAt.Sub(profiledAt).Microseconds(),
 		}
 	}
 
-	return false
-}
+	logger.Debugw("command finished",
+		"binary_path", binaryPath,
+		"duration", duration)
 
-// handleChangeDirectory handles the cd command
-func (e *commandExecutor) handleChangeDirectory(parts []string) (types.CommandResult, error) {
-	result := types.CommandResult{
-		Command:    strings.Join(parts, " "),
-		WorkingDir: e.currentWorkingDir,
-		ExitCode:   0,
+	// Set output results
+	result.Stdout = stdout.String()
+	result.Stderr = stderr.String()
+	result.StdoutTruncated = stdout.truncated
+	result.StderrTruncated = stderr.truncated
+	if lineLimit > 0 {
+		var truncated bool
+		result.Stdout, truncated = truncateLines(result.Stdout, lineLimit, keepLines)
+		result.StdoutTruncated = result.StdoutTruncated || truncated
+		result.Stderr, truncated = truncateLines(result.Stderr, lineLimit, keepLines)
+		result.StderrTruncated = result.StderrTruncated || truncated
+	}
+	switch {
+	case combined != nil:
+		// Sized for both streams, like its byte cap
+		result.Combined, _ = truncateLines(combined.String(), 2*lineLimit, keepLines)
+	case mergeOrder != "":
+		result.Combined = combineOutput(mergeOrder, result.Stdout, result.Stderr)
 	}
+	result.MaxRSSBytes = maxRSSBytes(cmd.ProcessState)
 
-	var message string
-	var err error
+	if mutating {
+		result.ChangedFiles = changedFiles(before, snapshotDir(workingDir, e.changedFilesMaxScan()))
+	}
 
-	if len(parts) < 2 {
-		// If no argument, change to home directory
-		if home := os.Getenv("HOME"); home != "" {
-			e.currentWorkingDir = home
-			message = fmt.Sprintf("Changed directory to %s", home)
-			result.Stdout = message
-			result.WorkingDir = home
-		} else {
-			err = errors.New("HOME environment variable not set")
-			result.Error = err.Error()
-			result.ExitCode = 1
-			return result, err
+	defer func() {
+		// One operator-friendly line per execution
+		if e.cfg.CommandExec.LogExecutions {
+			logger.Infow("command executed",
+				"binary_path", binaryPath,
+				"args", e.loggedArgs(args),
+				"working_dir", workingDir,
+				"exit_code", result.ExitCode,
+				"duration", duration)
 		}
-	} else {
-		// Resolve directory path
-		targetDir := parts[1]
-		var newDir string
+	}()
 
-		if filepath.IsAbs(targetDir) {
-			newDir = targetDir
-		} else {
-			newDir = filepath.Join(e.currentWorkingDir, targetDir)
-		}
+	signal := terminationSignal(err)
 
-		// Normalize path (resolve symlinks, etc.)
-		evalDir, evalErr := filepath.EvalSymlinks(newDir)
-		if evalErr == nil {
-			newDir = evalDir
-		}
+	if err != nil {
+		// Set error information
+		result.Error = err.Error()
 
-		// Check if directory exists
-		stat, err := os.Stat(newDir)
-		if err != nil || !stat.IsDir() {
-			errMsg := fmt.Sprintf("Directory does not exist: %s", newDir)
-			result.Error = errMsg
+		// Get exit code, telling a process that never started (exec format
+		// error, permissions) from one that ran and failed
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			result.ExitCode = exitErr.ExitCode()
+		} else if !started {
+			result.StartFailed = true
+			result.ExitCode = startFailedExitCode
+			if errors.Is(err, fs.ErrNotExist) {
+				result.ExitCode = startNotFoundExitCode
+			}
+		} else {
 			result.ExitCode = 1
-			return result, errors.New(errMsg)
 		}
+	}
 
-		// Check access permissions
-		if !e.IsDirectoryAllowed(newDir) {
-			errMsg := fmt.Sprintf("Access to directory not allowed: %s", newDir)
-			result.Error = errMsg
-			result.ExitCode = 1
-			return result, errors.New(errMsg)
+	// Keep termination by a signal apart from a normal exit, so a killed
+	// command is never mistaken for one that exited with a particular code
+	if signal != "" {
+		result.Signal = signal
+		result.TerminatedBySignal = true
+		result.ExitCode = signaledExitCode
+	}
+
+	// Say plainly when the command ran out of max_memory_bytes, rather than
+	// leaving a bare signal or allocation error to interpret. Kills the executor
+	// caused itself (timeout, output cap, kill_process) are never blamed on it.
+	timedOut := err != nil && options.Timeout > 0 && deadlineExceeded
+	killedByExecutor := timedOut || killedForOutput.Load() || killedByRequest
+	if err != nil && !killedByExecutor && e.exceededMemoryLimit(signal, result.Stderr) {
+		err = errors.Newf("killed: exceeded memory limit (%d bytes)", e.cfg.CommandExec.MaxMemoryBytes)
+		result.Error = err.Error()
+		result.MemoryLimitExceeded = true
+	}
+
+	// Report a timeout distinctly, keeping the output captured before the kill.
+	// The kill was the executor's own, so the timeout replaces the signal.
+	if timedOut {
+		timeout := options.Timeout
+		if options.configuredTimeout > 0 {
+			timeout = options.configuredTimeout
 		}
+		err = errors.Newf("command timed out after %s", timeout)
+		result.Error = err.Error()
+		result.ExitCode = timeoutExitCode
+		result.Signal = ""
+		result.TerminatedBySignal = false
+		result.MemoryLimitExceeded = false
+		signal = ""
+	}
 
-		// Update working directory
-		e.currentWorkingDir = newDir
-		message = fmt.Sprintf("Changed directory to %s", newDir)
-		result.Stdout = message
-		result.WorkingDir = newDir
+	// Mark the end of the output stream
+	if options.Stream != nil {
+		options.Stream.Exit(ExitEvent{
+			ExitCode:        result.ExitCode,
+			DurationMs:      duration.Milliseconds(),
+			Signal:          signal,
+			StdoutTruncated: result.StdoutTruncated,
+			StderrTruncated: result.StderrTruncated,
+		})
 	}
 
-	return result, nil
+	return result, err
 }
 
-// handlePrintWorkingDirectory handles the pwd command
-func (e *commandExecutor) handlePrintWorkingDirectory() (types.CommandResult, error) {
-	result := types.CommandResult{
-		Command:    "pwd",
-		WorkingDir: e.currentWorkingDir,
-		ExitCode:   0,
-		Stdout:     e.currentWorkingDir,
+// outputMatchesSuccess checks the command's success_if_output_matches pattern,
+// if any, against its stdout and stderr
+func (e *commandExecutor) outputMatchesSuccess(command string, result types.CommandResult) bool {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return true
 	}
-	return result, nil
+
+	pattern := e.successPatterns[parts[0]]
+	if pattern == nil {
+		return true
+	}
+	return pattern.MatchString(result.Stdout) || pattern.MatchString(result.Stderr)
 }
 
-// executeCommand executes the specified command
-func (e *commandExecutor) executeCommand(command string, workingDir string, env map[string]string) (types.CommandResult, error) {
+// fatalStderrMatch returns the part of stderr matching the command's
+// fatal_stderr_patterns entry, or "" if there is none
+func (e *commandExecutor) fatalStderrMatch(command string, stderr string) string {
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: workingDir,
-			ExitCode:   1,
-			Error:      "empty command",
-		}, errors.New("empty command")
+		return ""
 	}
 
-	// Initialize command execution result
-	result := types.CommandResult{
-		Command:    command,
-		WorkingDir: workingDir,
-		ExitCode:   0,
+	pattern := e.fatalPatterns[parts[0]]
+	if pattern == nil {
+		return ""
 	}
+	return pattern.FindString(stderr)
+}
 
-	// Resolve absolute path for the command
-	binaryPath, err := e.resolveBinaryPath(command)
-	if err != nil {
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: workingDir,
-			ExitCode:   1,
-			Error:      err.Error(),
-		}, err
+// isMutatingCommand checks if the command is configured as modifying files
+func (e *commandExecutor) isMutatingCommand(command string) bool {
+	parts := strings.Fields(command)
+	if len(parts) == 0 {
+		return false
 	}
 
-	// Extract the absolute path and detect arguments
-	var args []string
-	if len(parts) > 1 {
-		args = parts[1:]
+	for _, mutating := range e.cfg.CommandExec.MutatingCommands {
+		if parts[0] == mutating {
+			return true
+		}
 	}
 
-	// Execute the command directly without using a shell
-	zap.S().Debugw("executing binary",
-		"binary_path", binaryPath,
-		"args", args,
-		"working_dir", workingDir,
-		"custom_env", env != nil)
+	return false
+}
 
-	cmd := exec.Command(binaryPath, args...)
+// changedFilesMaxScan returns the maximum number of files scanned for changes
+func (e *commandExecutor) changedFilesMaxScan() int {
+	if e.cfg.CommandExec.ChangedFilesMaxScan > 0 {
+		return e.cfg.CommandExec.ChangedFilesMaxScan
+	}
+	return defaultChangedFilesMaxScan
+}
 
-	// Important: Set the working directory
-	cmd.Dir = workingDir
+// loggedArgs truncates args to log_max_args entries for logging, noting how many were dropped
+func (e *commandExecutor) loggedArgs(args []string) []string {
+	limit := e.cfg.CommandExec.LogMaxArgs
+	if limit <= 0 || len(args) <= limit {
+		return args
+	}
 
-	// Set environment variables (pass additional env vars)
-	cmd.Env = e.buildEnvironment(env)
+	logged := make([]string, limit, limit+1)
+	copy(logged, args)
+	return append(logged, fmt.Sprintf("+%d more", len(args)-limit))
+}
 
-	// Capture stdout and stderr
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
+// checkFileArgs rejects arguments that name existing files outside the allowed directories.
+// This is a heuristic: only arguments that resolve to existing paths are checked, so paths
+// created by the command or embedded in other syntax are not detected.
+func (e *commandExecutor) checkFileArgs(args []string, workingDir string) error {
+	for _, arg := range args {
+		candidate := arg
+		// Check the value of --flag=value style arguments
+		if strings.HasPrefix(candidate, "-") {
+			idx := strings.Index(candidate, "=")
+			if idx < 0 {
+				continue
+			}
+			candidate = candidate[idx+1:]
+		}
+		if candidate == "" {
+			continue
+		}
 
-	zap.S().Debugw("executing command",
-		"binary_path", binaryPath,
-		"args", args,
-		"working_dir", workingDir)
+		path := candidate
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(workingDir, path)
+		}
 
-	// Execute command
-	err = cmd.Run()
+		// Only arguments that name existing files are considered paths
+		if _, err := e.fs.Stat(path); err != nil {
+			continue
+		}
+		if evalPath, err := e.fs.EvalSymlinks(path); err == nil {
+			path = evalPath
+		}
 
-	// Set output results
-	result.Stdout = stdout.String()
-	result.Stderr = stderr.String()
+		if !e.IsDirectoryAllowed(filepath.Clean(path)) {
+			e.logger.Warnw("file argument outside allowed directories",
+				"arg", arg,
+				"path", path)
+			return fmt.Errorf("file argument outside allowed directories: %s", arg)
+		}
+	}
 
-	if err != nil {
-		// Set error information
-		result.Error = err.Error()
+	return nil
+}
 
-		// Get exit code
-		if exitErr, ok := err.(*exec.ExitError); ok {
-			result.ExitCode = exitErr.ExitCode()
-		} else {
-			result.ExitCode = 1
+// checkShellMetachars rejects arguments containing command substitution or command separators.
+// Commands never run through a shell here, so these only matter to tools that pass arguments to one.
+func (e *commandExecutor) checkShellMetachars(args []string) error {
+	for _, arg := range args {
+		for _, seq := range shellMetachars {
+			if strings.Contains(arg, seq) {
+				e.logger.Warnw("argument contains shell metacharacters",
+					"arg", arg,
+					"sequence", seq)
+				return fmt.Errorf("argument contains shell metacharacter %q: %s", seq, arg)
+			}
 		}
-
-		return result, err
 	}
 
-	return result, nil
+	return nil
 }
 
-// executeInDirectory executes the command in the specified directory
-func (e *commandExecutor) executeInDirectory(command string, workingDir string, env map[string]string) (types.CommandResult, error) {
-	// Check if directory exists
-	stat, err := os.Stat(workingDir)
-	if err != nil || !stat.IsDir() {
-		errMsg := fmt.Sprintf("Directory does not exist: %s", workingDir)
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: e.currentWorkingDir,
-			ExitCode:   1,
-			Error:      errMsg,
-		}, errors.New(errMsg)
+// openTeeFile creates the file that receives a copy of the command output
+func (e *commandExecutor) openTeeFile(path string, workingDir string) (*os.File, error) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
 	}
+	path = filepath.Clean(path)
 
-	// Check access permissions
-	if !e.IsDirectoryAllowed(workingDir) {
-		errMsg := fmt.Sprintf("Access to directory not allowed: %s", workingDir)
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: e.currentWorkingDir,
-			ExitCode:   1,
-			Error:      errMsg,
-		}, errors.New(errMsg)
+	// Check access permissions on the directory that will hold the file,
+	// where it really is, so a symlinked directory cannot lead outside
+	dir, err := e.fs.EvalSymlinks(filepath.Dir(path))
+	if err != nil || !e.IsDirectoryAllowed(dir) {
+		return nil, fmt.Errorf("access to tee file directory not allowed: %s", path)
 	}
+	path = filepath.Join(dir, filepath.Base(path))
 
-	// Check if cd command
-	parts := strings.Fields(command)
-	if len(parts) > 0 && parts[0] == "cd" {
-		return types.CommandResult{
-			Command:    command,
-			WorkingDir: workingDir,
-			ExitCode:   1,
-			Error:      "cd command is not supported when using a temporary working directory",
-		}, errors.New("cd command is not supported in executeInDirectory")
+	// Never follow a symlink at the file itself, which could point anywhere
+	if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return nil, errors.Newf("tee file must not be a symlink: %s", path)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC|teeNoFollow, 0666)
+	if err != nil {
+		if errors.Is(err, syscall.ELOOP) {
+			return nil, errors.Newf("tee file must not be a symlink: %s", path)
+		}
+		return nil, errors.Wrapf(err, "failed to create tee file %s", path)
 	}
 
-	// Check if pwd command
-	if len(parts) > 0 && parts[0] == "pwd" {
-		return types.CommandResult{
-			Command:    "pwd",
-			WorkingDir: workingDir,
-			ExitCode:   0,
-			Stdout:     workingDir,
-		}, nil
+	return f, nil
+}
+
+// checkWorkingDir checks that a per-call working directory exists and is allowed
+func (e *commandExecutor) checkWorkingDir(workingDir string) error {
+	// Check if directory exists
+	stat, err := e.fs.Stat(workingDir)
+	if err != nil || !stat.IsDir() {
+		return errors.Newf("Directory does not exist: %s", workingDir)
+	}
+
+	// Check access permissions
+	if !e.isDirectoryIn(workingDir, e.workdirAllowedDirs()) {
+		return errors.Newf("Access to directory not allowed: %s", workingDir)
 	}
 
-	// Execute the command in the specified directory
-	return e.executeCommand(command, workingDir, env)
+	// Check directory depth
+	return e.checkWorkingDirDepth(workingDir, e.workdirAllowedDirs())
 }
 
 // buildEnvironment builds the environment variables
@@ -380,9 +1648,18 @@ func (e *commandExecutor) buildEnvironment(additionalEnv map[string]string) []st
 		}
 	}
 
+	// Start tools clean of inherited variables they misbehave with; config and
+	// per-command env can still set them
+	for _, k := range e.cfg.CommandExec.StripEnvKeys {
+		delete(envMap, k)
+	}
+
 	// Apply environment variables from config file
 	if e.cfg.CommandExec.Environment != nil {
 		for k, v := range e.cfg.CommandExec.Environment {
+			if e.isEnvKeyBlocked(k, "config") {
+				continue
+			}
 			envMap[k] = v
 		}
 	}
@@ -390,6 +1667,9 @@ func (e *commandExecutor) buildEnvironment(additionalEnv map[string]string) []st
 	// Apply additional environment variables (specified per command execution)
 	if additionalEnv != nil {
 		for k, v := range additionalEnv {
+			if e.isEnvKeyBlocked(k, "request") {
+				continue
+			}
 			envMap[k] = v
 		}
 	}
@@ -400,8 +1680,10 @@ func (e *commandExecutor) buildEnvironment(additionalEnv map[string]string) []st
 		path = p
 	}
 
-	// Update PATH if search paths are configured
-	if len(e.searchPaths) > 0 {
+	// Update PATH if search paths are configured; strict_search_paths drops the host PATH entirely
+	if e.cfg.CommandExec.StrictSearchPaths {
+		envMap["PATH"] = strings.Join(e.searchPaths, string(os.PathListSeparator))
+	} else if len(e.searchPaths) > 0 {
 		// Build new PATH
 		var newPath string
 		switch e.pathBehavior {
@@ -426,7 +1708,7 @@ func (e *commandExecutor) buildEnvironment(additionalEnv map[string]string) []st
 	}
 
 	// Debug log
-	zap.S().Debugw("environment variables set",
+	e.logger.Debugw("environment variables set",
 		"PATH", envMap["PATH"],
 		"path_behavior", e.pathBehavior,
 		"custom_env_count", len(additionalEnv))
@@ -434,8 +1716,73 @@ func (e *commandExecutor) buildEnvironment(additionalEnv map[string]string) []st
 	return updatedEnv
 }
 
-// resolveBinaryPath resolves the absolute path of the command
+// envValue returns the value of key in a KEY=VALUE environment list
+func envValue(env []string, key string) string {
+	for _, kv := range env {
+		if k, v, ok := strings.Cut(kv, "="); ok && k == key {
+			return v
+		}
+	}
+	return ""
+}
+
+// maxEnvVars returns the most per-call environment variables a call may pass
+func (e *commandExecutor) maxEnvVars() int {
+	if e.cfg.CommandExec.MaxEnvVars > 0 {
+		return e.cfg.CommandExec.MaxEnvVars
+	}
+	return defaultMaxEnvVars
+}
+
+// filterEnvOverrides returns the per-call environment without the keys that
+// allowed_env_overrides does not list
+func (e *commandExecutor) filterEnvOverrides(env map[string]string) map[string]string {
+	if e.envOverrides == nil || len(env) == 0 {
+		return env
+	}
+
+	filtered := make(map[string]string, len(env))
+	for k, v := range env {
+		if !e.envOverrides[k] {
+			e.logger.Warnw("dropping environment variable not in allowed_env_overrides",
+				"key", k)
+			continue
+		}
+		filtered[k] = v
+	}
+	return filtered
+}
+
+// isEnvKeyBlocked checks if the environment variable is in the blocklist
+func (e *commandExecutor) isEnvKeyBlocked(key string, source string) bool {
+	if !e.blockedEnvKeys[key] {
+		return false
+	}
+
+	e.logger.Warnw("dropping blocked environment variable",
+		"key", key,
+		"source", source)
+	return true
+}
+
+// ResolveBinaryPath resolves the absolute path of the command's program
+func (e *commandExecutor) ResolveBinaryPath(command string) (string, error) {
+	return e.resolveBinaryPath(command)
+}
+
+// resolveBinaryPath resolves the absolute path of the command within resolve_timeout_ms
 func (e *commandExecutor) resolveBinaryPath(command string) (string, error) {
+	var path string
+	err := e.withResolveTimeout("resolving "+command, func() error {
+		var err error
+		path, err = e.lookupBinaryPath(command)
+		return err
+	})
+	return path, err
+}
+
+// lookupBinaryPath searches for the absolute path of the command
+func (e *commandExecutor) lookupBinaryPath(command string) (string, error) {
 	// Get the command name (first part split by spaces)
 	parts := strings.Fields(command)
 	if len(parts) == 0 {
@@ -446,7 +1793,7 @@ func (e *commandExecutor) resolveBinaryPath(command string) (string, error) {
 	// If it's an absolute path, return it as is
 	if filepath.IsAbs(cmdName) {
 		// Check if it's executable
-		info, err := os.Stat(cmdName)
+		info, err := e.fs.Stat(cmdName)
 		if err != nil {
 			return "", fmt.Errorf("command not found: %s", cmdName)
 		}
@@ -459,7 +1806,7 @@ func (e *commandExecutor) resolveBinaryPath(command string) (string, error) {
 	// Search for executable in the configured search paths
 	for _, dir := range e.searchPaths {
 		path := filepath.Join(dir, cmdName)
-		info, err := os.Stat(path)
+		info, err := e.fs.Stat(path)
 		if err == nil {
 			// Check if file exists and is executable
 			if !info.IsDir() && isExecutable(info) {
@@ -469,7 +1816,7 @@ func (e *commandExecutor) resolveBinaryPath(command string) (string, error) {
 	}
 
 	// If not found, search using the system PATH (according to path_behavior)
-	if e.pathBehavior != "replace" {
+	if e.pathBehavior != "replace" && !e.cfg.CommandExec.StrictSearchPaths {
 		// LookPath searches for an executable in the system PATH
 		path, err := exec.LookPath(cmdName)
 		if err == nil {
@@ -490,15 +1837,3 @@ func isExecutable(info os.FileInfo) bool {
 	// Currently only supporting Unix-like OS
 	return true
 }
-
-// isChangeDirectoryCommand checks if the command is a cd command
-func isChangeDirectoryCommand(command string) bool {
-	parts := strings.Fields(command)
-	return len(parts) > 0 && parts[0] == "cd"
-}
-
-// isPrintWorkingDirectoryCommand checks if the command is a pwd command
-func isPrintWorkingDirectoryCommand(command string) bool {
-	parts := strings.Fields(command)
-	return len(parts) > 0 && parts[0] == "pwd"
-}
@@ -0,0 +1,1577 @@
+package executor
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// newTestExecutor - Create an executor rooted in a temporary directory
+func newTestExecutor(t *testing.T, modify func(cfg *config.Config), opts ...Option) (*commandExecutor, string) {
+	t.Helper()
+
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo", "sh", "cat", "ls", "cd", "pwd"}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.AllowedDirs = []string{dir}
+	cfg.CommandExec.PathBehavior = "prepend"
+	if modify != nil {
+		modify(cfg)
+	}
+
+	cmdExecutor, err := newCommandExecutor(cfg, opts...)
+	require.NoError(t, err)
+
+	return cmdExecutor, dir
+}
+
+// TestRequireAllowedBinaries - Test that startup fails only when an allowed binary is missing
+func TestRequireAllowedBinaries(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.RequireAllowedBinaries = true
+
+	// Present binaries and built-ins start
+	cfg.CommandExec.AllowedCommands = []string{"sh", "echo", "cd", "pwd", "env"}
+	_, err := newCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	// Missing binaries fail startup, all listed at once
+	cfg.CommandExec.AllowedCommands = []string{"sh", "no-such-binary-a", "no-such-binary-b"}
+	_, err = newCommandExecutor(cfg)
+	assert.EqualError(t, err, "allowed commands not found: no-such-binary-a, no-such-binary-b")
+
+	// Without the setting they only fail when run
+	cfg.CommandExec.RequireAllowedBinaries = false
+	_, err = newCommandExecutor(cfg)
+	require.NoError(t, err)
+}
+
+// TestExecuteRawCommand - Test that built-in, real, and failed commands report the command as received
+func TestExecuteRawCommand(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	result, err := cmdExecutor.Execute(" cd  sub ", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "cd sub", result.Command)
+	assert.Equal(t, " cd  sub ", result.RawCommand)
+
+	result, err = cmdExecutor.Execute("pwd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "pwd", result.RawCommand)
+
+	result, err = cmdExecutor.Execute("echo  hi", Options{RawCommand: "greet"})
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", result.Command)
+	assert.Equal(t, "greet", result.RawCommand)
+
+	// Failures before and during execution carry it too
+	result, err = cmdExecutor.Execute("echo \xff", Options{})
+	require.Error(t, err)
+	assert.Equal(t, "echo \xff", result.RawCommand)
+	result, err = cmdExecutor.Execute("cd  missing", Options{})
+	require.Error(t, err)
+	assert.Equal(t, "cd  missing", result.RawCommand)
+}
+
+// TestExecuteParseJSON - Test that valid JSON output is attached as structured data
+func TestExecuteParseJSON(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", `echo '{"name": "web", "ports": [80, 443]}'`}, ParseJSON: true})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "web", "ports": [80, 443]}`, string(result.ParsedJSON))
+	assert.Equal(t, "{\"name\": \"web\", \"ports\": [80, 443]}\n", result.Stdout)
+
+	encoded, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.Contains(t, string(encoded), `"parsed_json":{"name":"web","ports":[80,443]}`)
+
+	// Invalid JSON, failed commands, and calls without the option keep only raw stdout
+	result, err = cmdExecutor.Execute("echo not json", Options{ParseJSON: true})
+	require.NoError(t, err)
+	assert.Nil(t, result.ParsedJSON)
+	assert.Equal(t, "not json\n", result.Stdout)
+
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo '[1]'; exit 1"}, ParseJSON: true})
+	require.Error(t, err)
+	assert.Nil(t, result.ParsedJSON)
+
+	result, err = cmdExecutor.Execute("echo [1]", Options{})
+	require.NoError(t, err)
+	assert.Nil(t, result.ParsedJSON)
+}
+
+// TestStrictConfig - Test that malformed settings fail startup only with strict_config
+func TestStrictConfig(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+
+	// Lenient: warn and fall back
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.PathBehavior = "prepnd"
+	}, WithLogger(zap.New(core).Sugar()))
+	assert.Equal(t, "prepend", cmdExecutor.pathBehavior)
+	require.Equal(t, 1, logs.FilterMessage("Invalid path_behavior setting, using default 'prepend'").Len())
+
+	// Strict: fail
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.PathBehavior = "prepnd"
+	cfg.CommandExec.StrictConfig = true
+	_, err := newCommandExecutor(cfg)
+	assert.EqualError(t, err, `invalid path_behavior setting: "prepnd"`)
+
+	cfg.CommandExec.PathBehavior = "prepend"
+	cfg.CommandExec.StdinMode = "tty"
+	_, err = newCommandExecutor(cfg)
+	assert.EqualError(t, err, `invalid stdin_mode setting: "tty"`)
+
+	cfg.CommandExec.StdinMode = "null"
+	cfg.CommandExec.DefaultWorkingDir = filepath.Join(dir, "missing")
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "default_working_dir does not exist")
+
+	// Valid settings still start
+	cfg.CommandExec.DefaultWorkingDir = dir
+	_, err = newCommandExecutor(cfg)
+	assert.NoError(t, err)
+}
+
+// TestExecuteTeeFile - Test that output goes to both the tee file and the result
+func TestExecuteTeeFile(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("echo archived output", Options{TeeFile: "out.log"})
+	require.NoError(t, err)
+	assert.Equal(t, "archived output\n", result.Stdout)
+
+	content, err := os.ReadFile(filepath.Join(dir, "out.log"))
+	require.NoError(t, err)
+	assert.Equal(t, "archived output\n", string(content))
+}
+
+// TestExecuteTeeFileOutsideAllowedDirs - Test that the tee file must be in an allowed directory
+func TestExecuteTeeFileOutsideAllowedDirs(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	outside := filepath.Join(t.TempDir(), "out.log")
+	result, err := cmdExecutor.Execute("echo secret", Options{TeeFile: outside})
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Empty(t, result.Stdout)
+
+	_, statErr := os.Stat(outside)
+	assert.True(t, os.IsNotExist(statErr))
+}
+
+// TestExecuteTeeFileSymlink - Test that a tee file symlink cannot redirect output outside the allowed directories
+func TestExecuteTeeFileSymlink(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	outsideDir := t.TempDir()
+	victim := filepath.Join(outsideDir, "victim")
+	require.NoError(t, os.WriteFile(victim, []byte("keep"), 0644))
+	require.NoError(t, os.Symlink(victim, filepath.Join(dir, "link.log")))
+	require.NoError(t, os.Symlink(outsideDir, filepath.Join(dir, "linkdir")))
+
+	_, err := cmdExecutor.Execute("echo overwrite", Options{TeeFile: "link.log"})
+	assert.ErrorContains(t, err, "tee file must not be a symlink")
+	_, err = cmdExecutor.Execute("echo overwrite", Options{TeeFile: "linkdir/victim"})
+	assert.ErrorContains(t, err, "access to tee file directory not allowed")
+
+	content, err := os.ReadFile(victim)
+	require.NoError(t, err)
+	assert.Equal(t, "keep", string(content))
+}
+
+// TestExecuteTeeFileDiscard - Test that tee_file is rejected together with a discarded stream
+func TestExecuteTeeFileDiscard(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	_, err := cmdExecutor.Execute("echo archived", Options{TeeFile: "out.log", DiscardStdout: true})
+	assert.EqualError(t, err, "tee_file cannot be combined with capture_stdout or capture_stderr set to false")
+	assert.NoFileExists(t, filepath.Join(dir, "out.log"))
+}
+
+// TestBuildEnvironmentBlockedKeys - Test that blocked variables are dropped
+func TestBuildEnvironmentBlockedKeys(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.Environment = map[string]string{
+			"DYLD_INSERT_LIBRARIES": "/tmp/evil.dylib",
+			"LANG":                  "C",
+		}
+	})
+
+	env := cmdExecutor.buildEnvironment(map[string]string{
+		"LD_PRELOAD": "/tmp/evil.so",
+		"DEBUG":      "1",
+	})
+
+	assert.Contains(t, env, "LANG=C")
+	assert.Contains(t, env, "DEBUG=1")
+	for _, kv := range env {
+		assert.NotContains(t, kv, "LD_PRELOAD=/tmp/evil.so")
+		assert.NotContains(t, kv, "DYLD_INSERT_LIBRARIES=")
+	}
+}
+
+// TestExecuteStrictSearchPaths - Test that strict_search_paths never consults the host PATH
+func TestExecuteStrictSearchPaths(t *testing.T) {
+	binDir := t.TempDir()
+	script := filepath.Join(binDir, "showpath")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho \"$PATH\"\n"), 0755))
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"echo", "showpath"}
+		cfg.CommandExec.SearchPaths = []string{binDir}
+		cfg.CommandExec.StrictSearchPaths = true
+	})
+
+	// echo is only on the system PATH
+	_, err := cmdExecutor.ResolveBinaryPath("echo")
+	assert.ErrorContains(t, err, "command not found: echo")
+	_, err = cmdExecutor.Execute("echo hi", Options{})
+	assert.Error(t, err)
+
+	// The child's PATH is exactly search_paths
+	result, err := cmdExecutor.Execute("showpath", Options{Env: map[string]string{"PATH": "/usr/bin"}})
+	require.NoError(t, err)
+	assert.Equal(t, binDir+"\n", result.Stdout)
+}
+
+// TestExecuteStartFailed - Test that a process that cannot start is told apart from a nonzero exit
+func TestExecuteStartFailed(t *testing.T) {
+	binDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "garbage"), []byte("not a program\n"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(binDir, "noexec"), []byte("#!/bin/sh\n"), 0644))
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "garbage", "noexec")
+		cfg.CommandExec.SearchPaths = []string{binDir}
+	})
+
+	// An executable that the kernel cannot run
+	result, err := cmdExecutor.Execute("garbage", Options{})
+	require.Error(t, err)
+	assert.True(t, result.StartFailed)
+	assert.Equal(t, 126, result.ExitCode)
+
+	// A file that is not executable is never found to run
+	result, err = cmdExecutor.Execute("noexec", Options{})
+	require.Error(t, err)
+	assert.True(t, result.StartFailed)
+	assert.Equal(t, 127, result.ExitCode)
+
+	// A process that ran and exited nonzero did start
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "exit 3"}})
+	require.Error(t, err)
+	assert.False(t, result.StartFailed)
+	assert.Equal(t, 3, result.ExitCode)
+}
+
+// TestExecuteMergeOrder - Test each ordering of the combined output
+func TestExecuteMergeOrder(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.CommandMergeOrder = map[string]string{"sh": "stderr_first"}
+	})
+	script := []string{"-c", "echo out1; sleep 0.05; echo err1 >&2; sleep 0.05; echo out2; sleep 0.05; echo err2 >&2"}
+
+	tests := []struct {
+		order    string
+		combined string
+	}{
+		{"interleaved", "out1\nerr1\nout2\nerr2\n"},
+		{"stdout_first", "out1\nout2\nerr1\nerr2\n"},
+		{"stderr_first", "err1\nerr2\nout1\nout2\n"},
+		// Falls back to the command_merge_order entry
+		{"", "err1\nerr2\nout1\nout2\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			result, err := cmdExecutor.Execute("sh", Options{Args: script, MergeOrder: tt.order})
+			require.NoError(t, err)
+			assert.Equal(t, tt.combined, result.Combined)
+			assert.Equal(t, "out1\nout2\n", result.Stdout)
+			assert.Equal(t, "err1\nerr2\n", result.Stderr)
+		})
+	}
+
+	// No combined output unless an order applies
+	result, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Combined)
+
+	_, err = cmdExecutor.Execute("echo hi", Options{MergeOrder: "random"})
+	assert.ErrorContains(t, err, "invalid merge_order")
+}
+
+// TestExecuteInvalidUTF8 - Test that commands with invalid UTF-8 are rejected or sanitized
+func TestExecuteInvalidUTF8(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("echo caf\xe9", Options{})
+	require.Error(t, err)
+	assert.Equal(t, "command is not valid UTF-8", result.Error)
+	assert.Equal(t, "echo caf\uFFFD", result.Command)
+	assert.True(t, utf8.ValidString(result.Command))
+
+	cmdExecutor, _ = newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.InvalidUTF8 = "sanitize"
+	})
+
+	result, err = cmdExecutor.Execute("echo caf\xe9", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "echo caf\uFFFD", result.Command)
+	assert.Equal(t, "caf\uFFFD\n", result.Stdout)
+}
+
+// TestExecuteSilentSuccess - Test that silent successful commands report success with a note
+func TestExecuteSilentSuccess(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"true", "false", "echo"}
+		cfg.CommandExec.SilentSuccessNote = true
+	})
+
+	result, err := cmdExecutor.Execute("true", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Equal(t, "command completed with no output", result.Note)
+
+	// Commands with output, or failing silently, get no note
+	result, err = cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Empty(t, result.Note)
+
+	result, _ = cmdExecutor.Execute("false", Options{})
+	assert.False(t, result.Success)
+	assert.Empty(t, result.Note)
+
+	// The note can be turned off; success is always reported
+	cmdExecutor.cfg.CommandExec.SilentSuccessNote = false
+	result, err = cmdExecutor.Execute("true", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Empty(t, result.Note)
+}
+
+// TestExecuteSuccessIfOutputMatches - Test that success can depend on the output
+func TestExecuteSuccessIfOutputMatches(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SuccessIfOutputMatches = map[string]string{"sh": `(?m)^PASS$`}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo running; echo PASS"}})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	assert.Empty(t, result.Note)
+
+	// Exit code 0 but the expected output is missing
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo FAIL"}})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.False(t, result.Success)
+	assert.Equal(t, "output did not match success_if_output_matches", result.Note)
+
+	// Commands without a pattern only depend on the exit code
+	result, err = cmdExecutor.Execute("echo FAIL", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	// Invalid patterns are rejected at startup
+	cfg := &config.Config{}
+	cfg.CommandExec.SuccessIfOutputMatches = map[string]string{"sh": "("}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	_, err = NewCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid success_if_output_matches entry for sh")
+}
+
+// TestExecuteFatalStderrPatterns - Test that matching stderr fails a command that exited 0
+func TestExecuteFatalStderrPatterns(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.FatalStderrPatterns = map[string]string{"sh": `(?m)^ERROR: .*`}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo 'ERROR: deprecated, ignoring' >&2"}})
+	require.Error(t, err)
+	assert.EqualError(t, err, `stderr matched fatal_stderr_patterns: "ERROR: deprecated, ignoring"`)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.False(t, result.Success)
+	assert.Equal(t, err.Error(), result.Error)
+
+	// Other stderr, stdout, and other commands are unaffected
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo 'warning: ERROR: later' >&2; echo 'ERROR: on stdout'"}})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+	result, err = cmdExecutor.Execute("echo ERROR: fine", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.Success)
+
+	// Invalid patterns are rejected at startup
+	cfg := &config.Config{}
+	cfg.CommandExec.FatalStderrPatterns = map[string]string{"sh": "("}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	_, err = NewCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid fatal_stderr_patterns entry for sh")
+}
+
+// TestBuildEnvironmentStripKeys - Test that stripped inherited variables are absent unless re-added
+func TestBuildEnvironmentStripKeys(t *testing.T) {
+	t.Setenv("GOPATH", "/server/go")
+	t.Setenv("VIRTUAL_ENV", "/server/venv")
+	t.Setenv("NODE_OPTIONS", "--inspect")
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.StripEnvKeys = []string{"GOPATH", "VIRTUAL_ENV", "NODE_OPTIONS"}
+		cfg.CommandExec.Environment = map[string]string{"GOPATH": "/config/go"}
+	})
+
+	env := cmdExecutor.buildEnvironment(map[string]string{"NODE_OPTIONS": "--max-old-space-size=512"})
+
+	assert.Contains(t, env, "GOPATH=/config/go")
+	assert.Contains(t, env, "NODE_OPTIONS=--max-old-space-size=512")
+	for _, kv := range env {
+		assert.False(t, strings.HasPrefix(kv, "VIRTUAL_ENV="))
+	}
+}
+
+// TestExecuteMaxEnvVars - Test that calls passing more than max_env_vars variables are rejected
+func TestExecuteMaxEnvVars(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxEnvVars = 2
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args: []string{"-c", `echo "$A$B"`},
+		Env:  map[string]string{"A": "1", "B": "2"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "12\n", result.Stdout)
+
+	result, err = cmdExecutor.Execute("echo hi", Options{Env: map[string]string{"A": "1", "B": "2", "C": "3"}})
+	assert.EqualError(t, err, "too many environment variables: 3 (max_env_vars is 2)")
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Empty(t, result.Stdout)
+
+	// Unset falls back to a generous default
+	cmdExecutor, _ = newTestExecutor(t, nil)
+	env := make(map[string]string, defaultMaxEnvVars+1)
+	for i := 0; i < defaultMaxEnvVars; i++ {
+		env[fmt.Sprintf("VAR_%d", i)] = "x"
+	}
+	_, err = cmdExecutor.Execute("echo hi", Options{Env: env})
+	require.NoError(t, err)
+	env["ONE_MORE"] = "x"
+	_, err = cmdExecutor.Execute("echo hi", Options{Env: env})
+	assert.ErrorContains(t, err, "too many environment variables")
+}
+
+// TestExecuteAllowedEnvOverrides - Test that only listed keys can be overridden per call
+func TestExecuteAllowedEnvOverrides(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedEnvOverrides = []string{"GIT_AUTHOR_NAME"}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args: []string{"-c", `echo "$GIT_AUTHOR_NAME $HOME"`},
+		Env:  map[string]string{"GIT_AUTHOR_NAME": "alice", "HOME": "/override"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "alice "+os.Getenv("HOME")+"\n", result.Stdout)
+
+	// Unset leaves overrides unrestricted
+	cmdExecutor, _ = newTestExecutor(t, nil)
+	result, err = cmdExecutor.Execute("sh", Options{
+		Args: []string{"-c", `echo "$HOME"`},
+		Env:  map[string]string{"HOME": "/override"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "/override\n", result.Stdout)
+}
+
+// TestExecuteRestrictFileArgs - Test that file arguments outside allowed dirs are rejected
+func TestExecuteRestrictFileArgs(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RestrictFileArgs = true
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "inside.txt"), []byte("inside\n"), 0644))
+	outside := filepath.Join(t.TempDir(), "outside.txt")
+	require.NoError(t, os.WriteFile(outside, []byte("outside\n"), 0644))
+
+	// File inside the sandbox
+	result, err := cmdExecutor.Execute("cat inside.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "inside\n", result.Stdout)
+
+	// File outside the sandbox
+	result, err = cmdExecutor.Execute("cat "+outside, Options{})
+	assert.Error(t, err)
+	assert.Empty(t, result.Stdout)
+	assert.Contains(t, result.Error, "file argument outside allowed directories")
+
+	// Flag values are checked too
+	_, err = cmdExecutor.Execute("cat --file="+outside, Options{})
+	assert.Error(t, err)
+}
+
+// TestExecuteRejectShellMetachars - Test that arguments with shell syntax are rejected when enabled
+func TestExecuteRejectShellMetachars(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RejectShellMetachars = true
+	})
+
+	for _, arg := range []string{"$(id)", "`id`", "a;id", "x=$(whoami)"} {
+		result, err := cmdExecutor.Execute("echo "+arg, Options{})
+		assert.Error(t, err, arg)
+		assert.Empty(t, result.Stdout)
+		assert.Contains(t, result.Error, "argument contains shell metacharacter", arg)
+	}
+
+	// Literal arguments are checked too
+	_, err := cmdExecutor.Execute("echo", Options{Args: []string{"safe", "$(id)"}})
+	assert.Error(t, err)
+
+	// Benign arguments, including a lone dollar sign and parentheses
+	result, err := cmdExecutor.Execute("echo $HOME (x) a|b", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "$HOME (x) a|b\n", result.Stdout)
+
+	// Off by default
+	cmdExecutor, _ = newTestExecutor(t, nil)
+	result, err = cmdExecutor.Execute("echo a;b", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "a;b\n", result.Stdout)
+}
+
+// TestWorkingDirDepth - Test that directories beyond the maximum depth are rejected
+func TestWorkingDirDepth(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxWorkingDirDepth = 2
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b", "c"), 0755))
+
+	// Within the allowed depth
+	result, err := cmdExecutor.Execute("cd a/b", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "a", "b"), result.WorkingDir)
+
+	// Beyond the allowed depth via cd
+	_, err = cmdExecutor.Execute("cd c", Options{})
+	assert.Error(t, err)
+	assert.Equal(t, filepath.Join(dir, "a", "b"), cmdExecutor.GetCurrentWorkingDir())
+
+	// Beyond the allowed depth via working_dir
+	result, err = cmdExecutor.Execute("ls", Options{WorkingDir: filepath.Join(dir, "a", "b", "c")})
+	assert.Error(t, err)
+	assert.Contains(t, result.Error, "exceeding the maximum depth of 2")
+}
+
+// TestChangeDirectoryConfiguredHome - Test that cd uses the HOME from the config environment
+func TestChangeDirectoryConfiguredHome(t *testing.T) {
+	home, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	require.NoError(t, os.Mkdir(filepath.Join(home, "projects"), 0755))
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.Environment = map[string]string{"HOME": home}
+		cfg.CommandExec.AllowedDirs = nil
+	})
+
+	// cd with no arguments goes to the configured HOME
+	result, err := cmdExecutor.Execute("cd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, home, result.WorkingDir)
+
+	// Tilde expands to the configured HOME
+	result, err = cmdExecutor.Execute("cd ~/projects", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(home, "projects"), result.WorkingDir)
+
+	// Per-command env takes precedence
+	other, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	result, err = cmdExecutor.Execute("cd", Options{Env: map[string]string{"HOME": other}})
+	require.NoError(t, err)
+	assert.Equal(t, other, result.WorkingDir)
+}
+
+// TestChangeDirectoryHomeOutsideAllowedDirs - Test that a bare cd does not follow a per-call HOME outside allowed_dirs
+func TestChangeDirectoryHomeOutsideAllowedDirs(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	result, err := cmdExecutor.Execute("cd", Options{Env: map[string]string{"HOME": outside}})
+	require.Error(t, err)
+	assert.Contains(t, result.Error, "Access to directory not allowed")
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+
+	// A HOME that does not exist is rejected as well
+	result, err = cmdExecutor.Execute("cd", Options{Env: map[string]string{"HOME": filepath.Join(dir, "missing")}})
+	require.Error(t, err)
+	assert.Contains(t, result.Error, "Directory does not exist")
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+}
+
+// TestChangeDirectoryDefaultHome - Test that cd with HOME unset uses default_home_dir, then default_working_dir
+func TestChangeDirectoryDefaultHome(t *testing.T) {
+	t.Setenv("HOME", "")
+
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.DefaultHomeDir = filepath.Join(cfg.CommandExec.DefaultWorkingDir, "home")
+		require.NoError(t, os.Mkdir(cfg.CommandExec.DefaultHomeDir, 0755))
+	})
+
+	result, err := cmdExecutor.Execute("cd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "home"), result.WorkingDir)
+
+	// Without default_home_dir, cd returns to the default working directory
+	cmdExecutor, dir = newTestExecutor(t, nil)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+	_, err = cmdExecutor.Execute("cd sub", Options{})
+	require.NoError(t, err)
+	result, err = cmdExecutor.Execute("cd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, dir, result.WorkingDir)
+
+	// A default_home_dir outside the allowed directories is rejected
+	outside := t.TempDir()
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedDirs = []string{dir}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.DefaultHomeDir = outside
+	cfg.CommandExec.StrictConfig = true
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid default_home_dir setting")
+}
+
+// TestChangeDirectoryPrevious - Test that cd - returns to the previous directory
+func TestChangeDirectoryPrevious(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	_, err := cmdExecutor.Execute("cd -", Options{})
+	assert.EqualError(t, err, "no previous directory to change to")
+
+	_, err = cmdExecutor.Execute("cd sub", Options{})
+	require.NoError(t, err)
+	target, err := cmdExecutor.ChangeDirectoryTarget("cd -", nil)
+	require.NoError(t, err)
+	assert.Equal(t, dir, target)
+
+	result, err := cmdExecutor.Execute("cd -", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, dir, result.WorkingDir)
+	result, err = cmdExecutor.Execute("cd -", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "sub"), result.WorkingDir)
+}
+
+// TestExecuteChangedFiles - Test that mutating commands report changed files
+func TestExecuteChangedFiles(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "touch", "rm")
+		cfg.CommandExec.MutatingCommands = []string{"touch", "rm"}
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "old.txt"), []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "untouched.txt"), []byte("same"), 0644))
+
+	result, err := cmdExecutor.Execute("touch new.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"new.txt"}, result.ChangedFiles)
+
+	result, err = cmdExecutor.Execute("rm old.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"old.txt"}, result.ChangedFiles)
+
+	// Commands not flagged as mutating are not scanned
+	result, err = cmdExecutor.Execute("ls", Options{})
+	require.NoError(t, err)
+	assert.Nil(t, result.ChangedFiles)
+}
+
+// TestExecuteCheckWritable - Test that mutating commands fail up front in a read-only working directory
+func TestExecuteCheckWritable(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "touch")
+		cfg.CommandExec.MutatingCommands = []string{"touch"}
+		cfg.CommandExec.CheckWritable = true
+	})
+	require.NoError(t, os.Chmod(dir, 0555))
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+
+	result, err := cmdExecutor.Execute("touch new.txt", Options{})
+	assert.EqualError(t, err, "working directory is read-only: "+dir)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Empty(t, result.Stderr)
+
+	// Commands not flagged as mutating still run
+	_, err = cmdExecutor.Execute("ls", Options{})
+	assert.NoError(t, err)
+
+	// Writable directories pass the check without leaving the probe file behind
+	require.NoError(t, os.Chmod(dir, 0755))
+	_, err = cmdExecutor.Execute("touch new.txt", Options{})
+	require.NoError(t, err)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "new.txt", entries[0].Name())
+}
+
+// TestExecuteEchoCommand - Test that the command is echoed into stdout exactly once
+func TestExecuteEchoCommand(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = "busy"
+		cfg.CommandExec.RetryMaxAttempts = 2
+		cfg.CommandExec.RetryBackoffMs = 1
+	})
+
+	result, err := cmdExecutor.Execute("echo hello", Options{EchoCommand: true})
+	require.NoError(t, err)
+	assert.Equal(t, "$ echo hello\nhello\n", result.Stdout)
+
+	// Retried commands are echoed once
+	result, err = cmdExecutor.Execute("sh -c", Options{
+		Args:        []string{"-c", "echo out; echo busy >&2; exit 1"},
+		EchoCommand: true,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "$ sh -c\nout\n", result.Stdout)
+
+	// Built-in commands are echoed too
+	result, err = cmdExecutor.Execute("pwd", Options{EchoCommand: true})
+	require.NoError(t, err)
+	assert.Equal(t, "$ pwd\n"+dir, result.Stdout)
+
+	// Off by default
+	result, err = cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", result.Stdout)
+}
+
+// TestNormalizeCommand - Test trimming and collapsing whitespace in commands
+func TestNormalizeCommand(t *testing.T) {
+	assert.Equal(t, "echo a b", NormalizeCommand("  echo   a\t b \n"))
+	assert.Equal(t, "", NormalizeCommand(" \t "))
+
+	// Padded commands run and are reported in their normalized form
+	cmdExecutor, _ := newTestExecutor(t, nil)
+	assert.True(t, cmdExecutor.IsCommandAllowed("  echo  hi"))
+	result, err := cmdExecutor.Execute("  echo  hi  ", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "echo hi", result.Command)
+	assert.Equal(t, "hi\n", result.Stdout)
+}
+
+// TestExecuteDiscardStreams - Test capturing only one of stdout and stderr
+func TestExecuteDiscardStreams(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	script := "echo out; echo err >&2; echo done > marker; exit 2"
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", script}, DiscardStdout: true})
+	assert.Error(t, err)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Empty(t, result.Stdout)
+	assert.Equal(t, "err\n", result.Stderr)
+
+	// The process still ran to completion
+	marker, err := os.ReadFile(filepath.Join(dir, "marker"))
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", string(marker))
+
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", script}, DiscardStderr: true})
+	assert.Error(t, err)
+	assert.Equal(t, 2, result.ExitCode)
+	assert.Equal(t, "out\n", result.Stdout)
+	assert.Empty(t, result.Stderr)
+}
+
+// TestExecuteMissingWorkingDir - Test recovery when the current working directory is removed
+func TestExecuteMissingWorkingDir(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "rm")
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "tmp", "tree"), 0755))
+
+	_, err := cmdExecutor.Execute("cd tmp/tree", Options{})
+	require.NoError(t, err)
+	_, err = cmdExecutor.Execute("rm -rf "+filepath.Join(dir, "tmp"), Options{})
+	require.NoError(t, err)
+
+	// The next command runs in the default working directory
+	result, err := cmdExecutor.Execute("ls", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.WorkingDirReset)
+	assert.Equal(t, dir, result.WorkingDir)
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+
+	// Only the first command after the removal is flagged
+	result, err = cmdExecutor.Execute("ls", Options{})
+	require.NoError(t, err)
+	assert.False(t, result.WorkingDirReset)
+}
+
+// TestExecuteMissingWorkingDirFail - Test failing instead of falling back
+func TestExecuteMissingWorkingDirFail(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MissingWorkingDir = "fail"
+	})
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+
+	_, err := cmdExecutor.Execute("cd sub", Options{})
+	require.NoError(t, err)
+	require.NoError(t, os.Remove(sub))
+
+	result, err := cmdExecutor.Execute("ls", Options{})
+	assert.EqualError(t, err, "current working directory no longer exists: "+sub)
+	assert.False(t, result.WorkingDirReset)
+}
+
+// TestExecuteExecutionID - Test that each execution gets a unique ID in its result and logs
+func TestExecuteExecutionID(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	cmdExecutor, _ := newTestExecutor(t, nil, WithLogger(zap.New(core).Sugar()))
+
+	first, err := cmdExecutor.Execute("echo one", Options{})
+	require.NoError(t, err)
+	second, err := cmdExecutor.Execute("echo two", Options{})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, first.ExecutionID)
+	assert.NotEqual(t, first.ExecutionID, second.ExecutionID)
+
+	// The execution's log entries carry its ID
+	entries := logs.FilterField(zap.String("execution_id", first.ExecutionID))
+	assert.Equal(t, 1, entries.FilterMessage("executing binary").Len())
+	assert.Equal(t, 1, entries.FilterMessage("command finished").Len())
+
+	// A caller-provided ID is kept
+	result, err := cmdExecutor.Execute("echo three", Options{ExecutionID: "exec-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "exec-1", result.ExecutionID)
+}
+
+// TestExecuteExplain - Test that the PATH the command ran with appears in the explain trace
+func TestExecuteExplain(t *testing.T) {
+	binDir := t.TempDir()
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SearchPaths = []string{binDir}
+		cfg.CommandExec.Environment = map[string]string{"PATH": "/usr/bin:/bin"}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo $PATH"}, Explain: true})
+	require.NoError(t, err)
+	require.NotNil(t, result.Explain)
+	assert.Equal(t, binDir+":/usr/bin:/bin", result.Explain.Path)
+	assert.Equal(t, result.Explain.Path+"\n", result.Stdout)
+	assert.True(t, filepath.IsAbs(result.Explain.BinaryPath))
+
+	// Not emitted unless requested
+	result, err = cmdExecutor.Execute("echo", Options{})
+	require.NoError(t, err)
+	assert.Nil(t, result.Explain)
+}
+
+// TestExecuteLogExecutions - Test the single per-execution log entry
+func TestExecuteLogExecutions(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.LogExecutions = true
+	}, WithLogger(zap.New(core).Sugar()))
+
+	_, err := cmdExecutor.Execute("echo hello world", Options{})
+	require.NoError(t, err)
+
+	entries := logs.FilterMessage("command executed").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Contains(t, fields["binary_path"], "echo")
+	assert.Equal(t, []interface{}{"hello", "world"}, fields["args"])
+	assert.Equal(t, dir, fields["working_dir"])
+	assert.Equal(t, int64(0), fields["exit_code"])
+	assert.Contains(t, fields, "duration")
+}
+
+// TestExecuteLogExecutionsDisabled - Test that the entry is off by default
+func TestExecuteLogExecutionsDisabled(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	cmdExecutor, _ := newTestExecutor(t, nil, WithLogger(zap.New(core).Sugar()))
+
+	_, err := cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, 0, logs.FilterMessage("command executed").Len())
+}
+
+// TestSeparateAllowedDirs - Test distinct allowed dirs for cd and working_dir
+func TestSeparateAllowedDirs(t *testing.T) {
+	cdOnly, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	workdirOnly, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	cmdExecutor, shared := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.CdAllowedDirs = []string{cdOnly}
+		cfg.CommandExec.WorkdirAllowedDirs = []string{workdirOnly}
+	})
+
+	// cd follows cd_allowed_dirs
+	_, err = cmdExecutor.Execute("cd "+workdirOnly, Options{})
+	assert.Error(t, err)
+	_, err = cmdExecutor.Execute("cd "+cdOnly, Options{})
+	assert.NoError(t, err)
+
+	// working_dir follows workdir_allowed_dirs
+	_, err = cmdExecutor.Execute("ls", Options{WorkingDir: cdOnly})
+	assert.Error(t, err)
+	_, err = cmdExecutor.Execute("ls", Options{WorkingDir: workdirOnly})
+	assert.NoError(t, err)
+
+	// The shared list is no longer used for either
+	_, err = cmdExecutor.Execute("ls", Options{WorkingDir: shared})
+	assert.Error(t, err)
+}
+
+// TestExecuteStickyWorkingDir - Test that working_dir only persists with sticky_working_dir
+func TestExecuteStickyWorkingDir(t *testing.T) {
+	for _, sticky := range []bool{false, true} {
+		cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+			cfg.CommandExec.StickyWorkingDir = sticky
+		})
+		sub := filepath.Join(dir, "sub")
+		require.NoError(t, os.Mkdir(sub, 0755))
+
+		result, err := cmdExecutor.Execute("pwd", Options{WorkingDir: sub})
+		require.NoError(t, err)
+		assert.Equal(t, sub, result.Stdout)
+		assert.Equal(t, sticky, result.WorkingDirChanged)
+
+		// Later calls without working_dir run in the sticky directory
+		result, err = cmdExecutor.Execute("pwd", Options{})
+		require.NoError(t, err)
+		if sticky {
+			assert.Equal(t, sub, result.Stdout)
+			assert.Equal(t, sub, cmdExecutor.GetCurrentWorkingDir())
+		} else {
+			assert.Equal(t, dir, result.Stdout)
+			assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+		}
+	}
+}
+
+// TestExecuteStickyWorkingDirCdAllowedDirs - Test that a working_dir cd could not reach does not stick
+func TestExecuteStickyWorkingDirCdAllowedDirs(t *testing.T) {
+	workdirOnly, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.StickyWorkingDir = true
+		cfg.CommandExec.CdAllowedDirs = []string{cfg.CommandExec.DefaultWorkingDir}
+		cfg.CommandExec.WorkdirAllowedDirs = []string{workdirOnly}
+	})
+
+	result, err := cmdExecutor.Execute("pwd", Options{WorkingDir: workdirOnly})
+	require.NoError(t, err)
+	assert.Equal(t, workdirOnly, result.Stdout)
+	assert.False(t, result.WorkingDirChanged)
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+}
+
+// TestSeparateAllowedDirsFallback - Test falling back to allowed_dirs
+func TestSeparateAllowedDirsFallback(t *testing.T) {
+	cmdExecutor, shared := newTestExecutor(t, nil)
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+
+	_, err = cmdExecutor.Execute("ls", Options{WorkingDir: shared})
+	assert.NoError(t, err)
+	_, err = cmdExecutor.Execute("ls", Options{WorkingDir: outside})
+	assert.Error(t, err)
+	_, err = cmdExecutor.Execute("cd "+outside, Options{})
+	assert.Error(t, err)
+}
+
+// TestExecuteEnvBuiltin - Test the env built-in prints the redacted effective environment
+func TestExecuteEnvBuiltin(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.Environment = map[string]string{
+			"LANG":         "C",
+			"GITHUB_TOKEN": "ghp_secret",
+		}
+	})
+
+	result, err := cmdExecutor.Execute("env", Options{Env: map[string]string{
+		"DEBUG":       "1",
+		"DB_PASSWORD": "hunter2",
+		"LD_PRELOAD":  "/tmp/evil.so",
+	}})
+	require.NoError(t, err)
+
+	// Config and per-call vars appear
+	assert.Contains(t, result.Stdout, "LANG=C\n")
+	assert.Contains(t, result.Stdout, "DEBUG=1\n")
+
+	// Sensitive values are redacted
+	assert.Contains(t, result.Stdout, "GITHUB_TOKEN=[REDACTED]\n")
+	assert.Contains(t, result.Stdout, "DB_PASSWORD=[REDACTED]\n")
+	assert.NotContains(t, result.Stdout, "ghp_secret")
+	assert.NotContains(t, result.Stdout, "hunter2")
+
+	// Blocked vars are not part of the effective environment
+	assert.NotContains(t, result.Stdout, "/tmp/evil.so")
+
+	// env cannot be used to run other programs
+	_, err = cmdExecutor.Execute("env rm -rf /", Options{})
+	assert.Error(t, err)
+}
+
+// TestExecuteStdinNull - Test that commands reading stdin get EOF by default
+func TestExecuteStdinNull(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	// cat without arguments reads stdin and would block on a terminal
+	result, err := cmdExecutor.Execute("cat", Options{Stdin: "ignored"})
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Empty(t, result.Stdout)
+}
+
+// TestExecuteStdinProvided - Test passing stdin when stdin_mode is provided
+func TestExecuteStdinProvided(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.StdinMode = "provided"
+	})
+
+	result, err := cmdExecutor.Execute("cat", Options{Stdin: "from the caller\n"})
+	require.NoError(t, err)
+	assert.Equal(t, "from the caller\n", result.Stdout)
+
+	// No stdin provided still means EOF
+	result, err = cmdExecutor.Execute("cat", Options{})
+	require.NoError(t, err)
+	assert.Empty(t, result.Stdout)
+}
+
+// TestStdinModeInheritRejected - Test that commands cannot be given the MCP stdio transport as stdin
+func TestStdinModeInheritRejected(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.StdinMode = "inherit"
+
+	_, err := newCommandExecutor(cfg)
+	assert.EqualError(t, err, `stdin_mode "inherit" is not supported: the server's stdin is the MCP stdio transport`)
+}
+
+// TestExecuteMaxOutputBytes - Test the global and per-command output caps
+func TestExecuteMaxOutputBytes(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxOutputBytes = 5
+		cfg.CommandExec.CommandMaxOutput = map[string]int{"echo": 3}
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "log.txt"), []byte("0123456789"), 0644))
+
+	// Per-command override applies to echo
+	result, err := cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "hel", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	// Other commands use the global default
+	result, err = cmdExecutor.Execute("cat log.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "01234", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+	assert.False(t, result.StderrTruncated)
+}
+
+// TestExecuteCommandMaxOutputRaisesLimit - Test that a per-command override can exceed the global default
+func TestExecuteCommandMaxOutputRaisesLimit(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxOutputBytes = 2
+		cfg.CommandExec.CommandMaxOutput = map[string]int{"cat": 100}
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "log.txt"), []byte("0123456789"), 0644))
+
+	result, err := cmdExecutor.Execute("cat log.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", result.Stdout)
+	assert.False(t, result.StdoutTruncated)
+
+	result, err = cmdExecutor.Execute("ls log.txt", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "lo", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+}
+
+// TestExecuteTrimOutput - Test trimming trailing whitespace from output
+func TestExecuteTrimOutput(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello\n", result.Stdout)
+
+	result, err = cmdExecutor.Execute("sh", Options{
+		Args:       []string{"-c", "printf '  out \\n\\n'; printf 'err\\t\\n' >&2"},
+		TrimOutput: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "  out", result.Stdout)
+	assert.Equal(t, "err", result.Stderr)
+
+	// Real and built-in commands agree
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "pwd"}, TrimOutput: true})
+	require.NoError(t, err)
+	builtin, err := cmdExecutor.Execute("pwd", Options{TrimOutput: true})
+	require.NoError(t, err)
+	assert.Equal(t, builtin.Stdout, result.Stdout)
+	assert.Equal(t, dir, result.Stdout)
+}
+
+// TestExecuteTrimOutputConfig - Test enabling trimming for every command in the configuration
+func TestExecuteTrimOutputConfig(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.TrimOutput = true
+	})
+
+	result, err := cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Stdout)
+}
+
+// TestExecuteDispatchConsistency - Test that the current and specified directory paths behave the same
+func TestExecuteDispatchConsistency(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "env", "no-such-command")
+	})
+
+	tests := []struct {
+		name    string
+		command string
+		options Options
+	}{
+		{name: "pwd", command: "pwd"},
+		{name: "env", command: "env"},
+		{name: "env with arguments", command: "env ls"},
+		{name: "program", command: "echo hello  world"},
+		{name: "literal args", command: "echo", options: Options{Args: []string{"a  b"}}},
+		{name: "missing program", command: "no-such-command"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			current, currentErr := cmdExecutor.Execute(tt.command, tt.options)
+
+			options := tt.options
+			options.WorkingDir = dir
+			specified, specifiedErr := cmdExecutor.Execute(tt.command, options)
+
+			// Peak memory and the execution ID vary between runs
+			current.MaxRSSBytes, specified.MaxRSSBytes = 0, 0
+			current.ExecutionID, specified.ExecutionID = "", ""
+
+			assert.Equal(t, currentErr != nil, specifiedErr != nil)
+			assert.Equal(t, current, specified)
+		})
+	}
+}
+
+// TestExecuteCdWithWorkingDir - Test that cd is rejected with a per-call working directory
+func TestExecuteCdWithWorkingDir(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+	result, err := cmdExecutor.Execute("cd sub", Options{WorkingDir: dir})
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+}
+
+// TestIsDirectoryAllowedNormalization - Test that allowed_dirs match regardless of how paths are written
+func TestIsDirectoryAllowedNormalization(t *testing.T) {
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		allowedDir string
+		dir        string
+		allowed    bool
+	}{
+		{"trailing slash in config", "/srv/project/", "/srv/project", true},
+		{"trailing slash in candidate", "/srv/project", "/srv/project/sub/", true},
+		{"dot elements", "/srv/./project", "/srv/project/./sub", true},
+		{"dot-dot staying inside", "/srv/project", "/srv/project/a/../b", true},
+		{"dot-dot escaping", "/srv/project", "/srv/project/../other", false},
+		{"sibling sharing a prefix", "/srv/project", "/srv/project2", false},
+		{"relative config dir", ".", filepath.Join(cwd, "sub"), true},
+		{"root", "/", "/srv", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+				cfg.CommandExec.AllowedDirs = []string{tt.allowedDir}
+			})
+			assert.Equal(t, tt.allowed, cmdExecutor.IsDirectoryAllowed(tt.dir))
+		})
+	}
+}
+
+// TestExecuteConcurrentChangeDirectory - Test that cd and commands running concurrently don't race
+func TestExecuteConcurrentChangeDirectory(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	subA := filepath.Join(dir, "a")
+	subB := filepath.Join(dir, "b")
+	require.NoError(t, os.Mkdir(subA, 0755))
+	require.NoError(t, os.Mkdir(subB, 0755))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			target := subA
+			if i%2 == 1 {
+				target = subB
+			}
+			_, err := cmdExecutor.Execute("cd "+target, Options{})
+			assert.NoError(t, err)
+		}(i)
+		go func() {
+			defer wg.Done()
+			// Each command runs in one consistent directory and reports it
+			result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "pwd"}})
+			assert.NoError(t, err)
+			assert.Equal(t, result.WorkingDir+"\n", result.Stdout)
+		}()
+	}
+	wg.Wait()
+
+	assert.Contains(t, []string{subA, subB}, cmdExecutor.GetCurrentWorkingDir())
+}
+
+// TestEmptyAllowlistsDefaultDeny - Test empty allowlists with and without default_deny
+func TestEmptyAllowlistsDefaultDeny(t *testing.T) {
+	for _, defaultDeny := range []bool{true, false} {
+		cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+			cfg.CommandExec.DefaultDeny = defaultDeny
+			cfg.CommandExec.AllowedDirs = nil
+			cfg.CommandExec.AllowedCommands = nil
+		})
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0755))
+
+		// Empty directory allowlist
+		assert.Equal(t, !defaultDeny, cmdExecutor.IsDirectoryAllowed(dir))
+		_, err := cmdExecutor.Execute("echo hi", Options{WorkingDir: dir})
+		assert.Equal(t, defaultDeny, err != nil)
+		_, err = cmdExecutor.Execute("cd sub", Options{})
+		assert.Equal(t, defaultDeny, err != nil)
+
+		// An empty command allowlist never allows anything
+		assert.False(t, cmdExecutor.IsCommandAllowed("echo hi"))
+	}
+}
+
+// TestExecuteCommandWorkingDirs - Test per-command default working directories
+func TestExecuteCommandWorkingDirs(t *testing.T) {
+	var sub string
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		sub = filepath.Join(cfg.CommandExec.DefaultWorkingDir, "app")
+		cfg.CommandExec.CommandWorkingDirs = map[string]string{
+			"sh":  sub,
+			"cat": "/outside",
+		}
+	})
+	require.NoError(t, os.Mkdir(sub, 0755))
+
+	// Default applied
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "pwd"}})
+	require.NoError(t, err)
+	assert.Equal(t, sub+"\n", result.Stdout)
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+
+	// Explicit working_dir overrides the default
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "pwd"}, WorkingDir: dir})
+	require.NoError(t, err)
+	assert.Equal(t, dir+"\n", result.Stdout)
+
+	// Other commands keep using the current directory
+	result, err = cmdExecutor.Execute("pwd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, dir, result.Stdout)
+
+	// Defaults are still validated against the allowed directories
+	_, err = cmdExecutor.Execute("cat file", Options{})
+	assert.Error(t, err)
+}
+
+// TestExecuteLogMaxArgs - Test that large argument lists are truncated in logs
+func TestExecuteLogMaxArgs(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.LogMaxArgs = 3
+	}, WithLogger(zap.New(core).Sugar()))
+
+	args := make([]string, 100)
+	for i := range args {
+		args[i] = fmt.Sprintf("arg%d", i)
+	}
+
+	result, err := cmdExecutor.Execute("echo", Options{Args: args})
+	require.NoError(t, err)
+	assert.Equal(t, strings.Join(args, " ")+"\n", result.Stdout)
+
+	entries := logs.FilterMessage("executing command").AllUntimed()
+	require.Len(t, entries, 1)
+	assert.Equal(t, []interface{}{"arg0", "arg1", "arg2", "+97 more"}, entries[0].ContextMap()["args"])
+
+	// Short argument lists are logged as is
+	assert.Equal(t, []string{"a", "b"}, cmdExecutor.loggedArgs([]string{"a", "b"}))
+}
+
+// TestExecuteWorkingDirChanged - Test that cd reports the directory change
+func TestExecuteWorkingDirChanged(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	sub := filepath.Join(dir, "sub")
+	require.NoError(t, os.Mkdir(sub, 0755))
+
+	result, err := cmdExecutor.Execute("cd sub", Options{})
+	require.NoError(t, err)
+	assert.True(t, result.WorkingDirChanged)
+	assert.Equal(t, dir, result.PreviousWorkingDir)
+	assert.Equal(t, sub, result.WorkingDir)
+
+	// cd to the same directory is not a change
+	result, err = cmdExecutor.Execute("cd .", Options{})
+	require.NoError(t, err)
+	assert.False(t, result.WorkingDirChanged)
+
+	// Normal commands never change it
+	result, err = cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.False(t, result.WorkingDirChanged)
+	assert.Empty(t, result.PreviousWorkingDir)
+
+	// A failed cd leaves it false
+	result, err = cmdExecutor.Execute("cd missing", Options{})
+	assert.Error(t, err)
+	assert.False(t, result.WorkingDirChanged)
+}
+
+// TestExecuteTimeout - Test that a command past its timeout is killed with partial output kept
+func TestExecuteTimeout(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "echo partial; exec sleep 5"},
+		Timeout: 200 * time.Millisecond,
+	})
+	assert.EqualError(t, err, "command timed out after 200ms")
+	assert.Equal(t, 124, result.ExitCode)
+	assert.Equal(t, "command timed out after 200ms", result.Error)
+	assert.Equal(t, "partial\n", result.Stdout)
+
+	// The timeout is reported instead of the signal that carried it out
+	assert.False(t, result.TerminatedBySignal)
+	assert.Empty(t, result.Signal)
+	assert.False(t, result.MemoryLimitExceeded)
+
+	// The streamed exit event agrees
+	sink := newRecordingSink()
+	result, _ = cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "exec sleep 5"},
+		Timeout: 200 * time.Millisecond,
+		Stream:  sink,
+	})
+	require.Len(t, sink.exits, 1)
+	assert.Equal(t, 124, sink.exits[0].ExitCode)
+	assert.Empty(t, sink.exits[0].Signal)
+	assert.False(t, result.TerminatedBySignal)
+}
+
+// slowFinishClock is a real-time clock that stalls once, the first time it is
+// read after the file at path appears, like slow work after the command exits
+type slowFinishClock struct {
+	path  string
+	delay time.Duration
+	slept sync.Once
+}
+
+func (c *slowFinishClock) Now() time.Time {
+	if _, err := os.Stat(c.path); err == nil {
+		c.slept.Do(func() { time.Sleep(c.delay) })
+	}
+	return time.Now()
+}
+
+func (c *slowFinishClock) Sleep(d time.Duration) { time.Sleep(d) }
+
+// TestExecuteTimeoutAfterExit - Test that a command that exited in time is not reported as timed out
+func TestExecuteTimeoutAfterExit(t *testing.T) {
+	dir := t.TempDir()
+	clock := &slowFinishClock{path: filepath.Join(dir, "done"), delay: 400 * time.Millisecond}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.DefaultWorkingDir = dir
+		cfg.CommandExec.AllowedDirs = []string{dir}
+	}, WithClock(clock))
+
+	// The deadline passes while the executor is still busy after Wait
+	start := time.Now()
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "sleep 0.05; echo ok > done"},
+		Timeout: 200 * time.Millisecond,
+	})
+	require.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Empty(t, result.Error)
+}
+
+// TestExecuteDefaultTimeout - Test that timeout_seconds applies to calls without their own timeout
+func TestExecuteDefaultTimeout(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.TimeoutSeconds = 1
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "exec sleep 5"}})
+	assert.EqualError(t, err, "command timed out after 1s")
+	assert.Equal(t, 124, result.ExitCode)
+
+	// A per-call timeout takes precedence
+	result, err = cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "exec sleep 5"},
+		Timeout: 100 * time.Millisecond,
+	})
+	assert.EqualError(t, err, "command timed out after 100ms")
+	assert.Equal(t, 124, result.ExitCode)
+}
+
+// TestTruncateLines - Test keeping the first or last lines of output
+func TestTruncateLines(t *testing.T) {
+	tests := []struct {
+		input     string
+		limit     int
+		keep      string
+		want      string
+		truncated bool
+	}{
+		{"a\nb\nc\n", 2, keepHead, "a\nb\n", true},
+		{"a\nb\nc\n", 2, keepTail, "b\nc\n", true},
+		{"a\nb\nc", 2, keepHead, "a\nb\n", true},
+		{"a\nb\nc", 2, keepTail, "b\nc", true},
+		{"a\nb\nc", 1, keepTail, "c", true},
+		{"a\nb\n", 2, keepTail, "a\nb\n", false},
+		{"a\nb\nc\n", 0, keepHead, "a\nb\nc\n", false},
+		{"", 1, keepHead, "", false},
+	}
+	for _, tt := range tests {
+		got, truncated := truncateLines(tt.input, tt.limit, tt.keep)
+		assert.Equal(t, tt.want, got, "%q %d %s", tt.input, tt.limit, tt.keep)
+		assert.Equal(t, tt.truncated, truncated, "%q %d %s", tt.input, tt.limit, tt.keep)
+	}
+}
+
+// TestExecuteMaxOutputLines - Test line-based truncation from both ends alongside the byte cap
+func TestExecuteMaxOutputLines(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxOutputLines = 3
+	})
+	script := []string{"-c", "seq 1 10; seq 1 2 >&2"}
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: script})
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n3\n", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+	assert.Equal(t, "1\n2\n", result.Stderr)
+	assert.False(t, result.StderrTruncated)
+
+	// Per call: a different count and the last lines
+	result, err = cmdExecutor.Execute("sh", Options{Args: script, MaxOutputLines: 2, OutputLinesKeep: "tail"})
+	require.NoError(t, err)
+	assert.Equal(t, "9\n10\n", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	_, err = cmdExecutor.Execute("sh", Options{Args: script, OutputLinesKeep: "middle"})
+	assert.EqualError(t, err, "invalid output_lines_keep: middle")
+
+	// The byte cap still applies first when it is hit first
+	cmdExecutor.cfg.CommandExec.MaxOutputBytes = 4
+	result, err = cmdExecutor.Execute("sh", Options{Args: script})
+	require.NoError(t, err)
+	assert.Equal(t, "1\n2\n", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+}
+
+// TestExecuteSignalTermination - Test that a signal is reported apart from a normal exit code
+func TestExecuteSignalTermination(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	// A normal exit reports its code and no signal
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "exit 3"}})
+	require.Error(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.False(t, result.TerminatedBySignal)
+	assert.Empty(t, result.Signal)
+
+	// A process killed by a signal has no exit code of its own
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "kill -TERM $$"}})
+	require.Error(t, err)
+	assert.Equal(t, -1, result.ExitCode)
+	assert.True(t, result.TerminatedBySignal)
+	assert.Equal(t, "terminated", result.Signal)
+
+	jsonBytes, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.Contains(t, string(jsonBytes), `"exit_code":-1`)
+	assert.Contains(t, string(jsonBytes), `"signal":"terminated"`)
+	assert.Contains(t, string(jsonBytes), `"terminated_by_signal":true`)
+}
+
+// TestExecuteProfile - Test that the phase timings add up to the total when profiling
+func TestExecuteProfile(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("echo", Options{Args: []string{"hi"}})
+	require.NoError(t, err)
+	assert.Nil(t, result.Timings)
+
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "sleep 0.05"}, Profile: true})
+	require.NoError(t, err)
+	require.NotNil(t, result.Timings)
+
+	timings := result.Timings
+	assert.GreaterOrEqual(t, timings.RunUs, int64(50000))
+	sum := timings.ResolveUs + timings.ValidateUs + timings.StartUs + timings.RunUs
+	assert.InDelta(t, timings.TotalUs, sum, 10)
+}
@@ -0,0 +1,211 @@
+package executor
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/cockroachdb/errors"
+)
+
+// defaultDirDiffMaxFiles is the default maximum number of files DiffDirs reads from both trees
+const defaultDirDiffMaxFiles = 1000
+
+// errDiffTooManyFiles stops a walk once the file limit is reached
+var errDiffTooManyFiles = errors.New("too many files")
+
+// DirDiff lists the files that differ between two directories, by path relative to each
+type DirDiff struct {
+	From    string   `json:"from"`
+	To      string   `json:"to"`
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// diffEntry is what DiffDirs compares for one path. Symlinks are compared by
+// their target and never followed, so a link cannot read outside allowed_dirs.
+type diffEntry struct {
+	path    string
+	size    int64
+	symlink string
+	isLink  bool
+}
+
+// DiffDirs compares two allowed directories and returns the files only in to
+// (added), only in from (removed), and in both with different contents (changed)
+func (e *commandExecutor) DiffDirs(from, to string) (DirDiff, error) {
+	maxFiles := e.cfg.CommandExec.DirDiffMaxFiles
+	if maxFiles <= 0 {
+		maxFiles = defaultDirDiffMaxFiles
+	}
+
+	fromRoot, err := e.diffRoot(from)
+	if err != nil {
+		return DirDiff{}, err
+	}
+	toRoot, err := e.diffRoot(to)
+	if err != nil {
+		return DirDiff{}, err
+	}
+
+	// The limit covers both trees together
+	tooMany := errors.Newf("directories hold more than %d files (dir_diff_max_files)", maxFiles)
+	fromEntries, err := walkDiffTree(fromRoot, maxFiles)
+	if errors.Is(err, errDiffTooManyFiles) {
+		return DirDiff{}, tooMany
+	}
+	if err != nil {
+		return DirDiff{}, err
+	}
+	toEntries, err := walkDiffTree(toRoot, maxFiles-len(fromEntries))
+	if errors.Is(err, errDiffTooManyFiles) {
+		return DirDiff{}, tooMany
+	}
+	if err != nil {
+		return DirDiff{}, err
+	}
+
+	return compareDiffTrees(fromRoot, toRoot, fromEntries, toEntries)
+}
+
+// compareDiffTrees builds the diff of two walked trees
+func compareDiffTrees(fromRoot, toRoot string, fromEntries, toEntries map[string]diffEntry) (DirDiff, error) {
+	diff := DirDiff{
+		From:    fromRoot,
+		To:      toRoot,
+		Added:   []string{},
+		Removed: []string{},
+		Changed: []string{},
+	}
+	for rel, toEntry := range toEntries {
+		fromEntry, ok := fromEntries[rel]
+		if !ok {
+			diff.Added = append(diff.Added, rel)
+			continue
+		}
+		same, err := sameContents(fromEntry, toEntry)
+		if err != nil {
+			return DirDiff{}, errors.Wrapf(err, "failed to compare %s", rel)
+		}
+		if !same {
+			diff.Changed = append(diff.Changed, rel)
+		}
+	}
+	for rel := range fromEntries {
+		if _, ok := toEntries[rel]; !ok {
+			diff.Removed = append(diff.Removed, rel)
+		}
+	}
+
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+	sort.Strings(diff.Changed)
+	return diff, nil
+}
+
+// diffRoot resolves a DiffDirs argument, relative to the current directory,
+// and checks that it is an allowed directory
+func (e *commandExecutor) diffRoot(dir string) (string, error) {
+	if dir == "" {
+		return "", errors.New("directory must not be empty")
+	}
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(e.GetCurrentWorkingDir(), dir)
+	}
+	dir = filepath.Clean(dir)
+
+	// Check where the path really is, so a symlink cannot point outside allowed_dirs
+	resolved, err := e.fs.EvalSymlinks(dir)
+	if err != nil {
+		return "", errors.Newf("cannot access %s: no such file or directory", dir)
+	}
+	if !e.IsDirectoryAllowed(resolved) {
+		e.logger.Warnw("dir_diff outside allowed directories",
+			"path", dir)
+		return "", errors.Newf("Access to directory not allowed: %s", dir)
+	}
+
+	info, err := e.fs.Stat(resolved)
+	if err != nil {
+		return "", errors.Wrapf(err, "cannot access %s", dir)
+	}
+	if !info.IsDir() {
+		return "", errors.Newf("not a directory: %s", dir)
+	}
+
+	return resolved, nil
+}
+
+// walkDiffTree records the files and symlinks under root, failing once there
+// are more than maxFiles rather than returning a partial and misleading diff
+func walkDiffTree(root string, maxFiles int) (map[string]diffEntry, error) {
+	entries := make(map[string]diffEntry)
+
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		if len(entries) >= maxFiles {
+			return errDiffTooManyFiles
+		}
+
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+
+		entry := diffEntry{path: path}
+		switch {
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			entry.isLink = true
+			entry.symlink = target
+		case d.Type().IsRegular():
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entry.size = info.Size()
+		default:
+			// Sockets, devices, and pipes have no contents to compare
+			return nil
+		}
+
+		entries[rel] = entry
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return entries, nil
+}
+
+// sameContents reports whether two entries have the same contents, hashing
+// the files only when their sizes match
+func sameContents(a, b diffEntry) (bool, error) {
+	if a.isLink || b.isLink {
+		return a.isLink == b.isLink && a.symlink == b.symlink, nil
+	}
+	if a.size != b.size {
+		return false, nil
+	}
+
+	aSum, err := fileSHA256(a.path)
+	if err != nil {
+		return false, err
+	}
+	bSum, err := fileSHA256(b.path)
+	if err != nil {
+		return false, err
+	}
+	return aSum == bSum, nil
+}
@@ -0,0 +1,85 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTree creates the files under root, keyed by relative path
+func writeTree(t *testing.T, root string, files map[string]string) {
+	t.Helper()
+	for rel, contents := range files {
+		path := filepath.Join(root, rel)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0644))
+	}
+}
+
+// TestDiffDirs - Test that two trees with known differences are diffed by content
+func TestDiffDirs(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+
+	writeTree(t, filepath.Join(dir, "old"), map[string]string{
+		"same.txt":        "unchanged",
+		"edited.txt":      "before",
+		"resized.txt":     "short",
+		"gone.txt":        "removed",
+		"nested/keep.txt": "nested",
+	})
+	writeTree(t, filepath.Join(dir, "new"), map[string]string{
+		"same.txt":        "unchanged",
+		"edited.txt":      "after!",
+		"resized.txt":     "much longer",
+		"nested/keep.txt": "nested",
+		"nested/new.txt":  "added",
+	})
+	require.NoError(t, os.Symlink("same.txt", filepath.Join(dir, "old", "link")))
+	require.NoError(t, os.Symlink("edited.txt", filepath.Join(dir, "new", "link")))
+
+	// Relative paths are resolved against the current directory
+	diff, err := cmdExecutor.DiffDirs("old", filepath.Join(dir, "new"))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "old"), diff.From)
+	assert.Equal(t, []string{"nested/new.txt"}, diff.Added)
+	assert.Equal(t, []string{"gone.txt"}, diff.Removed)
+	assert.Equal(t, []string{"edited.txt", "link", "resized.txt"}, diff.Changed)
+
+	// Identical trees have no differences
+	diff, err = cmdExecutor.DiffDirs("new", "new")
+	require.NoError(t, err)
+	assert.Empty(t, diff.Added)
+	assert.Empty(t, diff.Removed)
+	assert.Empty(t, diff.Changed)
+}
+
+// TestDiffDirsLimits - Test that DiffDirs refuses disallowed directories and oversized trees
+func TestDiffDirsLimits(t *testing.T) {
+	outside := t.TempDir()
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.DirDiffMaxFiles = 3
+	})
+	writeTree(t, filepath.Join(dir, "a"), map[string]string{"1": "", "2": ""})
+	writeTree(t, filepath.Join(dir, "b"), map[string]string{"1": "", "2": ""})
+
+	_, err := cmdExecutor.DiffDirs("a", outside)
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+
+	// Symlinks are checked by their target
+	require.NoError(t, os.Symlink(outside, filepath.Join(dir, "link")))
+	_, err = cmdExecutor.DiffDirs("link", "a")
+	assert.ErrorContains(t, err, "Access to directory not allowed")
+
+	_, err = cmdExecutor.DiffDirs("a", "missing")
+	assert.ErrorContains(t, err, "no such file or directory")
+	_, err = cmdExecutor.DiffDirs("a", "a/1")
+	assert.ErrorContains(t, err, "not a directory")
+
+	// The limit covers both trees together
+	_, err = cmdExecutor.DiffDirs("a", "b")
+	assert.ErrorContains(t, err, "more than 3 files")
+}
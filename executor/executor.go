@@ -1,6 +1,9 @@
 package executor
 
 import (
+	"context"
+	"time"
+
 	"github.com/cnosuke/mcp-command-exec/config"
 	"github.com/cnosuke/mcp-command-exec/types"
 )
@@ -13,14 +16,50 @@ type CommandExecutor interface {
 	// IsCommandAllowed checks if the command is in the allowed list
 	IsCommandAllowed(command string) bool
 
+	// Validate runs the custom validator, if one is configured
+	Validate(ctx context.Context, command string, options Options) error
+
 	// GetAllowedCommands returns the list of allowed commands
 	GetAllowedCommands() []string
 
+	// AllowCommand adds a program to the allowed list at runtime
+	AllowCommand(name string) bool
+
+	// DisallowCommand removes a program from the allowed list at runtime
+	DisallowCommand(name string) bool
+
 	// GetCurrentWorkingDir returns the current working directory
 	GetCurrentWorkingDir() string
 
+	// ChangeDirectoryTarget returns the directory the cd command would move to, without moving
+	ChangeDirectoryTarget(command string, env map[string]string) (string, error)
+
 	// IsDirectoryAllowed checks if directory access is allowed
 	IsDirectoryAllowed(dir string) bool
+
+	// IsBuiltinCommand reports whether the command's program is handled by the executor itself
+	IsBuiltinCommand(command string) bool
+
+	// ResolveBinaryPath resolves the absolute path of the command's program
+	ResolveBinaryPath(command string) (string, error)
+
+	// ListProcesses returns the commands whose processes are currently running
+	ListProcesses() []RunningProcess
+
+	// KillProcess sends the configured kill signal to a running process
+	KillProcess(id string) error
+
+	// LastResult returns the most recent result, optionally filtered by label and command
+	LastResult(label string, command string) (types.CommandResult, bool)
+
+	// ExportHistory writes the recent results to a file as JSON lines
+	ExportHistory(path string) error
+
+	// DiffDirs compares the files in two allowed directories by content
+	DiffDirs(from, to string) (DirDiff, error)
+
+	// CloseShells stops the persistent shells of all sessions
+	CloseShells()
 }
 
 // Options are options for command execution
@@ -30,9 +69,96 @@ type Options struct {
 
 	// Env are environment variables for command execution
 	Env map[string]string
+
+	// ScratchDir runs the command in a new directory under scratch_root, removed
+	// afterwards unless KeepScratch is set
+	ScratchDir  bool
+	KeepScratch bool
+
+	// TeeFile is a file that also receives the command output
+	TeeFile string
+
+	// Args, when set, are passed to the program verbatim instead of splitting the command
+	Args []string
+
+	// Stream, when set, receives output as it is produced and a final exit event
+	Stream StreamSink
+
+	// Stdin is passed to the command when stdin_mode is "provided"
+	Stdin string
+
+	// TrimOutput removes trailing whitespace from stdout and stderr
+	TrimOutput bool
+
+	// DiscardStdout and DiscardStderr drop the stream instead of capturing it,
+	// for callers that only need the exit code or one of the streams
+	DiscardStdout bool
+	DiscardStderr bool
+
+	// MaxOutputLines, when positive, overrides max_output_lines for this call,
+	// and OutputLinesKeep ("head" or "tail") overrides output_lines_keep
+	MaxOutputLines  int
+	OutputLinesKeep string
+
+	// MergeOrder, when set, also returns both streams in one combined output:
+	// "interleaved" in the order written, or "stdout_first"/"stderr_first"
+	MergeOrder string
+
+	// EchoCommand prepends a "$ <command>" line to stdout, like a shell session log
+	EchoCommand bool
+
+	// SecretRefs maps environment variable names to secret references resolved
+	// by the configured SecretResolver. Values are never logged and are
+	// redacted from the returned output.
+	SecretRefs map[string]string
+
+	// ParseJSON attaches stdout as structured data when the command succeeds
+	// and its stdout is valid JSON
+	ParseJSON bool
+
+	// HexDump returns stdout and stderr as hex+ASCII dumps, for inspecting binary output
+	HexDump bool
+
+	// Profile adds the time spent resolving, validating, starting, and running the command to the result
+	Profile bool
+
+	// Explain adds diagnostics on how the command was resolved (binary and PATH) to the result
+	Explain bool
+
+	// PersistentShell runs the command in the session's long-lived bash process,
+	// so shell state carries over between calls (requires persistent_shell)
+	PersistentShell bool
+
+	// Label tags the result so it can be looked up with LastResult
+	Label string
+
+	// RawCommand is the command exactly as the caller sent it, before template
+	// expansion, normalization, and aliases; defaults to the command passed to Execute
+	RawCommand string
+
+	// ExecutionID identifies this execution in logs and the result; generated if empty
+	ExecutionID string
+
+	// SessionID identifies the client session the command runs for, shown by ListProcesses
+	SessionID string
+
+	// SessionPolicyVersion identifies the session's own policy, if any, and is
+	// folded into the result's PolicyVersion
+	SessionPolicyVersion string
+
+	// Timeout kills the command after the duration; zero means no timeout
+	Timeout time.Duration
+
+	// configuredTimeout is the Timeout the caller asked for, reported in the
+	// timeout error when a retry runs with only what is left of it
+	configuredTimeout time.Duration
+
+	// secrets are the resolved SecretRefs, redacted from the tee file
+	secrets map[string]string
 }
 
 // NewCommandExecutor creates a new instance of CommandExecutor
-func NewCommandExecutor(config *config.Config) (CommandExecutor, error) {
-	return newCommandExecutor(config)
+// A nil config is accepted when the executor is configured through options
+func NewCommandExecutor(config *config.Config, opts ...Option) (CommandExecutor, error) {
+	return newCommandExecutor(config, opts...)
 }
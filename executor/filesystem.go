@@ -0,0 +1,78 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// FileSystem abstracts the filesystem operations used by the executor
+type FileSystem interface {
+	// Stat returns the FileInfo describing the named file
+	Stat(name string) (os.FileInfo, error)
+
+	// EvalSymlinks returns the path name after evaluating any symbolic links
+	EvalSymlinks(path string) (string, error)
+}
+
+// osFileSystem implements FileSystem using the os package
+type osFileSystem struct{}
+
+// Stat calls os.Stat
+func (osFileSystem) Stat(name string) (os.FileInfo, error) {
+	return os.Stat(name)
+}
+
+// EvalSymlinks calls filepath.EvalSymlinks
+func (osFileSystem) EvalSymlinks(path string) (string, error) {
+	return filepath.EvalSymlinks(path)
+}
+
+// withResolveTimeout runs fn, a filesystem lookup, giving up after
+// resolve_timeout_ms so a slow filesystem cannot stall the executor. A lookup
+// that times out keeps running in the background until the filesystem returns.
+func (e *commandExecutor) withResolveTimeout(what string, fn func() error) error {
+	timeout := time.Duration(e.cfg.CommandExec.ResolveTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		return fn()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- fn()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(timeout):
+		e.logger.Warnw("filesystem lookup timed out",
+			"lookup", what,
+			"timeout", timeout)
+		return errors.Newf("%s timed out after %s", what, timeout)
+	}
+}
+
+// Clock abstracts the current time and waiting for the executor
+type Clock interface {
+	// Now returns the current time
+	Now() time.Time
+
+	// Sleep pauses for at least the duration d
+	Sleep(d time.Duration)
+}
+
+// realClock implements Clock using the time package
+type realClock struct{}
+
+// Now calls time.Now
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Sleep calls time.Sleep
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
@@ -0,0 +1,28 @@
+package executor
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// hexDump formats data as a canonical hex+ASCII dump, like hexdump -C without
+// collapsing repeated lines. With a limit above zero the dump is cut to the
+// whole lines that fit, reporting whether anything was dropped.
+func hexDump(data string, limit int) (string, bool) {
+	if data == "" {
+		return "", false
+	}
+
+	// The dump is larger than its input, so no more input than the limit can fit
+	input := data
+	if limit > 0 && len(input) > limit {
+		input = input[:limit]
+	}
+
+	dump := hex.Dump([]byte(input)) + fmt.Sprintf("%08x\n", len(data))
+	if limit <= 0 || len(dump) <= limit {
+		return dump, false
+	}
+	return dump[:strings.LastIndexByte(dump[:limit], '\n')+1], true
+}
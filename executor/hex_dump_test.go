@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// knownHexDump is the output of hexdump -C for "\x7fELF\x00\x01hello, world!\xff\n"
+const knownHexDump = "00000000  7f 45 4c 46 00 01 68 65  6c 6c 6f 2c 20 77 6f 72  |.ELF..hello, wor|\n" +
+	"00000010  6c 64 21 ff 0a                                    |ld!..|\n" +
+	"00000015\n"
+
+// TestHexDump - Test the dump format against hexdump -C and its truncation to whole lines
+func TestHexDump(t *testing.T) {
+	data := "\x7fELF\x00\x01hello, world!\xff\n"
+
+	dump, truncated := hexDump(data, 0)
+	assert.Equal(t, knownHexDump, dump)
+	assert.False(t, truncated)
+
+	dump, truncated = hexDump(data, 100)
+	assert.Equal(t, knownHexDump[:79], dump)
+	assert.True(t, truncated)
+
+	dump, truncated = hexDump("", 0)
+	assert.Empty(t, dump)
+	assert.False(t, truncated)
+}
+
+// TestExecuteHexDump - Test that hex_dump shows command output as bytes
+func TestExecuteHexDump(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.TrimOutput = true
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", `printf '\177ELF\000\001hello, world!\377\n'`},
+		HexDump: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, knownHexDump, result.Stdout)
+	assert.Empty(t, result.Stderr)
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+)
+
+// envAssignmentPattern matches KEY=VALUE pairs, as printed by env or passed on a command line
+var envAssignmentPattern = regexp.MustCompile(`\b([A-Za-z_][A-Za-z0-9_]*)=(\S+)`)
+
+// defaultHistorySize is the number of results kept when history_size is not set
+const defaultHistorySize = 20
+
+// resultHistory keeps the most recent command results, oldest first
+type resultHistory struct {
+	mu      sync.Mutex
+	size    int
+	results []types.CommandResult
+}
+
+// add records a result, dropping the oldest once the history is full
+func (h *resultHistory) add(result types.CommandResult) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	size := h.size
+	if size <= 0 {
+		size = defaultHistorySize
+	}
+
+	h.results = append(h.results, result)
+	if len(h.results) > size {
+		h.results = h.results[len(h.results)-size:]
+	}
+}
+
+// last returns the newest result matching the label and command, either of
+// which may be empty to match anything
+func (h *resultHistory) last(label string, command string) (types.CommandResult, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for i := len(h.results) - 1; i >= 0; i-- {
+		result := h.results[i]
+		if label != "" && result.Label != label {
+			continue
+		}
+		if command != "" && !commandMatches(result.Command, command) {
+			continue
+		}
+		return result, true
+	}
+	return types.CommandResult{}, false
+}
+
+// snapshot returns a copy of the results, oldest first
+func (h *resultHistory) snapshot() []types.CommandResult {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]types.CommandResult(nil), h.results...)
+}
+
+// commandMatches reports whether the executed command is the given command
+// line, or runs the given program
+func commandMatches(executed string, command string) bool {
+	if executed == command {
+		return true
+	}
+	parts := strings.Fields(executed)
+	return len(parts) > 0 && parts[0] == command
+}
+
+// LastResult returns the most recent result, optionally filtered by label and
+// command, so callers can fetch earlier output without re-running the command
+func (e *commandExecutor) LastResult(label string, command string) (types.CommandResult, bool) {
+	return e.history.last(label, command)
+}
+
+// ExportHistory writes the history to path as JSON lines, oldest first, with
+// values of sensitive-looking variables redacted. The file is replaced
+// atomically, so readers never see a partial export.
+func (e *commandExecutor) ExportHistory(path string) error {
+	results := e.history.snapshot()
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return errors.Wrap(err, "failed to create history export file")
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		if err := encoder.Encode(redactHistoryResult(result)); err != nil {
+			return errors.Wrap(err, "failed to write command history")
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return errors.Wrap(err, "failed to write command history")
+	}
+	if err := tmp.Sync(); err != nil {
+		return errors.Wrap(err, "failed to write command history")
+	}
+	if err := tmp.Close(); err != nil {
+		return errors.Wrap(err, "failed to write command history")
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return errors.Wrap(err, "failed to replace history export file")
+	}
+
+	e.logger.Infow("exported command history",
+		"path", path,
+		"results", len(results))
+	return nil
+}
+
+// redactHistoryResult redacts the values of sensitive-looking KEY=VALUE pairs
+// in the result's command and output
+func redactHistoryResult(result types.CommandResult) types.CommandResult {
+	result.Command = redactSensitiveAssignments(result.Command)
+	result.RawCommand = redactSensitiveAssignments(result.RawCommand)
+	result.Stdout = redactSensitiveAssignments(result.Stdout)
+	result.Stderr = redactSensitiveAssignments(result.Stderr)
+	result.Combined = redactSensitiveAssignments(result.Combined)
+	result.Error = redactSensitiveAssignments(result.Error)
+	return result
+}
+
+// redactSensitiveAssignments replaces the values of KEY=VALUE pairs whose key looks sensitive
+func redactSensitiveAssignments(s string) string {
+	return envAssignmentPattern.ReplaceAllStringFunc(s, func(match string) string {
+		key, _, _ := strings.Cut(match, "=")
+		if !isSensitiveEnvKey(key) {
+			return match
+		}
+		return key + "=" + redactedValue
+	})
+}
@@ -0,0 +1,49 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLastResult - Test fetching recent results by label and command
+func TestLastResult(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.HistorySize = 3
+	})
+
+	_, ok := cmdExecutor.LastResult("", "")
+	assert.False(t, ok)
+
+	_, err := cmdExecutor.Execute("echo first", Options{Label: "build"})
+	require.NoError(t, err)
+	_, err = cmdExecutor.Execute("ls", Options{})
+	require.NoError(t, err)
+	_, err = cmdExecutor.Execute("echo second", Options{})
+	require.NoError(t, err)
+
+	result, ok := cmdExecutor.LastResult("", "")
+	require.True(t, ok)
+	assert.Equal(t, "second\n", result.Stdout)
+
+	result, ok = cmdExecutor.LastResult("build", "")
+	require.True(t, ok)
+	assert.Equal(t, "first\n", result.Stdout)
+	assert.Equal(t, "build", result.Label)
+
+	// Commands match by full command line or program name
+	result, ok = cmdExecutor.LastResult("", "ls")
+	require.True(t, ok)
+	assert.Equal(t, "ls", result.Command)
+	result, ok = cmdExecutor.LastResult("", "echo first")
+	require.True(t, ok)
+	assert.Equal(t, "first\n", result.Stdout)
+
+	// Older results fall out once the history is full
+	_, err = cmdExecutor.Execute("echo third", Options{})
+	require.NoError(t, err)
+	_, ok = cmdExecutor.LastResult("build", "")
+	assert.False(t, ok)
+}
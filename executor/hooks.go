@@ -0,0 +1,17 @@
+package executor
+
+import (
+	"github.com/cnosuke/mcp-command-exec/types"
+)
+
+// ResultHook post-processes every command result before it is returned, e.g.
+// to scrub output, add metadata, or enforce a size policy. Hooks run in the
+// order they were registered, after success is determined.
+type ResultHook func(result *types.CommandResult)
+
+// ScrubSecretsHook is a ResultHook that replaces the values of KEY=VALUE pairs
+// whose key looks sensitive (TOKEN, SECRET, PASSWORD, ...) in the command,
+// output, and error
+func ScrubSecretsHook(result *types.CommandResult) {
+	*result = redactHistoryResult(*result)
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// validateLoginShell checks the login_shell setting. The shell loads the
+// user's rc files, so it must be named by an absolute path rather than found
+// on a PATH those files could change.
+func (e *commandExecutor) validateLoginShell() error {
+	shell := e.cfg.CommandExec.LoginShell
+	if shell == "" {
+		return nil
+	}
+
+	if !filepath.IsAbs(shell) {
+		return errors.Newf("login_shell must be an absolute path: %s", shell)
+	}
+	info, err := e.fs.Stat(shell)
+	if err != nil {
+		return errors.Wrap(err, "invalid login_shell")
+	}
+	if info.IsDir() || !isExecutable(info) {
+		return errors.Newf("login_shell is not executable: %s", shell)
+	}
+
+	e.logger.Warnw("commands run through a login shell and load its rc files",
+		"login_shell", shell)
+	return nil
+}
+
+// loginShellArgs returns the arguments that make the login shell run parts.
+// Every word is quoted, so the shell only expands its rc files, never the command.
+func loginShellArgs(parts []string) []string {
+	quoted := make([]string, len(parts))
+	for i, part := range parts {
+		quoted[i] = shellQuote(part)
+	}
+	return []string{"-lc", "exec " + strings.Join(quoted, " ")}
+}
+
+// shellQuote quotes s as a single literal word for POSIX shells
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,54 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteLoginShell - Test that variables set by rc files reach commands run through the login shell
+func TestExecuteLoginShell(t *testing.T) {
+	const bash = "/bin/bash"
+	if _, err := os.Stat(bash); err != nil {
+		t.Skip("bash is not available")
+	}
+
+	home := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(home, ".bash_profile"),
+		[]byte("export RC_LOADED=from-rc\n"), 0644))
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.LoginShell = bash
+		cfg.CommandExec.Environment = map[string]string{"HOME": home}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo $RC_LOADED"}})
+	require.NoError(t, err)
+	assert.Equal(t, "from-rc\n", result.Stdout)
+
+	// Arguments are passed literally, not expanded by the login shell
+	result, err = cmdExecutor.Execute("echo", Options{Args: []string{"$RC_LOADED", "it's", "$(id)", "a;b"}})
+	require.NoError(t, err)
+	assert.Equal(t, "$RC_LOADED it's $(id) a;b\n", result.Stdout)
+
+	// The leading program is still validated
+	assert.False(t, cmdExecutor.IsCommandAllowed("env"))
+}
+
+// TestInvalidLoginShell - Test that login_shell must name an executable by absolute path
+func TestInvalidLoginShell(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cfg.CommandExec.LoginShell = "bash"
+	_, err := newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "login_shell must be an absolute path")
+
+	cfg.CommandExec.LoginShell = "/nonexistent/bash"
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid login_shell")
+}
@@ -0,0 +1,43 @@
+package executor
+
+import (
+	"strings"
+	"syscall"
+)
+
+// memoryLimitSignals are the signals a command typically dies of when an
+// allocation fails under max_memory_bytes. RLIMIT_AS makes allocations fail
+// rather than getting the process killed, so SIGKILL is not among them.
+var memoryLimitSignals = map[string]bool{
+	syscall.SIGSEGV.String(): true,
+	syscall.SIGABRT.String(): true,
+	syscall.SIGBUS.String():  true,
+}
+
+// memoryLimitMarkers are what common runtimes print to stderr, lowercased,
+// when an allocation fails and they exit instead of crashing
+var memoryLimitMarkers = []string{
+	"out of memory",
+	"cannot allocate memory",
+	"memoryerror",
+	"bad_alloc",
+}
+
+// exceededMemoryLimit reports whether a failed command most likely ran out of
+// max_memory_bytes, judging by the signal that ended it or its last words
+func (e *commandExecutor) exceededMemoryLimit(signal string, stderr string) bool {
+	if e.cfg.CommandExec.MaxMemoryBytes <= 0 {
+		return false
+	}
+	if memoryLimitSignals[signal] {
+		return true
+	}
+
+	lower := strings.ToLower(stderr)
+	for _, marker := range memoryLimitMarkers {
+		if strings.Contains(lower, marker) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,37 @@
+//go:build linux
+
+package executor
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/cockroachdb/errors"
+)
+
+// validateMemoryLimit checks the max_memory_bytes setting at startup
+func (e *commandExecutor) validateMemoryLimit() error {
+	if e.cfg.CommandExec.MaxMemoryBytes < 0 {
+		return errors.Newf("max_memory_bytes must not be negative: %d", e.cfg.CommandExec.MaxMemoryBytes)
+	}
+	return nil
+}
+
+// limitMemory caps the address space of a started process at max_memory_bytes.
+// exec offers no way to set rlimits in the child before it runs, so this is
+// best-effort: the limit takes effect just after the program starts, and what
+// it allocates before then is not held to it.
+func (e *commandExecutor) limitMemory(pid int) error {
+	limit := e.cfg.CommandExec.MaxMemoryBytes
+	if limit <= 0 {
+		return nil
+	}
+
+	rlimit := syscall.Rlimit{Cur: uint64(limit), Max: uint64(limit)}
+	_, _, errno := syscall.RawSyscall6(syscall.SYS_PRLIMIT64,
+		uintptr(pid), uintptr(syscall.RLIMIT_AS), uintptr(unsafe.Pointer(&rlimit)), 0, 0, 0)
+	if errno != 0 {
+		return errors.Wrap(errno, "failed to apply max_memory_bytes")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+//go:build linux
+
+package executor
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteMaxMemoryBytes - Test that a command exceeding max_memory_bytes is reported as such
+func TestExecuteMaxMemoryBytes(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"python3"}
+		cfg.CommandExec.MaxMemoryBytes = 256 << 20
+	})
+
+	// Small allocations still run
+	result, err := cmdExecutor.Execute("python3", Options{Args: []string{"-c", "x = bytearray(1 << 20); print('ok')"}})
+	require.NoError(t, err)
+	assert.Equal(t, "ok\n", result.Stdout)
+	assert.False(t, result.MemoryLimitExceeded)
+
+	result, err = cmdExecutor.Execute("python3", Options{Args: []string{"-c", "x = bytearray(1 << 30)"}})
+	require.Error(t, err)
+	assert.EqualError(t, err, "killed: exceeded memory limit (268435456 bytes)")
+	assert.Equal(t, err.Error(), result.Error)
+	assert.True(t, result.MemoryLimitExceeded)
+
+	// Other failures are not blamed on the limit
+	result, err = cmdExecutor.Execute("python3", Options{Args: []string{"-c", "raise SystemExit(3)"}})
+	require.Error(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+	assert.False(t, result.MemoryLimitExceeded)
+}
+
+// TestExecuteMaxMemoryBytesExecutorKills - Test that kills the executor causes are not blamed on max_memory_bytes
+func TestExecuteMaxMemoryBytesExecutorKills(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxMemoryBytes = 256 << 20
+		cfg.CommandExec.KillSignal = "SIGKILL"
+	})
+	script := []string{"-c", "echo 'fatal: out of memory' >&2; exec sleep 5"}
+
+	// Killed by its timeout
+	result, err := cmdExecutor.Execute("sh", Options{Args: script, Timeout: 200 * time.Millisecond})
+	assert.EqualError(t, err, "command timed out after 200ms")
+	assert.False(t, result.MemoryLimitExceeded)
+
+	// Killed through kill_process
+	proc, done := startTracked(t, cmdExecutor, "sh", Options{Args: script})
+	require.NoError(t, cmdExecutor.KillProcess(proc.ID))
+	select {
+	case result = <-done:
+		assert.Equal(t, "signal: killed", result.Error)
+		assert.False(t, result.MemoryLimitExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed command did not return")
+	}
+}
+
+// TestExecuteMaxMemoryBytesApplied - Test that the limit is in effect once the command has started
+func TestExecuteMaxMemoryBytesApplied(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxMemoryBytes = 256 << 20
+	})
+
+	// The limit is applied just after start (best-effort), so read it a moment later
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "sleep 0.2; cat /proc/self/limits"}})
+	require.NoError(t, err)
+	assert.Regexp(t, `Max address space\s+268435456\s+268435456`, result.Stdout)
+}
+
+// TestMaxMemoryBytesValidation - Test that a negative max_memory_bytes fails startup
+func TestMaxMemoryBytesValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.MaxMemoryBytes = -1
+	_, err := newCommandExecutor(cfg)
+	assert.EqualError(t, err, "max_memory_bytes must not be negative: -1")
+}
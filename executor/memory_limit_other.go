@@ -0,0 +1,20 @@
+//go:build !linux
+
+package executor
+
+import (
+	"github.com/cockroachdb/errors"
+)
+
+// validateMemoryLimit rejects max_memory_bytes, which requires Linux
+func (e *commandExecutor) validateMemoryLimit() error {
+	if e.cfg.CommandExec.MaxMemoryBytes != 0 {
+		return errors.New("max_memory_bytes is only supported on Linux")
+	}
+	return nil
+}
+
+// limitMemory does nothing outside Linux
+func (e *commandExecutor) limitMemory(pid int) error {
+	return nil
+}
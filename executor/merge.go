@@ -0,0 +1,53 @@
+package executor
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Merge orders for the combined output
+const (
+	mergeInterleaved = "interleaved"
+	mergeStdoutFirst = "stdout_first"
+	mergeStderrFirst = "stderr_first"
+)
+
+// lockedWriter serializes writes from the stdout and stderr copiers into one buffer
+type lockedWriter struct {
+	mu  sync.Mutex
+	buf *limitedBuffer
+}
+
+// Write implements io.Writer
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+// mergeOrder returns how to build the combined output for a command: the
+// per-call order if given, otherwise the command's command_merge_order entry.
+// An empty order means no combined output.
+func (e *commandExecutor) mergeOrder(program string, requested string) (string, error) {
+	order := requested
+	if order == "" {
+		order = e.cfg.CommandExec.CommandMergeOrder[filepath.Base(program)]
+	}
+
+	switch order {
+	case "", mergeInterleaved, mergeStdoutFirst, mergeStderrFirst:
+		return order, nil
+	default:
+		return "", errors.Newf("invalid merge_order: %s (use interleaved, stdout_first, or stderr_first)", order)
+	}
+}
+
+// combineOutput concatenates the separate captures in the given order
+func combineOutput(order string, stdout string, stderr string) string {
+	if order == mergeStderrFirst {
+		return stderr + stdout
+	}
+	return stdout + stderr
+}
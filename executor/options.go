@@ -0,0 +1,66 @@
+package executor
+
+import (
+	"go.uber.org/zap"
+)
+
+// Option configures a CommandExecutor at construction time
+type Option func(*commandExecutor)
+
+// WithAllowedCommands overrides the allowed command list from the config
+func WithAllowedCommands(commands ...string) Option {
+	return func(e *commandExecutor) {
+		e.allowedCommands = commands
+	}
+}
+
+// WithLogger sets the logger used by the executor (defaults to the global logger)
+func WithLogger(logger *zap.SugaredLogger) Option {
+	return func(e *commandExecutor) {
+		e.logger = logger
+	}
+}
+
+// WithValidator sets a custom validator consulted after the allowlist check
+func WithValidator(validator CommandValidator) Option {
+	return func(e *commandExecutor) {
+		e.validator = validator
+	}
+}
+
+// WithFileSystem sets the filesystem used for directory and binary checks
+func WithFileSystem(fs FileSystem) Option {
+	return func(e *commandExecutor) {
+		e.fs = fs
+	}
+}
+
+// WithClock sets the clock used to measure execution time
+func WithClock(clock Clock) Option {
+	return func(e *commandExecutor) {
+		e.clock = clock
+	}
+}
+
+// WithRandom sets the source of the random fractions in [0, 1) that retry_jitter
+// takes off each retry delay (defaults to math/rand)
+func WithRandom(random func() float64) Option {
+	return func(e *commandExecutor) {
+		e.random = random
+	}
+}
+
+// WithSecretResolver sets the source used to resolve secret_refs (defaults to
+// a file resolver over secrets_dir when it is configured)
+func WithSecretResolver(resolver SecretResolver) Option {
+	return func(e *commandExecutor) {
+		e.secretResolver = resolver
+	}
+}
+
+// WithResultHook registers hooks that post-process every result before it is returned
+func WithResultHook(hooks ...ResultHook) Option {
+	return func(e *commandExecutor) {
+		e.resultHooks = append(e.resultHooks, hooks...)
+	}
+}
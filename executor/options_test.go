@@ -0,0 +1,186 @@
+package executor
+
+import (
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// fakeFileInfo - Minimal os.FileInfo for directories that only exist in tests
+type fakeFileInfo struct {
+	name string
+}
+
+func (f fakeFileInfo) Name() string       { return f.name }
+func (f fakeFileInfo) Size() int64        { return 0 }
+func (f fakeFileInfo) Mode() os.FileMode  { return os.ModeDir | 0755 }
+func (f fakeFileInfo) ModTime() time.Time { return time.Time{} }
+func (f fakeFileInfo) IsDir() bool        { return true }
+func (f fakeFileInfo) Sys() interface{}   { return nil }
+
+// fakeFileSystem - FileSystem backed by a fixed set of directories
+type fakeFileSystem struct {
+	dirs map[string]bool
+}
+
+func (f fakeFileSystem) Stat(name string) (os.FileInfo, error) {
+	if f.dirs[name] {
+		return fakeFileInfo{name: name}, nil
+	}
+	return nil, os.ErrNotExist
+}
+
+func (f fakeFileSystem) EvalSymlinks(path string) (string, error) {
+	return path, nil
+}
+
+// fakeClock - Clock that always returns a fixed time
+type fakeClock struct {
+	now time.Time
+}
+
+func (c fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c fakeClock) Sleep(d time.Duration) {}
+
+// TestNewCommandExecutorWithOptions - Test construction without a config
+func TestNewCommandExecutorWithOptions(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cmdExecutor, err := NewCommandExecutor(nil, WithAllowedCommands("ls", "echo"))
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"ls", "echo"}, cmdExecutor.GetAllowedCommands())
+	assert.True(t, cmdExecutor.IsCommandAllowed("echo hello"))
+	assert.False(t, cmdExecutor.IsCommandAllowed("rm -rf /"))
+}
+
+// TestWithLogger - Test that the executor logs to the provided logger
+func TestWithLogger(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+
+	_, err := NewCommandExecutor(nil, WithLogger(zap.New(core).Sugar()))
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, logs.FilterMessage("creating new Command Executor").Len())
+}
+
+// TestWithFileSystem - Test that directory checks use the provided filesystem
+func TestWithFileSystem(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	fs := fakeFileSystem{dirs: map[string]bool{"/virtual": true, "/virtual/project": true}}
+
+	cmdExecutor, err := newCommandExecutor(nil,
+		WithAllowedCommands("cd"),
+		WithFileSystem(fs),
+		WithClock(fakeClock{now: time.Unix(0, 0)}),
+	)
+	require.NoError(t, err)
+	cmdExecutor.currentWorkingDir = "/virtual"
+
+	// The directory only exists in the fake filesystem
+	result, err := cmdExecutor.Execute("cd project", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "/virtual/project", result.WorkingDir)
+	assert.Equal(t, "/virtual/project", cmdExecutor.GetCurrentWorkingDir())
+
+	// Missing directories are still rejected
+	_, err = cmdExecutor.Execute("cd missing", Options{})
+	assert.Error(t, err)
+}
+
+// slowFileSystem - FileSystem whose symlink resolution blocks until released
+type slowFileSystem struct {
+	fakeFileSystem
+	release chan struct{}
+}
+
+func (f slowFileSystem) EvalSymlinks(path string) (string, error) {
+	<-f.release
+	return path, nil
+}
+
+// TestResolveTimeoutChangeDirectory - Test that cd gives up on a slow filesystem
+func TestResolveTimeoutChangeDirectory(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	release := make(chan struct{})
+	defer close(release)
+	fs := slowFileSystem{
+		fakeFileSystem: fakeFileSystem{dirs: map[string]bool{"/virtual": true, "/virtual/slow": true}},
+		release:        release,
+	}
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"cd", "pwd"}
+	cfg.CommandExec.ResolveTimeoutMs = 50
+	cmdExecutor, err := newCommandExecutor(cfg, WithFileSystem(fs))
+	require.NoError(t, err)
+	cmdExecutor.currentWorkingDir = "/virtual"
+
+	start := time.Now()
+	result, err := cmdExecutor.Execute("cd slow", Options{})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, err.Error(), "cd /virtual/slow timed out after 50ms")
+	assert.Equal(t, 1, result.ExitCode)
+
+	// The working directory is unchanged and other built-ins still respond
+	result, err = cmdExecutor.Execute("pwd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "/virtual", result.Stdout)
+}
+
+// TestWithResultHook - Test that registered hooks mutate the result in order
+func TestWithResultHook(t *testing.T) {
+	var calls []string
+	cmdExecutor, _ := newTestExecutor(t, nil,
+		WithResultHook(func(result *types.CommandResult) {
+			calls = append(calls, "first")
+			result.Stdout += "checked\n"
+		}),
+		WithResultHook(func(result *types.CommandResult) {
+			calls = append(calls, "second")
+			result.Note = "exit " + strconv.Itoa(result.ExitCode)
+		}),
+	)
+
+	result, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"first", "second"}, calls)
+	assert.Equal(t, "hi\nchecked\n", result.Stdout)
+	assert.Equal(t, "exit 0", result.Note)
+
+	// History holds the result as the hooks left it
+	last, ok := cmdExecutor.LastResult("", "")
+	require.True(t, ok)
+	assert.Equal(t, result.Stdout, last.Stdout)
+}
+
+// TestScrubSecretsHook - Test that the built-in hook scrubs sensitive assignments from output
+func TestScrubSecretsHook(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil, WithResultHook(ScrubSecretsHook))
+
+	result, err := cmdExecutor.Execute("echo API_TOKEN=abc123 USER=bob", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, "API_TOKEN=[REDACTED] USER=bob\n", result.Stdout)
+	assert.Equal(t, "echo API_TOKEN=[REDACTED] USER=bob", result.Command)
+}
@@ -0,0 +1,119 @@
+package executor
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// limitedBuffer keeps at most limit bytes of output and discards the rest.
+// A limit of 0 or less keeps everything.
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+// Write implements io.Writer. It always reports the full length so the
+// command is not interrupted by a short write once the limit is reached.
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit <= 0 {
+		return b.buf.Write(p)
+	}
+
+	remaining := b.limit - b.buf.Len()
+	if remaining <= 0 {
+		if len(p) > 0 {
+			b.truncated = true
+		}
+		return len(p), nil
+	}
+
+	if len(p) > remaining {
+		b.buf.Write(p[:remaining])
+		b.truncated = true
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+// String returns the captured output
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
+}
+
+// maxOutputBytes returns the output cap for a command: the per-command
+// override when one is configured, otherwise the global max_output_bytes
+func (e *commandExecutor) maxOutputBytes(command string) int {
+	parts := strings.Fields(command)
+	if len(parts) > 0 {
+		if limit, ok := e.cfg.CommandExec.CommandMaxOutput[filepath.Base(parts[0])]; ok {
+			return limit
+		}
+	}
+
+	return e.cfg.CommandExec.MaxOutputBytes
+}
+
+// Ends of the output kept by max_output_lines
+const (
+	keepHead = "head"
+	keepTail = "tail"
+)
+
+// truncateLines keeps the first (head) or last (tail) limit lines of s,
+// reporting whether any were dropped. A limit of 0 or less keeps everything.
+func truncateLines(s string, limit int, keep string) (string, bool) {
+	if limit <= 0 || s == "" {
+		return s, false
+	}
+
+	// A final line without a newline still counts as a line
+	lines := strings.Count(s, "\n")
+	if !strings.HasSuffix(s, "\n") {
+		lines++
+	}
+	if lines <= limit {
+		return s, false
+	}
+
+	if keep == keepTail {
+		end := len(s)
+		if strings.HasSuffix(s, "\n") {
+			end--
+		}
+		start := end
+		for i := 0; i < limit; i++ {
+			start = strings.LastIndexByte(s[:start], '\n')
+		}
+		return s[start+1:], true
+	}
+
+	end := 0
+	for i := 0; i < limit; i++ {
+		end += strings.IndexByte(s[end:], '\n') + 1
+	}
+	return s[:end], true
+}
+
+// outputLineLimit returns the line cap and the end to keep for a call: the
+// per-call settings when given, otherwise max_output_lines and output_lines_keep
+func (e *commandExecutor) outputLineLimit(options Options) (int, string, error) {
+	limit := e.cfg.CommandExec.MaxOutputLines
+	if options.MaxOutputLines > 0 {
+		limit = options.MaxOutputLines
+	}
+
+	keep := e.outputLinesKeep
+	switch options.OutputLinesKeep {
+	case "":
+	case keepHead, keepTail:
+		keep = options.OutputLinesKeep
+	default:
+		return 0, "", errors.Newf("invalid output_lines_keep: %s", options.OutputLinesKeep)
+	}
+	return limit, keep, nil
+}
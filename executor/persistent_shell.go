@@ -0,0 +1,387 @@
+package executor
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+)
+
+// shellSession is a long-lived bash process that keeps shell state (variables,
+// functions, the directory) between the commands of one client session
+type shellSession struct {
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bufio.Reader
+	marker string
+}
+
+// persistentShellMetachars are rejected in command lines sent to the persistent
+// shell. Only a line's leading program is checked against the allowlist, so
+// anything that would run or redirect to something else is refused.
+var persistentShellMetachars = []string{";", "&", "|", "$(", "`", "<", ">", "(", ")"}
+
+// shellOutput is what one command sent to a shell session produced
+type shellOutput struct {
+	stdout          string
+	stderr          string
+	stdoutTruncated bool
+	stderrTruncated bool
+	exitCode        int
+	workingDir      string
+}
+
+// shellStream is the output read from one stream up to the end marker
+type shellStream struct {
+	text      string
+	truncated bool
+	status    string
+	err       error
+}
+
+// persistentShells holds the shell of each client session
+type persistentShells struct {
+	mu     sync.Mutex
+	shells map[string]*shellSession
+}
+
+// get returns the session's shell, starting one with start if there is none
+func (p *persistentShells) get(sessionID string, start func() (*shellSession, error)) (*shellSession, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if shell, ok := p.shells[sessionID]; ok {
+		return shell, nil
+	}
+
+	shell, err := start()
+	if err != nil {
+		return nil, err
+	}
+	if p.shells == nil {
+		p.shells = make(map[string]*shellSession)
+	}
+	p.shells[sessionID] = shell
+	return shell, nil
+}
+
+// remove drops the session's shell if it is still the given one
+func (p *persistentShells) remove(sessionID string, shell *shellSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shells[sessionID] == shell {
+		delete(p.shells, sessionID)
+	}
+}
+
+// closeAll stops every session's shell. The shells are killed first so commands
+// still running in them end, then each is waited for once its caller lets go.
+func (p *persistentShells) closeAll() {
+	p.mu.Lock()
+	shells := p.shells
+	p.shells = nil
+	p.mu.Unlock()
+
+	for _, shell := range shells {
+		killShell(shell.cmd)
+	}
+	for _, shell := range shells {
+		shell.mu.Lock()
+		shell.close()
+		shell.mu.Unlock()
+	}
+}
+
+// CloseShells stops the persistent shells of all sessions
+func (e *commandExecutor) CloseShells() {
+	e.shells.closeAll()
+}
+
+// checkShellLine rejects a line sent to the persistent shell that could run
+// anything besides its leading program
+func (e *commandExecutor) checkShellLine(line string) error {
+	for _, seq := range persistentShellMetachars {
+		if strings.Contains(line, seq) {
+			e.logger.Warnw("persistent shell command contains shell metacharacters",
+				"command", line,
+				"sequence", seq)
+			return fmt.Errorf("persistent shell commands cannot contain %q: only the leading program is checked against the allowlist", seq)
+		}
+	}
+
+	return nil
+}
+
+// validatePersistentShell checks the persistent_shell setting. Commands in the
+// shell are interpreted by bash, so it also requires allow_shell.
+func (e *commandExecutor) validatePersistentShell() error {
+	if !e.cfg.CommandExec.PersistentShell {
+		return nil
+	}
+
+	if !e.cfg.CommandExec.AllowShell {
+		return e.invalidSetting("persistent_shell", "enabled without allow_shell", "false")
+	}
+
+	e.persistentShell = true
+	e.logger.Warnw("persistent_shell is enabled; commands sent to it are interpreted by bash, and lines with command separators, substitutions or redirections are rejected")
+	return nil
+}
+
+// startShell starts a bash process for a session in workingDir
+func (e *commandExecutor) startShell(workingDir string, env map[string]string) (*shellSession, error) {
+	bash, err := e.resolveBinaryPath("bash")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find bash for the persistent shell")
+	}
+
+	cmd := exec.Command(bash, "--noprofile", "--norc")
+	cmd.Dir = workingDir
+	cmd.Env = e.buildEnvironment(env)
+	startInGroup(cmd)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create persistent shell stdin")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create persistent shell stdout")
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create persistent shell stderr")
+	}
+
+	// A random marker keeps command output from ending a read early by accident
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, errors.Wrap(err, "failed to generate persistent shell marker")
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, errors.Wrap(err, "failed to start persistent shell")
+	}
+
+	e.logger.Infow("started persistent shell",
+		"pid", cmd.Process.Pid,
+		"working_dir", workingDir)
+
+	return &shellSession{
+		cmd:    cmd,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: bufio.NewReader(stderr),
+		marker: "__MCP_COMMAND_DONE_" + hex.EncodeToString(nonce),
+	}, nil
+}
+
+// close kills the shell and waits for it to exit
+func (s *shellSession) close() {
+	killShell(s.cmd)
+	_ = s.cmd.Wait()
+}
+
+// run sends line to the shell and reads its output up to the end markers,
+// keeping at most limit bytes of each stream. The line is evaluated in the
+// shell itself, so state it changes persists, with stdin from /dev/null so it
+// cannot consume the markers. A zero timeout waits indefinitely. On error the
+// shell is unusable and must be closed.
+func (s *shellSession) run(line string, timeout time.Duration, limit int) (shellOutput, error) {
+	script := fmt.Sprintf("{ eval %s\n} </dev/null\n"+
+		"__mcp_rc=$?; printf '\\n%%s %%d %%s\\n' %s \"$__mcp_rc\" \"$PWD\"; printf '\\n%%s\\n' %s >&2\n",
+		shellQuote(line), s.marker, s.marker)
+	if _, err := io.WriteString(s.stdin, script); err != nil {
+		return shellOutput{}, errors.Wrap(err, "persistent shell is not running")
+	}
+
+	stdoutCh := make(chan shellStream, 1)
+	stderrCh := make(chan shellStream, 1)
+	go s.readStream(s.stdout, limit, stdoutCh)
+	go s.readStream(s.stderr, limit, stderrCh)
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		timer = time.After(timeout)
+	}
+
+	var stdout, stderr shellStream
+	timedOut := false
+	for received := 0; received < 2; {
+		select {
+		case stdout = <-stdoutCh:
+			received++
+		case stderr = <-stderrCh:
+			received++
+		case <-timer:
+			// Killing the shell and its commands ends both reads
+			timedOut = true
+			timer = nil
+			killShell(s.cmd)
+		}
+	}
+
+	output := shellOutput{
+		stdout:          stdout.text,
+		stderr:          stderr.text,
+		stdoutTruncated: stdout.truncated,
+		stderrTruncated: stderr.truncated,
+	}
+	if timedOut {
+		output.exitCode = timeoutExitCode
+		return output, errors.Newf("command timed out after %s; the persistent shell was restarted", timeout)
+	}
+	if stdout.err != nil || stderr.err != nil {
+		output.exitCode = 1
+		return output, errors.New("persistent shell exited; the next command starts a new one")
+	}
+
+	code, dir, _ := strings.Cut(stdout.status, " ")
+	output.exitCode, _ = strconv.Atoi(code)
+	output.workingDir = dir
+	return output, nil
+}
+
+// readStream reads r up to the line holding the marker, keeping at most limit
+// bytes of the output before it. Output is read in chunks rather than lines so
+// a long line cannot grow past the limit. The newline printed before the marker
+// is dropped so output without a trailing newline stays unchanged.
+func (s *shellSession) readStream(r *bufio.Reader, limit int, ch chan<- shellStream) {
+	buf := &limitedBuffer{limit: limit}
+	lineStart := true
+	heldNewline := false
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if lineStart && bytes.HasPrefix(chunk, []byte(s.marker)) {
+			status := string(chunk)
+			for err == bufio.ErrBufferFull {
+				chunk, err = r.ReadSlice('\n')
+				status += string(chunk)
+			}
+			ch <- shellStream{
+				text:      buf.String(),
+				truncated: buf.truncated,
+				status:    strings.TrimSpace(strings.TrimPrefix(status, s.marker)),
+			}
+			return
+		}
+
+		// A line's newline is held back until more output shows it is not the
+		// one printed before the marker
+		if heldNewline {
+			buf.Write([]byte{'\n'})
+			heldNewline = false
+		}
+		lineStart = bytes.HasSuffix(chunk, []byte{'\n'})
+		if lineStart {
+			buf.Write(chunk[:len(chunk)-1])
+			heldNewline = true
+		} else {
+			buf.Write(chunk)
+		}
+
+		if err != nil && err != bufio.ErrBufferFull {
+			if heldNewline {
+				buf.Write([]byte{'\n'})
+			}
+			ch <- shellStream{text: buf.String(), truncated: buf.truncated, err: err}
+			return
+		}
+	}
+}
+
+// executeInShell runs the command in the session's persistent shell. The shell
+// starts in workingDir with the call's environment; later calls share its state.
+func (e *commandExecutor) executeInShell(command string, parts []string, workingDir string, options Options) (types.CommandResult, error) {
+	result := types.CommandResult{
+		Command:    command,
+		WorkingDir: workingDir,
+	}
+
+	// Literal arguments are quoted below; a raw line must not run anything else
+	if options.Args == nil {
+		if err := e.checkShellLine(command); err != nil {
+			result.ExitCode = 1
+			result.Error = err.Error()
+			return result, err
+		}
+	}
+
+	// Output is capped like that of commands run directly
+	limit := e.maxOutputBytes(command)
+	lineLimit, keepLines, err := e.outputLineLimit(options)
+	if err != nil {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
+	}
+
+	shell, err := e.shells.get(options.SessionID, func() (*shellSession, error) {
+		return e.startShell(workingDir, options.Env)
+	})
+	if err != nil {
+		result.ExitCode = 1
+		result.Error = err.Error()
+		return result, err
+	}
+
+	// Literal arguments are quoted so the shell passes them through unchanged
+	line := command
+	if options.Args != nil {
+		quoted := make([]string, len(parts))
+		for i, part := range parts {
+			quoted[i] = shellQuote(part)
+		}
+		line = strings.Join(quoted, " ")
+	}
+
+	shell.mu.Lock()
+	defer shell.mu.Unlock()
+
+	output, err := shell.run(line, options.Timeout, limit)
+	result.Stdout = output.stdout
+	result.Stderr = output.stderr
+	result.StdoutTruncated = output.stdoutTruncated
+	result.StderrTruncated = output.stderrTruncated
+	if lineLimit > 0 {
+		var truncated bool
+		result.Stdout, truncated = truncateLines(result.Stdout, lineLimit, keepLines)
+		result.StdoutTruncated = result.StdoutTruncated || truncated
+		result.Stderr, truncated = truncateLines(result.Stderr, lineLimit, keepLines)
+		result.StderrTruncated = result.StderrTruncated || truncated
+	}
+	result.ExitCode = output.exitCode
+	if output.workingDir != "" {
+		result.WorkingDir = output.workingDir
+	}
+
+	if err != nil {
+		e.logger.Warnw("persistent shell failed",
+			"session_id", options.SessionID,
+			"command", command,
+			"error", err)
+		e.shells.remove(options.SessionID, shell)
+		shell.close()
+		result.Error = err.Error()
+		return result, err
+	}
+
+	// Report failures like commands run directly do
+	if result.ExitCode != 0 {
+		err = errors.Newf("exit status %d", result.ExitCode)
+		result.Error = err.Error()
+	}
+	return result, err
+}
@@ -0,0 +1,179 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newShellExecutor returns an executor with the persistent shell enabled
+func newShellExecutor(t *testing.T, modify func(cfg *config.Config)) (*commandExecutor, string) {
+	t.Helper()
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"export", "echo", "cd", "pwd", "exit", "sleep", "true", "printf"}
+		cfg.CommandExec.AllowShell = true
+		cfg.CommandExec.PersistentShell = true
+		if modify != nil {
+			modify(cfg)
+		}
+	})
+	t.Cleanup(cmdExecutor.CloseShells)
+	return cmdExecutor, dir
+}
+
+// TestPersistentShellKeepsState - Test that a variable set in one call is visible in the next
+func TestPersistentShellKeepsState(t *testing.T) {
+	cmdExecutor, dir := newShellExecutor(t, nil)
+	options := Options{PersistentShell: true, SessionID: "s1"}
+
+	result, err := cmdExecutor.Execute("export GREETING=hello", options)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+	assert.Equal(t, dir, result.WorkingDir)
+
+	result, err = cmdExecutor.Execute("echo $GREETING world", options)
+	require.NoError(t, err)
+	assert.Equal(t, "hello world\n", result.Stdout)
+
+	// Output without a trailing newline and stderr are kept apart
+	result, err = cmdExecutor.Execute("echo -n out", options)
+	require.NoError(t, err)
+	assert.Equal(t, "out", result.Stdout)
+	assert.Empty(t, result.Stderr)
+
+	result, err = cmdExecutor.Execute("cd missing", options)
+	require.Error(t, err)
+	assert.Empty(t, result.Stdout)
+	assert.Contains(t, result.Stderr, "No such file or directory\n")
+
+	// Other sessions get their own shell
+	result, err = cmdExecutor.Execute("echo ${GREETING:-unset}", Options{PersistentShell: true, SessionID: "s2"})
+	require.NoError(t, err)
+	assert.Equal(t, "unset\n", result.Stdout)
+}
+
+// TestPersistentShellFailures - Test exit codes, syntax errors, and a shell that exits or times out
+func TestPersistentShellFailures(t *testing.T) {
+	cmdExecutor, _ := newShellExecutor(t, nil)
+	options := Options{PersistentShell: true, SessionID: "s1"}
+
+	result, err := cmdExecutor.Execute("echo 'unterminated", options)
+	require.Error(t, err)
+	assert.NotEqual(t, 0, result.ExitCode)
+
+	// A failed command leaves the shell usable
+	result, err = cmdExecutor.Execute("true", options)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.ExitCode)
+
+	// A shell that exits is replaced by a new one on the next call
+	_, err = cmdExecutor.Execute("export KEPT=1", options)
+	require.NoError(t, err)
+	_, err = cmdExecutor.Execute("exit 3", options)
+	require.Error(t, err)
+	result, err = cmdExecutor.Execute("echo ${KEPT:-gone}", options)
+	require.NoError(t, err)
+	assert.Equal(t, "gone\n", result.Stdout)
+
+	// A timeout kills the shell
+	result, err = cmdExecutor.Execute("sleep 10", Options{PersistentShell: true, SessionID: "s1", Timeout: 100 * time.Millisecond})
+	require.Error(t, err)
+	assert.Equal(t, timeoutExitCode, result.ExitCode)
+}
+
+// TestPersistentShellRequiresAllowShell - Test that the persistent shell is off unless allow_shell is set
+func TestPersistentShellRequiresAllowShell(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.PersistentShell = true
+	})
+
+	_, err := cmdExecutor.Execute("echo hi", Options{PersistentShell: true})
+	assert.ErrorContains(t, err, "persistent shell is not enabled")
+
+	cfg := &config.Config{}
+	cfg.CommandExec.PersistentShell = true
+	cfg.CommandExec.StrictConfig = true
+	cfg.CommandExec.PathBehavior = "prepend"
+	cfg.CommandExec.StdinMode = "null"
+	cfg.CommandExec.KillSignal = "SIGTERM"
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	_, err = NewCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid persistent_shell setting")
+}
+
+// TestPersistentShellRejectsMetachars - Test that a line cannot run anything besides its leading program
+func TestPersistentShellRejectsMetachars(t *testing.T) {
+	cmdExecutor, _ := newShellExecutor(t, nil)
+	options := Options{PersistentShell: true, SessionID: "s1"}
+
+	for _, line := range []string{
+		"echo hi; rm -rf x",
+		"true && rm -rf x",
+		"echo hi | rm -rf x",
+		"echo $(rm -rf x)",
+		"echo `rm -rf x`",
+		"echo hi > /etc/passwd",
+	} {
+		result, err := cmdExecutor.Execute(line, options)
+		require.Error(t, err, line)
+		assert.Contains(t, result.Error, "persistent shell commands cannot contain", line)
+	}
+	assert.Empty(t, cmdExecutor.shells.shells)
+
+	// Literal arguments are quoted, so they may contain anything
+	result, err := cmdExecutor.Execute("echo", Options{PersistentShell: true, SessionID: "s1", Args: []string{"a; b > c"}})
+	require.NoError(t, err)
+	assert.Equal(t, "a; b > c\n", result.Stdout)
+}
+
+// TestPersistentShellOutputLimits - Test that shell output is capped like that of commands run directly
+func TestPersistentShellOutputLimits(t *testing.T) {
+	cmdExecutor, _ := newShellExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxOutputBytes = 5
+	})
+	options := Options{PersistentShell: true, SessionID: "s1"}
+
+	result, err := cmdExecutor.Execute("echo hello world", options)
+	require.NoError(t, err)
+	assert.Equal(t, "hello", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	// A single line longer than the read buffer is capped too
+	result, err = cmdExecutor.Execute("printf %010000d 0", options)
+	require.NoError(t, err)
+	assert.Equal(t, "00000", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	// The shell stays usable after truncated output
+	result, err = cmdExecutor.Execute("echo -n ok", options)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result.Stdout)
+	assert.False(t, result.StdoutTruncated)
+
+	// max_output_lines applies as well
+	result, err = cmdExecutor.Execute("printf '%s\\n' a b c", Options{PersistentShell: true, SessionID: "s2", MaxOutputLines: 2})
+	require.NoError(t, err)
+	assert.Equal(t, "a\nb\n", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+}
+
+// TestPersistentShellCloseShells - Test that every session's shell is stopped
+func TestPersistentShellCloseShells(t *testing.T) {
+	cmdExecutor, _ := newShellExecutor(t, nil)
+
+	for _, sessionID := range []string{"s1", "s2"} {
+		_, err := cmdExecutor.Execute("true", Options{PersistentShell: true, SessionID: sessionID})
+		require.NoError(t, err)
+	}
+	shells := cmdExecutor.shells.shells
+	require.Len(t, shells, 2)
+
+	cmdExecutor.CloseShells()
+	assert.Empty(t, cmdExecutor.shells.shells)
+	for _, shell := range shells {
+		assert.NotNil(t, shell.cmd.ProcessState)
+	}
+}
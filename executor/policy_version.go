@@ -0,0 +1,59 @@
+package executor
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// policyVersionLength is the number of hex digits kept of a policy hash
+const policyVersionLength = 16
+
+// PolicyHash returns a stable hash of named policy lists. The order of the
+// sections and of the entries within each does not matter.
+func PolicyHash(sections map[string][]string) string {
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		entries := slices.Clone(sections[name])
+		slices.Sort(entries)
+		h.Write([]byte(name + "\x00" + strings.Join(entries, "\x00") + "\n"))
+	}
+	return hex.EncodeToString(h.Sum(nil))[:policyVersionLength]
+}
+
+// policyVersion hashes the policy governing an execution: the allowlist as
+// changed at runtime, the directories, the environment restrictions, and the
+// session's own policy, if the caller reports one
+func (e *commandExecutor) policyVersion(sessionPolicyVersion string) string {
+	// A nil allowed_env_overrides leaves overrides unrestricted, unlike an empty one
+	envOverrides := []string{"*"}
+	if e.envOverrides != nil {
+		envOverrides = make([]string, 0, len(e.envOverrides))
+		for key := range e.envOverrides {
+			envOverrides = append(envOverrides, key)
+		}
+	}
+	blockedEnvKeys := make([]string, 0, len(e.blockedEnvKeys))
+	for key := range e.blockedEnvKeys {
+		blockedEnvKeys = append(blockedEnvKeys, key)
+	}
+
+	return PolicyHash(map[string][]string{
+		"allowed_commands":      e.GetAllowedCommands(),
+		"allowed_dirs":          e.allowedDirs,
+		"cd_allowed_dirs":       e.cdAllowedDirs(),
+		"workdir_allowed_dirs":  e.workdirAllowedDirs(),
+		"blocked_env_keys":      blockedEnvKeys,
+		"allowed_env_overrides": envOverrides,
+		"default_deny":          {strconv.FormatBool(e.cfg.CommandExec.DefaultDeny)},
+		"session_policy":        {sessionPolicyVersion},
+	})
+}
@@ -0,0 +1,48 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecutePolicyVersion - Test that policy_version is stable and changes with the allowlist
+func TestExecutePolicyVersion(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	first, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.Len(t, first.PolicyVersion, 16)
+
+	second, err := cmdExecutor.Execute("pwd", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, first.PolicyVersion, second.PolicyVersion)
+
+	// Changing the allowlist changes the version, and undoing the change restores it
+	require.True(t, cmdExecutor.AllowCommand("true"))
+	changed, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PolicyVersion, changed.PolicyVersion)
+
+	require.True(t, cmdExecutor.DisallowCommand("true"))
+	restored, err := cmdExecutor.Execute("echo hi", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, first.PolicyVersion, restored.PolicyVersion)
+
+	// A session's own policy is folded in
+	session, err := cmdExecutor.Execute("echo hi", Options{SessionPolicyVersion: "abc"})
+	require.NoError(t, err)
+	assert.NotEqual(t, first.PolicyVersion, session.PolicyVersion)
+}
+
+// TestPolicyHash - Test that the hash ignores ordering but not contents
+func TestPolicyHash(t *testing.T) {
+	a := PolicyHash(map[string][]string{"allowed_commands": {"git", "ls"}, "allowed_dirs": {"/tmp"}})
+	b := PolicyHash(map[string][]string{"allowed_dirs": {"/tmp"}, "allowed_commands": {"ls", "git"}})
+	assert.Equal(t, a, b)
+
+	// Entries are not confused across sections
+	c := PolicyHash(map[string][]string{"allowed_commands": {"git", "ls", "/tmp"}, "allowed_dirs": {}})
+	assert.NotEqual(t, a, c)
+}
@@ -0,0 +1,104 @@
+package executor
+
+import (
+	"fmt"
+	"syscall"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+)
+
+// Probe check statuses
+const (
+	ProbeOK   = "ok"
+	ProbeWarn = "warn"
+	ProbeFail = "fail"
+)
+
+// ProbeCheck is the outcome of a single startup diagnostic
+type ProbeCheck struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// ProbeReport is the readiness report produced by Probe
+type ProbeReport struct {
+	OK     bool         `json:"ok"`
+	Checks []ProbeCheck `json:"checks"`
+}
+
+// add records a check; failed checks are critical and mark the report as not ready
+func (r *ProbeReport) add(name, status, detail string) {
+	r.Checks = append(r.Checks, ProbeCheck{Name: name, Status: status, Detail: detail})
+	if status == ProbeFail {
+		r.OK = false
+	}
+}
+
+// Probe runs a battery of harmless checks against the configuration to catch
+// deployment problems before a client connects
+func Probe(cfg *config.Config, opts ...Option) (ProbeReport, error) {
+	e, err := newCommandExecutor(cfg, opts...)
+	if err != nil {
+		return ProbeReport{}, err
+	}
+	return e.probe(), nil
+}
+
+// probe runs the startup diagnostics
+func (e *commandExecutor) probe() ProbeReport {
+	report := ProbeReport{OK: true}
+
+	// The working directory must be allowed and writable
+	workingDir := e.GetCurrentWorkingDir()
+	if !e.IsDirectoryAllowed(workingDir) {
+		report.add("working_dir_allowed", ProbeFail,
+			fmt.Sprintf("%s is not within allowed_dirs", workingDir))
+	} else {
+		report.add("working_dir_allowed", ProbeOK, workingDir)
+	}
+
+	if err := dirWritable(workingDir); err != nil {
+		report.add("working_dir_writable", ProbeFail, err.Error())
+	} else {
+		report.add("working_dir_writable", ProbeOK, workingDir)
+	}
+
+	// Missing search paths only narrow command resolution
+	for _, dir := range e.searchPaths {
+		name := "search_path:" + dir
+		info, err := e.fs.Stat(dir)
+		switch {
+		case err != nil:
+			report.add(name, ProbeWarn, err.Error())
+		case !info.IsDir():
+			report.add(name, ProbeWarn, "not a directory")
+		default:
+			report.add(name, ProbeOK, "")
+		}
+	}
+
+	// Allowed commands that cannot be resolved will fail at execution time
+	for _, cmd := range e.GetAllowedCommands() {
+		if e.isBuiltinCommand(cmd) {
+			continue
+		}
+
+		name := "command:" + cmd
+		if path, err := e.resolveBinaryPath(cmd); err != nil {
+			report.add(name, ProbeWarn, err.Error())
+		} else {
+			report.add(name, ProbeOK, path)
+		}
+	}
+
+	// Resource limits rely on the rlimit syscalls
+	var rlimit syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_CPU, &rlimit); err != nil {
+		report.add("resource_limits", ProbeWarn, err.Error())
+	} else {
+		report.add("resource_limits", ProbeOK, "")
+	}
+
+	return report
+}
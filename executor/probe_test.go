@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// findCheck returns the named check from a probe report
+func findCheck(t *testing.T, report ProbeReport, name string) ProbeCheck {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	require.Failf(t, "check not found", "%s", name)
+	return ProbeCheck{}
+}
+
+// TestProbeReady - Test a healthy configuration with a missing search path
+func TestProbeReady(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SearchPaths = []string{"/nonexistent/bin"}
+		cfg.CommandExec.AllowedCommands = []string{"echo", "no-such-command", "cd"}
+	})
+
+	report := cmdExecutor.probe()
+	assert.True(t, report.OK)
+	assert.Equal(t, ProbeOK, findCheck(t, report, "working_dir_writable").Status)
+	assert.Equal(t, ProbeOK, findCheck(t, report, "command:echo").Status)
+	assert.Equal(t, ProbeWarn, findCheck(t, report, "search_path:/nonexistent/bin").Status)
+	assert.Equal(t, ProbeWarn, findCheck(t, report, "command:no-such-command").Status)
+
+	// Built-in commands are not resolved
+	for _, check := range report.Checks {
+		assert.NotEqual(t, "command:cd", check.Name)
+	}
+}
+
+// TestProbeUnwritableWorkingDir - Test that an unusable working directory is critical
+func TestProbeUnwritableWorkingDir(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, nil)
+	require.NoError(t, os.RemoveAll(dir))
+
+	report := cmdExecutor.probe()
+	assert.False(t, report.OK)
+	assert.Equal(t, ProbeFail, findCheck(t, report, "working_dir_writable").Status)
+}
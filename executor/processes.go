@@ -0,0 +1,129 @@
+package executor
+
+import (
+	"os"
+	"sort"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// killSignals are the signals accepted by the kill_signal setting
+var killSignals = map[string]syscall.Signal{
+	"SIGTERM": syscall.SIGTERM,
+	"SIGINT":  syscall.SIGINT,
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+}
+
+// RunningProcess describes a command whose process is currently running
+type RunningProcess struct {
+	ID        string    `json:"id"`
+	Command   string    `json:"command"`
+	PID       int       `json:"pid"`
+	StartedAt time.Time `json:"started_at"`
+	SessionID string    `json:"session_id,omitempty"`
+}
+
+// trackedProcess is a registry entry for a running process
+type trackedProcess struct {
+	info    RunningProcess
+	process *os.Process
+	killed  bool
+}
+
+// processRegistry tracks the processes of in-flight executions
+type processRegistry struct {
+	mu    sync.Mutex
+	procs map[string]*trackedProcess
+}
+
+// add registers a started process under its execution ID, the same ID its
+// result and log lines carry
+func (r *processRegistry) add(id string, command string, process *os.Process, startedAt time.Time, sessionID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.procs == nil {
+		r.procs = make(map[string]*trackedProcess)
+	}
+
+	r.procs[id] = &trackedProcess{
+		info: RunningProcess{
+			ID:        id,
+			Command:   command,
+			PID:       process.Pid,
+			StartedAt: startedAt,
+			SessionID: sessionID,
+		},
+		process: process,
+	}
+}
+
+// remove unregisters a process once it has exited and reports whether it was
+// killed through the registry
+func (r *processRegistry) remove(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	p, ok := r.procs[id]
+	delete(r.procs, id)
+	return ok && p.killed
+}
+
+// list returns the running processes, oldest first
+func (r *processRegistry) list() []RunningProcess {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	procs := make([]RunningProcess, 0, len(r.procs))
+	for _, p := range r.procs {
+		procs = append(procs, p.info)
+	}
+	sort.Slice(procs, func(i, j int) bool {
+		return procs[i].StartedAt.Before(procs[j].StartedAt) ||
+			(procs[i].StartedAt.Equal(procs[j].StartedAt) && procs[i].PID < procs[j].PID)
+	})
+	return procs
+}
+
+// get returns the process registered under id
+func (r *processRegistry) get(id string) (*trackedProcess, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	p, ok := r.procs[id]
+	return p, ok
+}
+
+// ListProcesses returns the commands whose processes are currently running
+func (e *commandExecutor) ListProcesses() []RunningProcess {
+	return e.processes.list()
+}
+
+// KillProcess sends the configured kill_signal to a running process. The
+// process leaves the registry once it exits.
+func (e *commandExecutor) KillProcess(id string) error {
+	p, ok := e.processes.get(id)
+	if !ok {
+		return errors.Newf("no running process with id %s", id)
+	}
+
+	e.logger.Infow("killing process",
+		"id", id,
+		"pid", p.info.PID,
+		"command", p.info.Command,
+		"signal", e.killSignal)
+
+	// Mark the kill first, so the command's result cannot miss it
+	e.processes.mu.Lock()
+	p.killed = true
+	e.processes.mu.Unlock()
+
+	if err := p.process.Signal(e.killSignal); err != nil {
+		return errors.Wrapf(err, "failed to signal process %s", id)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package executor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startTracked - Run a command in the background and wait until it is listed
+func startTracked(t *testing.T, cmdExecutor *commandExecutor, command string, options Options) (RunningProcess, <-chan types.CommandResult) {
+	t.Helper()
+
+	done := make(chan types.CommandResult, 1)
+	go func() {
+		result, _ := cmdExecutor.Execute(command, options)
+		done <- result
+	}()
+
+	var procs []RunningProcess
+	require.Eventually(t, func() bool {
+		procs = cmdExecutor.ListProcesses()
+		return len(procs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return procs[0], done
+}
+
+// TestListAndKillProcess - Test that a running command is listed and removed once killed
+func TestListAndKillProcess(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "sleep")
+	})
+	assert.Empty(t, cmdExecutor.ListProcesses())
+
+	proc, done := startTracked(t, cmdExecutor, "sleep 30", Options{SessionID: "session-1", ExecutionID: "exec-1"})
+	assert.Equal(t, "exec-1", proc.ID)
+	assert.Equal(t, "sleep 30", proc.Command)
+	assert.Positive(t, proc.PID)
+	assert.Equal(t, "session-1", proc.SessionID)
+	assert.False(t, proc.StartedAt.IsZero())
+
+	require.NoError(t, cmdExecutor.KillProcess(proc.ID))
+
+	select {
+	case result := <-done:
+		assert.Equal(t, "signal: terminated", result.Error)
+		assert.Equal(t, proc.ID, result.ExecutionID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed command did not return")
+	}
+	assert.Empty(t, cmdExecutor.ListProcesses())
+
+	// Finished processes can no longer be killed
+	assert.ErrorContains(t, cmdExecutor.KillProcess(proc.ID), "no running process with id")
+}
+
+// TestKillProcessSignal - Test that kills send the configured signal
+func TestKillProcessSignal(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.KillSignal = "SIGKILL"
+	})
+
+	// The command ignores SIGTERM, so only the configured SIGKILL stops it
+	proc, done := startTracked(t, cmdExecutor, "sh", Options{
+		Args: []string{"-c", "trap '' TERM; while :; do sleep 0.1; done"},
+	})
+	require.NoError(t, cmdExecutor.KillProcess(proc.ID))
+
+	select {
+	case result := <-done:
+		assert.Equal(t, "signal: killed", result.Error)
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed command did not return")
+	}
+	assert.Empty(t, cmdExecutor.ListProcesses())
+}
@@ -0,0 +1,73 @@
+package executor
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+)
+
+// ProgressSink is optionally implemented by a StreamSink to receive progress
+// parsed from output lines matching the command's progress_patterns entry
+type ProgressSink interface {
+	// Progress is called with a percentage between 0 and 100
+	Progress(percent float64)
+}
+
+// compileProgressPatterns compiles the progress_patterns config. Each pattern
+// must capture the percentage in its first group.
+func compileProgressPatterns(patterns map[string]string) (map[string]*regexp.Regexp, error) {
+	compiled := make(map[string]*regexp.Regexp, len(patterns))
+	for name, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid progress_patterns entry for %s", name)
+		}
+		if re.NumSubexp() < 1 {
+			return nil, errors.Newf("progress_patterns entry for %s must capture the percentage in a group", name)
+		}
+		compiled[name] = re
+	}
+	return compiled, nil
+}
+
+// progressWriter splits output into lines and reports the percentages they contain.
+// Progress bars usually redraw with carriage returns, so both \r and \n end a line.
+type progressWriter struct {
+	pattern *regexp.Regexp
+	sink    ProgressSink
+	line    []byte
+}
+
+// Write implements io.Writer
+func (w *progressWriter) Write(p []byte) (int, error) {
+	data := p
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "\r\n")
+		if i < 0 {
+			w.line = append(w.line, data...)
+			break
+		}
+
+		w.line = append(w.line, data[:i]...)
+		w.report()
+		w.line = w.line[:0]
+		data = data[i+1:]
+	}
+	return len(p), nil
+}
+
+// report parses the buffered line and forwards its percentage, if any
+func (w *progressWriter) report() {
+	match := w.pattern.FindSubmatch(w.line)
+	if match == nil {
+		return
+	}
+
+	percent, err := strconv.ParseFloat(string(match[1]), 64)
+	if err != nil || percent < 0 || percent > 100 {
+		return
+	}
+	w.sink.Progress(percent)
+}
@@ -0,0 +1,44 @@
+package executor
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultMutationRateWindow is used when mutation_rate_window_seconds is not set
+const defaultMutationRateWindow = time.Minute
+
+// slidingWindowLimiter allows at most limit events in any window-long period
+type slidingWindowLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	events []time.Time
+}
+
+// allow records an event at now and reports whether it is within the limit.
+// Rejected events are not recorded. A limit of 0 or less allows everything.
+func (l *slidingWindowLimiter) allow(now time.Time) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Forget events that have left the window
+	cutoff := now.Add(-l.window)
+	kept := l.events[:0]
+	for _, t := range l.events {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	l.events = kept
+
+	if len(l.events) >= l.limit {
+		return false
+	}
+	l.events = append(l.events, now)
+	return true
+}
@@ -0,0 +1,57 @@
+package executor
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSlidingWindowLimiter - Test that events are allowed again once older ones leave the window
+func TestSlidingWindowLimiter(t *testing.T) {
+	limiter := slidingWindowLimiter{limit: 2, window: time.Minute}
+	start := time.Unix(0, 0)
+
+	assert.True(t, limiter.allow(start))
+	assert.True(t, limiter.allow(start.Add(10*time.Second)))
+	assert.False(t, limiter.allow(start.Add(20*time.Second)))
+
+	// The first event has left the window
+	assert.True(t, limiter.allow(start.Add(61*time.Second)))
+	assert.False(t, limiter.allow(start.Add(62*time.Second)))
+
+	// No limit configured
+	unlimited := slidingWindowLimiter{window: time.Minute}
+	for i := 0; i < 10; i++ {
+		assert.True(t, unlimited.allow(start))
+	}
+}
+
+// TestExecuteMutationRateLimit - Test that mutating commands are throttled while reads are not
+func TestExecuteMutationRateLimit(t *testing.T) {
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"mkdir", "ls"}
+		cfg.CommandExec.MutatingCommands = []string{"mkdir"}
+		cfg.CommandExec.MutationRateLimit = 2
+		cfg.CommandExec.MutationRateWindowSeconds = 60
+	}, WithClock(fakeClock{now: time.Unix(0, 0)}))
+
+	_, err := cmdExecutor.Execute("mkdir a", Options{})
+	require.NoError(t, err)
+	_, err = cmdExecutor.Execute("mkdir b", Options{})
+	require.NoError(t, err)
+
+	result, err := cmdExecutor.Execute("mkdir c", Options{})
+	assert.ErrorContains(t, err, "mutation rate limit exceeded")
+	assert.Equal(t, 1, result.ExitCode)
+	assert.NoDirExists(t, filepath.Join(dir, "c"))
+
+	// Reads are not limited
+	for i := 0; i < 5; i++ {
+		_, err = cmdExecutor.Execute("ls", Options{})
+		require.NoError(t, err)
+	}
+}
@@ -0,0 +1,33 @@
+package executor
+
+import (
+	"strings"
+)
+
+// redactedValue replaces sensitive values in output
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeyMarkers are substrings of environment variable names that hold secrets
+var sensitiveKeyMarkers = []string{
+	"TOKEN",
+	"SECRET",
+	"PASSWORD",
+	"PASSWD",
+	"CREDENTIAL",
+	"API_KEY",
+	"APIKEY",
+	"PRIVATE_KEY",
+	"ACCESS_KEY",
+	"AUTH",
+}
+
+// isSensitiveEnvKey checks if the environment variable name looks like it holds a secret
+func isSensitiveEnvKey(key string) bool {
+	upper := strings.ToUpper(key)
+	for _, marker := range sensitiveKeyMarkers {
+		if strings.Contains(upper, marker) {
+			return true
+		}
+	}
+	return false
+}
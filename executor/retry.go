@@ -0,0 +1,132 @@
+package executor
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+)
+
+// Defaults used when the retry settings are not configured
+const (
+	defaultRetryMaxAttempts = 3
+	defaultRetryBackoff     = 200 * time.Millisecond
+	defaultRetryMultiplier  = 2.0
+)
+
+// validateRetryBackoff checks the retry backoff settings, falling back to
+// doubling from retry_backoff_ms without a cap or jitter when they are invalid
+func (e *commandExecutor) validateRetryBackoff() error {
+	// retry_base_delay takes over from the older retry_backoff_ms when set
+	e.retryBaseDelay = time.Duration(e.cfg.CommandExec.RetryBackoffMs) * time.Millisecond
+	if e.retryBaseDelay <= 0 {
+		e.retryBaseDelay = defaultRetryBackoff
+	}
+	if value := e.cfg.CommandExec.RetryBaseDelay; value != "" {
+		delay, err := time.ParseDuration(value)
+		if err == nil && delay > 0 {
+			e.retryBaseDelay = delay
+		} else if err := e.invalidSetting("retry_base_delay", value, e.retryBaseDelay.String()); err != nil {
+			return err
+		}
+	}
+
+	if value := e.cfg.CommandExec.RetryMaxDelay; value != "" {
+		delay, err := time.ParseDuration(value)
+		if err == nil && delay >= 0 {
+			e.retryMaxDelay = delay
+		} else if err := e.invalidSetting("retry_max_delay", value, "no cap"); err != nil {
+			return err
+		}
+	}
+
+	e.retryMultiplier = e.cfg.CommandExec.RetryMultiplier
+	switch {
+	case e.retryMultiplier == 0:
+		e.retryMultiplier = defaultRetryMultiplier
+	case e.retryMultiplier < 1:
+		if err := e.invalidSetting("retry_multiplier", strconv.FormatFloat(e.retryMultiplier, 'g', -1, 64), "2"); err != nil {
+			return err
+		}
+		e.retryMultiplier = defaultRetryMultiplier
+	}
+
+	e.retryJitter = e.cfg.CommandExec.RetryJitter
+	if e.retryJitter < 0 || e.retryJitter > 1 {
+		if err := e.invalidSetting("retry_jitter", strconv.FormatFloat(e.retryJitter, 'g', -1, 64), "0"); err != nil {
+			return err
+		}
+		e.retryJitter = 0
+	}
+
+	return nil
+}
+
+// executeWithRetry runs the command and retries it after a backoff while it
+// fails with stderr matching retry_on_output_pattern, e.g. a held git index.lock.
+// The timeout covers all attempts together, so retries never run past it.
+func (e *commandExecutor) executeWithRetry(command string, parts []string, workingDir string, options Options) (types.CommandResult, error) {
+	maxAttempts := e.cfg.CommandExec.RetryMaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = defaultRetryMaxAttempts
+	}
+	backoff := e.capRetryDelay(e.retryBaseDelay)
+
+	var deadline time.Time
+	if options.Timeout > 0 {
+		deadline = e.clock.Now().Add(options.Timeout)
+	}
+
+	attemptOptions := options
+	attemptOptions.configuredTimeout = options.Timeout
+	for attempt := 1; ; attempt++ {
+		result, err := e.executeCommand(command, parts, workingDir, attemptOptions)
+
+		// Streamed output and the exit event cannot be taken back, so never retry them
+		if err == nil || e.retryPattern == nil || options.Stream != nil ||
+			attempt >= maxAttempts || !e.retryPattern.MatchString(result.Stderr) {
+			return result, err
+		}
+
+		delay := e.retryDelay(backoff)
+		if !deadline.IsZero() && !e.clock.Now().Add(delay).Before(deadline) {
+			e.logger.Warnw("command failed with a retryable error, but the timeout leaves no time to retry",
+				"command", command,
+				"attempt", attempt)
+			return result, err
+		}
+
+		e.logger.Warnw("command failed with a retryable error, retrying",
+			"command", command,
+			"attempt", attempt,
+			"backoff", delay)
+
+		e.clock.Sleep(delay)
+
+		// The next attempt gets only what is left of the timeout
+		if !deadline.IsZero() {
+			attemptOptions.Timeout = deadline.Sub(e.clock.Now())
+			if attemptOptions.Timeout <= 0 {
+				return result, err
+			}
+		}
+
+		backoff = e.capRetryDelay(time.Duration(float64(backoff) * e.retryMultiplier))
+	}
+}
+
+// capRetryDelay limits a backoff to retry_max_delay, when one is set
+func (e *commandExecutor) capRetryDelay(backoff time.Duration) time.Duration {
+	if e.retryMaxDelay > 0 && backoff > e.retryMaxDelay {
+		return e.retryMaxDelay
+	}
+	return backoff
+}
+
+// retryDelay shortens the backoff by a random fraction of up to retry_jitter
+func (e *commandExecutor) retryDelay(backoff time.Duration) time.Duration {
+	if e.retryJitter == 0 {
+		return backoff
+	}
+	return backoff - time.Duration(e.random()*e.retryJitter*float64(backoff))
+}
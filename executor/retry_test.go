@@ -0,0 +1,214 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sleepRecorder - Clock that records sleeps instead of waiting
+type sleepRecorder struct {
+	fakeClock
+	sleeps []time.Duration
+}
+
+func (c *sleepRecorder) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+}
+
+// advancingClock - Clock whose sleeps move its time forward instead of waiting
+type advancingClock struct {
+	now    time.Time
+	sleeps []time.Duration
+}
+
+func (c *advancingClock) Now() time.Time {
+	return c.now
+}
+
+func (c *advancingClock) Sleep(d time.Duration) {
+	c.sleeps = append(c.sleeps, d)
+	c.now = c.now.Add(d)
+}
+
+// lockStub fails with a git lock message until the marker file exists
+const lockStub = `if [ -e marker ]; then echo done; else touch marker; echo "fatal: Unable to create '.git/index.lock': File exists." >&2; exit 128; fi`
+
+// TestExecuteRetryOnOutputPattern - Test retrying a command whose stderr matches the pattern
+func TestExecuteRetryOnOutputPattern(t *testing.T) {
+	clock := &sleepRecorder{}
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock.*File exists`
+		cfg.CommandExec.RetryBackoffMs = 50
+	}, WithClock(clock))
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", lockStub}})
+	require.NoError(t, err)
+	assert.Equal(t, "done\n", result.Stdout)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond}, clock.sleeps)
+	assert.FileExists(t, filepath.Join(dir, "marker"))
+}
+
+// TestExecuteRetryNotMatching - Test that other failures are not retried
+func TestExecuteRetryNotMatching(t *testing.T) {
+	clock := &sleepRecorder{}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+	}, WithClock(clock))
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo boom >&2; exit 1"}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+	assert.Empty(t, clock.sleeps)
+}
+
+// TestExecuteRetryMaxAttempts - Test that retries stop after the configured attempts
+func TestExecuteRetryMaxAttempts(t *testing.T) {
+	clock := &sleepRecorder{}
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryMaxAttempts = 3
+		cfg.CommandExec.RetryBackoffMs = 10
+	}, WithClock(clock))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "count"), nil, 0644))
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo x >> count; echo index.lock >&2; exit 128"}})
+	assert.Error(t, err)
+	assert.Equal(t, 128, result.ExitCode)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond}, clock.sleeps)
+
+	count, err := os.ReadFile(filepath.Join(dir, "count"))
+	require.NoError(t, err)
+	assert.Equal(t, "x\nx\nx\n", string(count))
+}
+
+// lockFailure always fails with a retryable message
+var lockFailure = []string{"-c", "echo index.lock >&2; exit 128"}
+
+// TestExecuteRetryBackoffPolicy - Test the delays from retry_base_delay, retry_multiplier and retry_max_delay
+func TestExecuteRetryBackoffPolicy(t *testing.T) {
+	clock := &sleepRecorder{}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryMaxAttempts = 5
+		cfg.CommandExec.RetryBackoffMs = 500
+		cfg.CommandExec.RetryBaseDelay = "10ms"
+		cfg.CommandExec.RetryMultiplier = 3
+		cfg.CommandExec.RetryMaxDelay = "100ms"
+	}, WithClock(clock))
+
+	_, err := cmdExecutor.Execute("sh", Options{Args: lockFailure})
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 90 * time.Millisecond, 100 * time.Millisecond}, clock.sleeps)
+}
+
+// TestExecuteRetryMaxDelayFirst - Test that retry_max_delay also caps the first delay
+func TestExecuteRetryMaxDelayFirst(t *testing.T) {
+	clock := &sleepRecorder{}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryBaseDelay = "200ms"
+		cfg.CommandExec.RetryMaxDelay = "50ms"
+	}, WithClock(clock))
+
+	_, err := cmdExecutor.Execute("sh", Options{Args: lockFailure})
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{50 * time.Millisecond, 50 * time.Millisecond}, clock.sleeps)
+}
+
+// TestExecuteRetryJitter - Test that jitter shortens each delay by the random fraction of retry_jitter
+func TestExecuteRetryJitter(t *testing.T) {
+	clock := &sleepRecorder{}
+	fractions := []float64{0, 0.5, 0.25}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryMaxAttempts = 4
+		cfg.CommandExec.RetryBackoffMs = 100
+		cfg.CommandExec.RetryJitter = 0.5
+	}, WithClock(clock), WithRandom(func() float64 {
+		fraction := fractions[0]
+		fractions = fractions[1:]
+		return fraction
+	}))
+
+	_, err := cmdExecutor.Execute("sh", Options{Args: lockFailure})
+	assert.Error(t, err)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 150 * time.Millisecond, 350 * time.Millisecond}, clock.sleeps)
+}
+
+// TestExecuteRetryDeadline - Test that the timeout caps retries across all attempts
+func TestExecuteRetryDeadline(t *testing.T) {
+	clock := &advancingClock{now: time.Now()}
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryMaxAttempts = 10
+		cfg.CommandExec.RetryBackoffMs = 100
+		cfg.CommandExec.RetryMultiplier = 3
+	}, WithClock(clock))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "count"), nil, 0644))
+
+	// A third backoff of 900ms would end past the 1s timeout
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "echo x >> count; echo index.lock >&2; exit 128"},
+		Timeout: time.Second,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 128, result.ExitCode)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond, 300 * time.Millisecond}, clock.sleeps)
+
+	count, err := os.ReadFile(filepath.Join(dir, "count"))
+	require.NoError(t, err)
+	assert.Equal(t, "x\nx\nx\n", string(count))
+}
+
+// TestExecuteRetryTimeoutError - Test that a retry running out of time reports the configured timeout
+func TestExecuteRetryTimeoutError(t *testing.T) {
+	clock := &advancingClock{now: time.Now()}
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RetryOnOutputPattern = `index\.lock`
+		cfg.CommandExec.RetryBaseDelay = "100ms"
+	}, WithClock(clock))
+
+	// The first attempt fails with a retryable error, the retry hangs
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "if [ -e marker ]; then exec sleep 5; fi; touch marker; echo index.lock >&2; exit 128"},
+		Timeout: 300 * time.Millisecond,
+	})
+	assert.EqualError(t, err, "command timed out after 300ms")
+	assert.Equal(t, 124, result.ExitCode)
+	assert.Equal(t, []time.Duration{100 * time.Millisecond}, clock.sleeps)
+}
+
+// TestInvalidRetryBackoff - Test that out-of-range backoff settings fall back or fail under strict_config
+func TestInvalidRetryBackoff(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.PathBehavior = "prepend"
+	cfg.CommandExec.RetryBackoffMs = 50
+	cfg.CommandExec.RetryBaseDelay = "soon"
+	cfg.CommandExec.RetryMaxDelay = "-1s"
+	cfg.CommandExec.RetryMultiplier = 0.5
+	cfg.CommandExec.RetryJitter = 2
+	cmdExecutor, err := newCommandExecutor(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 50*time.Millisecond, cmdExecutor.retryBaseDelay)
+	assert.Zero(t, cmdExecutor.retryMaxDelay)
+	assert.Equal(t, 2.0, cmdExecutor.retryMultiplier)
+	assert.Equal(t, 0.0, cmdExecutor.retryJitter)
+
+	cfg.CommandExec.StrictConfig = true
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid retry_base_delay setting")
+}
+
+// TestInvalidRetryPattern - Test that an invalid pattern fails startup
+func TestInvalidRetryPattern(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.RetryOnOutputPattern = "("
+	_, err := newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "invalid retry_on_output_pattern")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package executor
+
+import "os"
+
+// maxRSSBytes is not available outside Unix
+func maxRSSBytes(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package executor
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// maxRSSBytes returns the peak resident set size of the finished process
+func maxRSSBytes(state *os.ProcessState) int64 {
+	if state == nil {
+		return 0
+	}
+
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+
+	// Maxrss is reported in bytes on Darwin and in kilobytes elsewhere
+	if runtime.GOOS == "darwin" || runtime.GOOS == "ios" {
+		return int64(rusage.Maxrss)
+	}
+	return int64(rusage.Maxrss) * 1024
+}
@@ -0,0 +1,24 @@
+//go:build unix
+
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteMaxRSSBytes - Test that the peak memory of the child is reported
+func TestExecuteMaxRSSBytes(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", `i=0; while [ $i -lt 2000 ]; do x="$x$i"; i=$((i+1)); done`}})
+	require.NoError(t, err)
+	assert.Greater(t, result.MaxRSSBytes, int64(0))
+
+	// Built-in commands do not start a process
+	result, err = cmdExecutor.Execute("pwd", Options{})
+	require.NoError(t, err)
+	assert.Zero(t, result.MaxRSSBytes)
+}
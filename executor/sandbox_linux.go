@@ -0,0 +1,70 @@
+//go:build linux
+
+package executor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+)
+
+// namespaceFlags maps the supported namespaces config values to clone flags
+var namespaceFlags = map[string]uintptr{
+	"mount": syscall.CLONE_NEWNS,
+	"pid":   syscall.CLONE_NEWPID,
+	"ipc":   syscall.CLONE_NEWIPC,
+	"uts":   syscall.CLONE_NEWUTS,
+	"net":   syscall.CLONE_NEWNET,
+}
+
+// validateSandbox checks the chroot_dir and namespaces settings at startup
+func (e *commandExecutor) validateSandbox() error {
+	if dir := e.cfg.CommandExec.ChrootDir; dir != "" {
+		info, err := e.fs.Stat(dir)
+		if err != nil {
+			return errors.Wrap(err, "invalid chroot_dir")
+		}
+		if !info.IsDir() {
+			return fmt.Errorf("invalid chroot_dir: %s is not a directory", dir)
+		}
+	}
+
+	for _, ns := range e.cfg.CommandExec.Namespaces {
+		if _, ok := namespaceFlags[ns]; !ok {
+			return fmt.Errorf("unsupported namespace: %s", ns)
+		}
+	}
+
+	return nil
+}
+
+// sandboxAttr returns the process attributes that apply chroot_dir and namespaces
+func (e *commandExecutor) sandboxAttr() *syscall.SysProcAttr {
+	if e.cfg.CommandExec.ChrootDir == "" && len(e.cfg.CommandExec.Namespaces) == 0 {
+		return nil
+	}
+
+	attr := &syscall.SysProcAttr{Chroot: e.cfg.CommandExec.ChrootDir}
+	for _, ns := range e.cfg.CommandExec.Namespaces {
+		attr.Cloneflags |= namespaceFlags[ns]
+	}
+	return attr
+}
+
+// sandboxDir translates a host working directory into the path seen inside
+// chroot_dir. Directories outside the chroot map to its root.
+func (e *commandExecutor) sandboxDir(dir string) string {
+	root := e.cfg.CommandExec.ChrootDir
+	if root == "" {
+		return dir
+	}
+
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "/"
+	}
+	return filepath.Join("/", rel)
+}
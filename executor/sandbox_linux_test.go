@@ -0,0 +1,86 @@
+//go:build linux
+
+package executor
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSandboxConfigValidation - Test that invalid sandbox settings fail startup
+func TestSandboxConfigValidation(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.ChrootDir = "/nonexistent/chroot"
+	_, err := newCommandExecutor(cfg)
+	assert.Error(t, err)
+
+	cfg = &config.Config{}
+	cfg.CommandExec.Namespaces = []string{"time-travel"}
+	_, err = newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "unsupported namespace: time-travel")
+}
+
+// TestSandboxAttr - Test the process attributes and working directory inside the chroot
+func TestSandboxAttr(t *testing.T) {
+	root := t.TempDir()
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.ChrootDir = root
+		cfg.CommandExec.Namespaces = []string{"mount", "pid"}
+	})
+
+	attr := cmdExecutor.sandboxAttr()
+	require.NotNil(t, attr)
+	assert.Equal(t, root, attr.Chroot)
+	assert.Equal(t, uintptr(syscall.CLONE_NEWNS|syscall.CLONE_NEWPID), attr.Cloneflags)
+
+	assert.Equal(t, "/src", cmdExecutor.sandboxDir(filepath.Join(root, "src")))
+	assert.Equal(t, "/", cmdExecutor.sandboxDir(root))
+	assert.Equal(t, "/", cmdExecutor.sandboxDir("/somewhere/else"))
+}
+
+// TestExecuteInChroot - Test that commands run inside chroot_dir (requires root)
+func TestExecuteInChroot(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("chroot requires root privileges")
+	}
+
+	// The host binary works without a chroot
+	cmdExecutor, _ := newTestExecutor(t, nil)
+	_, err := cmdExecutor.Execute("echo hello", Options{})
+	require.NoError(t, err)
+
+	// An empty chroot has no echo binary to execute
+	root := t.TempDir()
+	cmdExecutor, _ = newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.ChrootDir = root
+	})
+	result, err := cmdExecutor.Execute("echo hello", Options{})
+	require.Error(t, err)
+	assert.ErrorIs(t, err, syscall.ENOENT)
+	assert.Empty(t, result.Stdout)
+}
+
+// TestExecuteInPIDNamespace - Test that commands run in a new PID namespace (requires root)
+func TestExecuteInPIDNamespace(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("namespaces require root privileges")
+	}
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.Namespaces = []string{"pid"}
+	})
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo $$"}})
+	if errors.Is(err, syscall.EPERM) {
+		t.Skipf("namespaces unavailable: %v", err)
+	}
+	require.NoError(t, err)
+	assert.Equal(t, "1\n", result.Stdout)
+}
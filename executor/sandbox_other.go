@@ -0,0 +1,27 @@
+//go:build !linux
+
+package executor
+
+import (
+	"syscall"
+
+	"github.com/cockroachdb/errors"
+)
+
+// validateSandbox rejects chroot_dir and namespaces, which require Linux
+func (e *commandExecutor) validateSandbox() error {
+	if e.cfg.CommandExec.ChrootDir != "" || len(e.cfg.CommandExec.Namespaces) > 0 {
+		return errors.New("chroot_dir and namespaces are only supported on Linux")
+	}
+	return nil
+}
+
+// sandboxAttr returns no process attributes outside Linux
+func (e *commandExecutor) sandboxAttr() *syscall.SysProcAttr {
+	return nil
+}
+
+// sandboxDir returns the directory unchanged outside Linux
+func (e *commandExecutor) sandboxDir(dir string) string {
+	return dir
+}
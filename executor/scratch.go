@@ -0,0 +1,52 @@
+package executor
+
+import (
+	"os"
+
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+)
+
+// executeInScratchDir runs the command in a new directory under scratch_root,
+// which is removed afterwards unless options.KeepScratch is set
+func (e *commandExecutor) executeInScratchDir(command string, parts []string, options Options) (types.CommandResult, error) {
+	fail := func(err error) (types.CommandResult, error) {
+		return types.CommandResult{
+			Command:    command,
+			WorkingDir: e.GetCurrentWorkingDir(),
+			ExitCode:   1,
+			Error:      err.Error(),
+		}, err
+	}
+
+	if options.WorkingDir != "" {
+		return fail(errors.New("scratch_dir cannot be combined with working_dir"))
+	}
+
+	root := e.cfg.CommandExec.ScratchRoot
+	if root == "" {
+		return fail(errors.New("scratch directories are not available: scratch_root is not configured"))
+	}
+	if err := e.checkWorkingDir(root); err != nil {
+		return fail(errors.Wrap(err, "invalid scratch_root"))
+	}
+
+	dir, err := os.MkdirTemp(root, "scratch-")
+	if err != nil {
+		return fail(errors.Wrap(err, "failed to create scratch directory"))
+	}
+
+	if !options.KeepScratch {
+		defer func() {
+			if err := os.RemoveAll(dir); err != nil {
+				e.logger.Warnw("failed to remove scratch directory",
+					"dir", dir,
+					"error", err)
+			}
+		}()
+	}
+
+	result, err := e.dispatch(command, parts, dir, true, options)
+	result.ScratchDir = dir
+	return result, err
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExecuteScratchDir - Test that scratch directories are created, used, and cleaned up
+func TestExecuteScratchDir(t *testing.T) {
+	var root string
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"sh", "cd"}
+		root = filepath.Join(cfg.CommandExec.DefaultWorkingDir, "scratch")
+		cfg.CommandExec.ScratchRoot = root
+	})
+	require.NoError(t, os.Mkdir(root, 0755))
+
+	result, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "touch made && pwd"}, ScratchDir: true})
+	require.NoError(t, err)
+	assert.Equal(t, root, filepath.Dir(result.ScratchDir))
+	assert.Equal(t, result.ScratchDir+"\n", result.Stdout)
+	assert.NoDirExists(t, result.ScratchDir)
+
+	// The current directory is untouched
+	assert.Equal(t, dir, cmdExecutor.GetCurrentWorkingDir())
+
+	// Kept on request
+	result, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", "touch made"}, ScratchDir: true, KeepScratch: true})
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(result.ScratchDir, "made"))
+
+	// cd cannot change into a directory that is about to disappear
+	_, err = cmdExecutor.Execute("cd /", Options{ScratchDir: true})
+	assert.Error(t, err)
+	_, err = cmdExecutor.Execute("sh", Options{ScratchDir: true, WorkingDir: dir})
+	assert.ErrorContains(t, err, "scratch_dir cannot be combined with working_dir")
+}
+
+// TestExecuteScratchDirRequiresRoot - Test that scratch directories need a scratch_root within the allowed dirs
+func TestExecuteScratchDirRequiresRoot(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+	_, err := cmdExecutor.Execute("echo hi", Options{ScratchDir: true})
+	assert.ErrorContains(t, err, "scratch_root is not configured")
+
+	cmdExecutor, _ = newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.ScratchRoot = t.TempDir()
+	})
+	_, err = cmdExecutor.Execute("echo hi", Options{ScratchDir: true})
+	assert.ErrorContains(t, err, "invalid scratch_root")
+}
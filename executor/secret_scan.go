@@ -0,0 +1,95 @@
+package executor
+
+import (
+	"math"
+	"regexp"
+	"strings"
+)
+
+// secretPatterns match well-known credential formats
+var secretPatterns = []*regexp.Regexp{
+	// AWS access key IDs
+	regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`),
+	// PEM private keys, through the end marker when present
+	regexp.MustCompile(`(?s)-----BEGIN [A-Z ]*PRIVATE KEY-----.*?(?:-----END [A-Z ]*PRIVATE KEY-----|\z)`),
+	// GitHub tokens
+	regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`),
+	// Slack tokens
+	regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`),
+}
+
+// highEntropyCandidate matches long runs of token characters that may be random secrets
+var highEntropyCandidate = regexp.MustCompile(`[A-Za-z0-9+/=_-]{32,}`)
+
+// minSecretEntropy is the Shannon entropy, in bits per character, above which a
+// candidate looks random. Hex (at most 4 bits) such as commit hashes stays below it.
+const minSecretEntropy = 4.5
+
+// scanSecrets looks for likely secrets in s, returning s with them redacted
+// and whether any were found
+func scanSecrets(s string) (string, bool) {
+	found := false
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllStringFunc(s, func(string) string {
+			found = true
+			return redactedValue
+		})
+	}
+
+	s = highEntropyCandidate.ReplaceAllStringFunc(s, func(match string) string {
+		if !looksRandom(match) {
+			return match
+		}
+		found = true
+		return redactedValue
+	})
+
+	return s, found
+}
+
+// looksRandom reports whether a token mixes character classes with high entropy
+func looksRandom(token string) bool {
+	if !strings.ContainsAny(token, "abcdefghijklmnopqrstuvwxyz") ||
+		!strings.ContainsAny(token, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") ||
+		!strings.ContainsAny(token, "0123456789") {
+		return false
+	}
+	return shannonEntropy(token) >= minSecretEntropy
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	counts := make(map[rune]int)
+	for _, r := range s {
+		counts[r]++
+	}
+
+	n := float64(len(s))
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / n
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// scanResultSecrets flags, and with redact_detected_secrets redacts, likely
+// secrets in the command output when scan_output_for_secrets is enabled
+func (e *commandExecutor) scanResultSecrets(stdout, stderr, combined *string) bool {
+	if !e.cfg.CommandExec.ScanOutputForSecrets {
+		return false
+	}
+
+	detected := false
+	for _, s := range []*string{stdout, stderr, combined} {
+		redacted, found := scanSecrets(*s)
+		if !found {
+			continue
+		}
+		detected = true
+		if e.cfg.CommandExec.RedactDetectedSecrets {
+			*s = redacted
+		}
+	}
+	return detected
+}
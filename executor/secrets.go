@@ -0,0 +1,219 @@
+package executor
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// SecretResolver resolves secret references into their values
+type SecretResolver interface {
+	// Resolve returns the secret value for the reference
+	Resolve(ref string) (string, error)
+}
+
+// SecretResolverFunc adapts an ordinary function to the SecretResolver interface
+type SecretResolverFunc func(ref string) (string, error)
+
+// Resolve calls f(ref)
+func (f SecretResolverFunc) Resolve(ref string) (string, error) {
+	return f(ref)
+}
+
+// fileSecretResolver resolves references to files under a secrets directory
+type fileSecretResolver struct {
+	dir string
+}
+
+// NewFileSecretResolver creates a SecretResolver that reads each reference as a
+// file path relative to dir. Trailing newlines are removed from the value.
+func NewFileSecretResolver(dir string) SecretResolver {
+	return &fileSecretResolver{dir: dir}
+}
+
+// Resolve reads the secret file named by ref
+func (r *fileSecretResolver) Resolve(ref string) (string, error) {
+	if !filepath.IsLocal(ref) {
+		return "", errors.Newf("invalid secret reference: %s", ref)
+	}
+
+	data, err := os.ReadFile(filepath.Join(r.dir, ref))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read secret %s", ref)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveSecrets resolves secret references into environment variable values
+func (e *commandExecutor) resolveSecrets(refs map[string]string) (map[string]string, error) {
+	if e.secretResolver == nil {
+		return nil, errors.New("secret_refs provided but no secret source is configured")
+	}
+
+	secrets := make(map[string]string, len(refs))
+	for key, ref := range refs {
+		value, err := e.secretResolver.Resolve(ref)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to resolve secret for %s", key)
+		}
+		secrets[key] = value
+	}
+
+	return secrets, nil
+}
+
+// redactSecrets replaces every occurrence of the secret values in s
+func redactSecrets(s string, secrets map[string]string) string {
+	for _, value := range secretValues(secrets) {
+		s = strings.ReplaceAll(s, value, redactedValue)
+	}
+	return s
+}
+
+// secretValues returns the non-empty secret values, longest first so a value
+// containing another is fully redacted
+func secretValues(secrets map[string]string) []string {
+	values := make([]string, 0, len(secrets))
+	for _, value := range secrets {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	return values
+}
+
+// redactingWriter redacts secret values from output on its way to w. A secret
+// can be split across writes, so the tail that could still be the start of one
+// is held back until later output or Flush settles it.
+type redactingWriter struct {
+	mu      sync.Mutex
+	w       io.Writer
+	secrets map[string]string
+	values  []string
+	pending []byte
+}
+
+// newRedactingWriter creates a writer that redacts the secret values before writing to w
+func newRedactingWriter(w io.Writer, secrets map[string]string) *redactingWriter {
+	return &redactingWriter{w: w, secrets: secrets, values: secretValues(secrets)}
+}
+
+// Write implements io.Writer
+func (r *redactingWriter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pending = append(r.pending, p...)
+	cut := r.safeCut()
+	if cut == 0 {
+		return len(p), nil
+	}
+
+	out := redactSecrets(string(r.pending[:cut]), r.secrets)
+	r.pending = append(r.pending[:0], r.pending[cut:]...)
+	if _, err := io.WriteString(r.w, out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush redacts and writes the output held back so far
+func (r *redactingWriter) Flush() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pending) == 0 {
+		return nil
+	}
+	out := redactSecrets(string(r.pending), r.secrets)
+	r.pending = r.pending[:0]
+	_, err := io.WriteString(r.w, out)
+	return err
+}
+
+// safeCut returns how much of the pending output can be written: everything
+// except a tail shorter than the longest secret, and never in the middle of a
+// secret that is already complete
+func (r *redactingWriter) safeCut() int {
+	if len(r.values) == 0 {
+		return len(r.pending)
+	}
+
+	cut := len(r.pending) - (len(r.values[0]) - 1)
+	if cut <= 0 {
+		return 0
+	}
+
+	// Move the cut before any secret that straddles it, until none does
+	for moved := true; moved; {
+		moved = false
+		for _, value := range r.values {
+			start := max(cut-len(value)+1, 0)
+			if i := bytes.Index(r.pending[start:], []byte(value)); i >= 0 && start+i < cut {
+				cut = start + i
+				moved = true
+			}
+		}
+	}
+	return cut
+}
+
+// redactingSink redacts the secret values from the output forwarded to sink
+type redactingSink struct {
+	sink   StreamSink
+	stdout *redactingWriter
+	stderr *redactingWriter
+}
+
+// newRedactingSink wraps sink so streamed output is redacted like the result
+func newRedactingSink(sink StreamSink, secrets map[string]string) *redactingSink {
+	return &redactingSink{
+		sink:   sink,
+		stdout: newRedactingWriter(sinkWriter{sink: sink, stream: StreamStdout}, secrets),
+		stderr: newRedactingWriter(sinkWriter{sink: sink, stream: StreamStderr}, secrets),
+	}
+}
+
+// Output implements StreamSink
+func (s *redactingSink) Output(stream string, data []byte) {
+	if stream == StreamStderr {
+		s.stderr.Write(data)
+		return
+	}
+	s.stdout.Write(data)
+}
+
+// Exit implements StreamSink, delivering the output held back first
+func (s *redactingSink) Exit(event ExitEvent) {
+	s.stdout.Flush()
+	s.stderr.Flush()
+	s.sink.Exit(event)
+}
+
+// Progress implements ProgressSink when the wrapped sink does
+func (s *redactingSink) Progress(percent float64) {
+	if progressSink, ok := s.sink.(ProgressSink); ok {
+		progressSink.Progress(percent)
+	}
+}
+
+// sinkWriter adapts one stream of a StreamSink to io.Writer. The redacting
+// writer hands it a fresh slice on every write, so p is passed on without a copy.
+type sinkWriter struct {
+	sink   StreamSink
+	stream string
+}
+
+// Write implements io.Writer
+func (w sinkWriter) Write(p []byte) (int, error) {
+	w.sink.Output(w.stream, p)
+	return len(p), nil
+}
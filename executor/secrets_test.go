@@ -0,0 +1,161 @@
+package executor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestExecuteSecretRefs - Test that secrets are injected but redacted from output and logs
+func TestExecuteSecretRefs(t *testing.T) {
+	secretsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(secretsDir, "deploy-key"), []byte("s3cr3t-value\n"), 0600))
+
+	core, logs := observer.New(zap.DebugLevel)
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SecretsDir = secretsDir
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "env")
+	}, WithLogger(zap.New(core).Sugar()))
+
+	options := Options{SecretRefs: map[string]string{"DEPLOY_VALUE": "deploy-key"}}
+
+	// The child sees the secret value, without a trailing newline
+	options.Args = []string{"-c", `echo ${#DEPLOY_VALUE}`}
+	result, err := cmdExecutor.Execute("sh", options)
+	require.NoError(t, err)
+	assert.Equal(t, "12\n", result.Stdout)
+
+	// Output containing the value is redacted
+	options.Args = []string{"-c", `echo "out:$DEPLOY_VALUE"; echo "err:$DEPLOY_VALUE" >&2`}
+	result, err = cmdExecutor.Execute("sh", options)
+	require.NoError(t, err)
+	assert.Equal(t, "out:[REDACTED]\n", result.Stdout)
+	assert.Equal(t, "err:[REDACTED]\n", result.Stderr)
+
+	// So is the env built-in, even though the name does not look sensitive
+	result, err = cmdExecutor.Execute("env", Options{SecretRefs: options.SecretRefs})
+	require.NoError(t, err)
+	assert.Contains(t, result.Stdout, "DEPLOY_VALUE=[REDACTED]\n")
+	assert.NotContains(t, result.Stdout, "s3cr3t-value")
+
+	// Nothing logged contains the value
+	for _, entry := range logs.AllUntimed() {
+		assert.NotContains(t, entry.Message, "s3cr3t-value")
+		for _, value := range entry.ContextMap() {
+			assert.NotContains(t, fmt.Sprint(value), "s3cr3t-value")
+		}
+	}
+}
+
+// TestExecuteSecretRefsCombined - Test that the combined output is redacted for every merge order
+func TestExecuteSecretRefsCombined(t *testing.T) {
+	secretsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(secretsDir, "deploy-key"), []byte("s3cr3t-value\n"), 0600))
+
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SecretsDir = secretsDir
+	})
+
+	for _, order := range []string{mergeInterleaved, mergeStdoutFirst, mergeStderrFirst} {
+		result, err := cmdExecutor.Execute("sh", Options{
+			Args:       []string{"-c", `echo "out:$DEPLOY_VALUE"; echo "err:$DEPLOY_VALUE" >&2`},
+			SecretRefs: map[string]string{"DEPLOY_VALUE": "deploy-key"},
+			MergeOrder: order,
+		})
+		require.NoError(t, err, order)
+		assert.Contains(t, result.Combined, "out:[REDACTED]\n", order)
+		assert.Contains(t, result.Combined, "err:[REDACTED]\n", order)
+		assert.NotContains(t, result.Combined, "s3cr3t-value", order)
+	}
+}
+
+// TestExecuteSecretRefsStreamAndTee - Test that streamed and teed output is redacted too
+func TestExecuteSecretRefsStreamAndTee(t *testing.T) {
+	secretsDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(secretsDir, "deploy-key"), []byte("s3cr3t-value\n"), 0600))
+
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SecretsDir = secretsDir
+	})
+	refs := map[string]string{"DEPLOY_VALUE": "deploy-key"}
+
+	// The value is printed in pieces so it spans several writes
+	script := `printf 'out:s3cr'; sleep 0.05; printf '3t-val'; sleep 0.05; echo "ue:$DEPLOY_VALUE"; echo "err:$DEPLOY_VALUE" >&2`
+
+	sink := newRecordingSink()
+	_, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", script}, SecretRefs: refs, Stream: sink})
+	require.NoError(t, err)
+	assert.Equal(t, "out:[REDACTED]:[REDACTED]\n", sink.output[StreamStdout].String())
+	assert.Equal(t, "err:[REDACTED]\n", sink.output[StreamStderr].String())
+	assert.Len(t, sink.exits, 1)
+	assert.False(t, sink.outputAfterExit)
+
+	_, err = cmdExecutor.Execute("sh", Options{Args: []string{"-c", script}, SecretRefs: refs, TeeFile: "out.log"})
+	require.NoError(t, err)
+	data, err := os.ReadFile(filepath.Join(dir, "out.log"))
+	require.NoError(t, err)
+	// The streams share the file, so only what each contributes is checked
+	assert.NotContains(t, string(data), "s3cr3t-value")
+	assert.Equal(t, 3, strings.Count(string(data), "[REDACTED]"))
+}
+
+// TestRedactingWriter - Test redaction of secrets split across writes
+func TestRedactingWriter(t *testing.T) {
+	var out strings.Builder
+	w := newRedactingWriter(&out, map[string]string{"A": "secret", "B": "token-xyz"})
+
+	for _, chunk := range []string{"a sec", "ret b to", "k", "en-x", "yz c secr"} {
+		n, err := w.Write([]byte(chunk))
+		require.NoError(t, err)
+		assert.Equal(t, len(chunk), n)
+		assert.NotContains(t, out.String(), "sec")
+		assert.NotContains(t, out.String(), "tok")
+	}
+
+	// The tail that could still have been a secret is written on Flush
+	require.NoError(t, w.Flush())
+	assert.Equal(t, "a [REDACTED] b [REDACTED] c secr", out.String())
+}
+
+// TestExecuteSecretRefsErrors - Test unresolvable secret references
+func TestExecuteSecretRefsErrors(t *testing.T) {
+	// No secret source configured
+	cmdExecutor, _ := newTestExecutor(t, nil)
+	result, err := cmdExecutor.Execute("echo hi", Options{SecretRefs: map[string]string{"KEY": "ref"}})
+	assert.Error(t, err)
+	assert.Equal(t, 1, result.ExitCode)
+
+	// References cannot escape the secrets directory
+	secretsDir := t.TempDir()
+	cmdExecutor, _ = newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.SecretsDir = secretsDir
+	})
+	_, err = cmdExecutor.Execute("echo hi", Options{SecretRefs: map[string]string{"KEY": "../outside"}})
+	assert.ErrorContains(t, err, "invalid secret reference")
+
+	_, err = cmdExecutor.Execute("echo hi", Options{SecretRefs: map[string]string{"KEY": "missing"}})
+	assert.ErrorContains(t, err, "failed to resolve secret for KEY")
+}
+
+// TestWithSecretResolver - Test a custom secret source
+func TestWithSecretResolver(t *testing.T) {
+	resolver := SecretResolverFunc(func(ref string) (string, error) {
+		return "value-of-" + ref, nil
+	})
+	cmdExecutor, _ := newTestExecutor(t, nil, WithSecretResolver(resolver))
+
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:       []string{"-c", `[ "$KEY" = "value-of-vault/key" ] && echo ok`},
+		SecretRefs: map[string]string{"KEY": "vault/key"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "ok\n", result.Stdout)
+}
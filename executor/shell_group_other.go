@@ -0,0 +1,13 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// startInGroup is a no-op outside Unix
+func startInGroup(cmd *exec.Cmd) {}
+
+// killShell kills only the shell outside Unix
+func killShell(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
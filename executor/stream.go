@@ -0,0 +1,74 @@
+package executor
+
+import (
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// Stream names passed to StreamSink.Output
+const (
+	StreamStdout = "stdout"
+	StreamStderr = "stderr"
+)
+
+// StreamSink receives command output incrementally while the command runs.
+// Output may be called concurrently for stdout and stderr.
+type StreamSink interface {
+	// Output is called with each chunk of output as it is produced
+	Output(stream string, data []byte)
+
+	// Exit is called once after all output has been delivered
+	Exit(event ExitEvent)
+}
+
+// ExitEvent is the final streaming event marking the end of the output stream
+type ExitEvent struct {
+	ExitCode        int    `json:"exit_code"`
+	DurationMs      int64  `json:"duration_ms"`
+	Signal          string `json:"signal,omitempty"`
+	StdoutTruncated bool   `json:"stdout_truncated"`
+	StderrTruncated bool   `json:"stderr_truncated"`
+}
+
+// streamWriter captures output into a buffer and forwards it to a StreamSink.
+// Once the buffer's limit is reached, nothing more is forwarded and onTruncate
+// is called so an unbounded producer can be stopped.
+type streamWriter struct {
+	stream     string
+	buf        *limitedBuffer
+	sink       StreamSink
+	onTruncate func()
+	once       sync.Once
+}
+
+// Write implements io.Writer
+func (w *streamWriter) Write(p []byte) (int, error) {
+	before := w.buf.buf.Len()
+	n, err := w.buf.Write(p)
+
+	// Forward only what was kept; copy since the caller may reuse p after Write returns
+	if kept := w.buf.buf.Len() - before; kept > 0 {
+		w.sink.Output(w.stream, append([]byte(nil), p[:kept]...))
+	}
+
+	if w.buf.truncated && w.onTruncate != nil {
+		w.once.Do(w.onTruncate)
+	}
+	return n, err
+}
+
+// terminationSignal returns the name of the signal that terminated the process, if any
+func terminationSignal(err error) string {
+	exitErr, ok := err.(*exec.ExitError)
+	if !ok {
+		return ""
+	}
+
+	status, ok := exitErr.Sys().(syscall.WaitStatus)
+	if !ok || !status.Signaled() {
+		return ""
+	}
+
+	return status.Signal().String()
+}
@@ -0,0 +1,180 @@
+package executor
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingSink - StreamSink that records every event
+type recordingSink struct {
+	mu     sync.Mutex
+	output map[string]*strings.Builder
+	exits  []ExitEvent
+	// progress records reported percentages
+	progress []float64
+	// outputAfterExit is set if output arrives after the exit event
+	outputAfterExit bool
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{output: map[string]*strings.Builder{
+		StreamStdout: {},
+		StreamStderr: {},
+	}}
+}
+
+func (s *recordingSink) Output(stream string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.exits) > 0 {
+		s.outputAfterExit = true
+	}
+	s.output[stream].Write(data)
+}
+
+func (s *recordingSink) Progress(percent float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.progress = append(s.progress, percent)
+}
+
+func (s *recordingSink) Exit(event ExitEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exits = append(s.exits, event)
+}
+
+// TestExecuteStreamExitEvent - Test that streaming ends with an exit event
+func TestExecuteStreamExitEvent(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	sink := newRecordingSink()
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:   []string{"-c", "echo out; echo err >&2; exit 3"},
+		Stream: sink,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, 3, result.ExitCode)
+
+	assert.Equal(t, "out\n", sink.output[StreamStdout].String())
+	assert.Equal(t, "err\n", sink.output[StreamStderr].String())
+	assert.False(t, sink.outputAfterExit)
+
+	require.Len(t, sink.exits, 1)
+	assert.Equal(t, 3, sink.exits[0].ExitCode)
+	assert.GreaterOrEqual(t, sink.exits[0].DurationMs, int64(0))
+	assert.Empty(t, sink.exits[0].Signal)
+	assert.False(t, sink.exits[0].StdoutTruncated)
+	assert.False(t, sink.exits[0].StderrTruncated)
+}
+
+// TestExecuteStreamSignal - Test that the exit event reports a terminating signal
+func TestExecuteStreamSignal(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	sink := newRecordingSink()
+	_, err := cmdExecutor.Execute("sh", Options{
+		Args:   []string{"-c", "kill -TERM $$"},
+		Stream: sink,
+	})
+	assert.Error(t, err)
+
+	require.Len(t, sink.exits, 1)
+	assert.Equal(t, "terminated", sink.exits[0].Signal)
+}
+
+// TestExecuteStreamCapped - Test that a streaming command is killed once it exceeds the output cap
+func TestExecuteStreamCapped(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.MaxOutputBytes = 10
+	})
+
+	sink := newRecordingSink()
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:   []string{"-c", "while :; do echo 0123456789; done"},
+		Stream: sink,
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "0123456789", result.Stdout)
+	assert.True(t, result.StdoutTruncated)
+
+	// Nothing beyond the cap is streamed
+	assert.Equal(t, "0123456789", sink.output[StreamStdout].String())
+
+	require.Len(t, sink.exits, 1)
+	assert.True(t, sink.exits[0].StdoutTruncated)
+	assert.Equal(t, "killed", sink.exits[0].Signal)
+}
+
+// TestExecuteStreamHeavyOutput - Test that filling one pipe while the other is idle does not deadlock
+func TestExecuteStreamHeavyOutput(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = append(cfg.CommandExec.AllowedCommands, "head")
+	})
+
+	// Each stream gets far more than a pipe buffer holds, stderr first, so a
+	// reader draining stdout before stderr would block forever
+	const size = 1 << 20
+	sink := newRecordingSink()
+	result, err := cmdExecutor.Execute("sh", Options{
+		Args:    []string{"-c", "head -c 1048576 /dev/zero >&2; head -c 1048576 /dev/zero; head -c 1048576 /dev/zero >&2"},
+		Stream:  sink,
+		Timeout: 30 * time.Second,
+	})
+	require.NoError(t, err)
+	assert.Len(t, result.Stdout, size)
+	assert.Len(t, result.Stderr, 2*size)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+	assert.Equal(t, size, sink.output[StreamStdout].Len())
+	assert.Equal(t, 2*size, sink.output[StreamStderr].Len())
+	require.Len(t, sink.exits, 1)
+}
+
+// TestExecuteStreamProgress - Test that progress is parsed from lines matching the command's pattern
+func TestExecuteStreamProgress(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.ProgressPatterns = map[string]string{"sh": `(\d+(?:\.\d+)?)%`}
+	})
+
+	sink := newRecordingSink()
+	_, err := cmdExecutor.Execute("sh", Options{
+		// Progress redrawn with carriage returns, a line without progress, and an out of range value
+		Args:   []string{"-c", `printf ' 10%%\r 55.5%%\r'; printf 'done\n'; printf 'bogus 250%%\n' >&2`},
+		Stream: sink,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, []float64{10, 55.5}, sink.progress)
+
+	// Raw output is still streamed
+	assert.Equal(t, " 10%\r 55.5%\rdone\n", sink.output[StreamStdout].String())
+	assert.Equal(t, "bogus 250%\n", sink.output[StreamStderr].String())
+}
+
+// TestExecuteStreamNoProgressPattern - Test that commands without a pattern only stream raw output
+func TestExecuteStreamNoProgressPattern(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, nil)
+
+	sink := newRecordingSink()
+	_, err := cmdExecutor.Execute("sh", Options{Args: []string{"-c", "echo 50%"}, Stream: sink})
+	require.NoError(t, err)
+
+	assert.Empty(t, sink.progress)
+	assert.Equal(t, "50%\n", sink.output[StreamStdout].String())
+}
+
+// TestInvalidProgressPattern - Test that patterns without a capture group fail startup
+func TestInvalidProgressPattern(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.ProgressPatterns = map[string]string{"curl": `\d+%`}
+	_, err := newCommandExecutor(cfg)
+	assert.ErrorContains(t, err, "must capture the percentage")
+}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package executor
+
+// teeNoFollow is not available here; openTeeFile checks for a symlink with Lstat instead
+const teeNoFollow = 0
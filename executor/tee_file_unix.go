@@ -0,0 +1,10 @@
+//go:build unix
+
+package executor
+
+import (
+	"syscall"
+)
+
+// teeNoFollow makes opening the tee file fail if its path is a symlink
+const teeNoFollow = syscall.O_NOFOLLOW
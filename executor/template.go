@@ -0,0 +1,47 @@
+package executor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// templatePlaceholder matches {name} placeholders in a command template
+var templatePlaceholder = regexp.MustCompile(`\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// ExpandCommandTemplate substitutes params into a command template and returns the argument vector.
+// The template is split on whitespace first, so each substituted value stays within a single
+// argument and is never re-tokenized.
+func ExpandCommandTemplate(template string, params map[string]string) ([]string, error) {
+	tokens := strings.Fields(template)
+	if len(tokens) == 0 {
+		return nil, errors.New("empty command template")
+	}
+
+	// The program must be fixed so the allowlist check is meaningful
+	if templatePlaceholder.MatchString(tokens[0]) {
+		return nil, fmt.Errorf("placeholders are not allowed in the program name: %s", tokens[0])
+	}
+
+	argv := make([]string, 0, len(tokens))
+	var missing []string
+	for _, token := range tokens {
+		expanded := templatePlaceholder.ReplaceAllStringFunc(token, func(placeholder string) string {
+			name := placeholder[1 : len(placeholder)-1]
+			value, ok := params[name]
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+		argv = append(argv, expanded)
+	}
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing template params: %s", strings.Join(missing, ", "))
+	}
+
+	return argv, nil
+}
@@ -0,0 +1,42 @@
+package executor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestExpandCommandTemplate - Test that params become single literal arguments
+func TestExpandCommandTemplate(t *testing.T) {
+	argv, err := ExpandCommandTemplate("git show {sha} --stat", map[string]string{"sha": "abc123"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "show", "abc123", "--stat"}, argv)
+
+	// Placeholders inside a token keep the surrounding text
+	argv, err = ExpandCommandTemplate("git log --author={name}", map[string]string{"name": "Jane Doe"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"git", "log", "--author=Jane Doe"}, argv)
+}
+
+// TestExpandCommandTemplateInjection - Test that malicious values cannot add arguments
+func TestExpandCommandTemplateInjection(t *testing.T) {
+	argv, err := ExpandCommandTemplate("git show {sha}", map[string]string{
+		"sha": "abc123 --output=/etc/passwd; rm -rf / {sha}",
+	})
+	require.NoError(t, err)
+	require.Len(t, argv, 3)
+	assert.Equal(t, "abc123 --output=/etc/passwd; rm -rf / {sha}", argv[2])
+}
+
+// TestExpandCommandTemplateErrors - Test rejected templates
+func TestExpandCommandTemplateErrors(t *testing.T) {
+	_, err := ExpandCommandTemplate("", nil)
+	assert.Error(t, err)
+
+	_, err = ExpandCommandTemplate("{program} --version", map[string]string{"program": "rm"})
+	assert.Error(t, err)
+
+	_, err = ExpandCommandTemplate("git show {sha}", nil)
+	assert.EqualError(t, err, "missing template params: sha")
+}
@@ -0,0 +1,97 @@
+package executor
+
+import (
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// traversalLimiters add a depth limit to the arguments of commands that walk
+// directory trees, unless the caller already set one
+var traversalLimiters = map[string]func(args []string, depth int) []string{
+	"find": limitFindDepth,
+	"du":   limitOptionDepth([]string{"-d", "--max-depth"}, "-d"),
+	"tree": limitOptionDepth([]string{"-L"}, "-L"),
+}
+
+// validateTraversalLimits checks traversal_commands at startup
+func (e *commandExecutor) validateTraversalLimits() error {
+	for _, name := range e.cfg.CommandExec.TraversalCommands {
+		if _, ok := traversalLimiters[name]; !ok {
+			return errors.Newf("traversal_commands: no depth limit is known for %s", name)
+		}
+	}
+	return nil
+}
+
+// limitTraversal injects traversal_max_depth into the arguments of a traversal
+// command, if enabled for it. traversal_commands defaults to every command
+// with a known limit.
+func (e *commandExecutor) limitTraversal(program string, args []string) []string {
+	depth := e.cfg.CommandExec.TraversalMaxDepth
+	if depth <= 0 {
+		return args
+	}
+
+	name := filepath.Base(program)
+	limiter, ok := traversalLimiters[name]
+	if !ok {
+		return args
+	}
+	if commands := e.cfg.CommandExec.TraversalCommands; len(commands) > 0 && !slices.Contains(commands, name) {
+		return args
+	}
+
+	limited := limiter(args, depth)
+	if len(limited) != len(args) {
+		e.logger.Debugw("limited traversal depth",
+			"command", name,
+			"max_depth", depth)
+	}
+	return limited
+}
+
+// limitFindDepth inserts -maxdepth after find's leading options and starting
+// points, where find expects it
+func limitFindDepth(args []string, depth int) []string {
+	if slices.Contains(args, "-maxdepth") {
+		return args
+	}
+
+	// -H, -L, -P, and -O/-D options come first, then the starting points
+	i := 0
+	for i < len(args) && (args[i] == "-H" || args[i] == "-L" || args[i] == "-P" ||
+		strings.HasPrefix(args[i], "-O") || strings.HasPrefix(args[i], "-D")) {
+		if args[i] == "-D" && i+1 < len(args) {
+			i++
+		}
+		i++
+	}
+	for i < len(args) && !strings.HasPrefix(args[i], "-") && args[i] != "(" && args[i] != "!" {
+		i++
+	}
+
+	limited := make([]string, 0, len(args)+2)
+	limited = append(limited, args[:i]...)
+	limited = append(limited, "-maxdepth", strconv.Itoa(depth))
+	return append(limited, args[i:]...)
+}
+
+// limitOptionDepth returns a limiter that prepends option depth unless any of
+// options (or its --option=value form) is already given
+func limitOptionDepth(options []string, option string) func(args []string, depth int) []string {
+	return func(args []string, depth int) []string {
+		for _, arg := range args {
+			for _, o := range options {
+				if arg == o || strings.HasPrefix(arg, o+"=") ||
+					(len(o) == 2 && strings.HasPrefix(arg, o) && !strings.HasPrefix(arg, "--")) {
+					return args
+				}
+			}
+		}
+		return append([]string{option, strconv.Itoa(depth)}, args...)
+	}
+}
@@ -0,0 +1,67 @@
+package executor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestLimitTraversal - Test that depth limits are injected where each command expects them
+func TestLimitTraversal(t *testing.T) {
+	cmdExecutor, _ := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.TraversalMaxDepth = 2
+	})
+
+	tests := []struct {
+		program string
+		args    []string
+		want    []string
+	}{
+		{"find", nil, []string{"-maxdepth", "2"}},
+		{"find", []string{".", "-name", "*.go"}, []string{".", "-maxdepth", "2", "-name", "*.go"}},
+		{"find", []string{"-L", "src", "lib", "(", "-name", "x", ")"}, []string{"-L", "src", "lib", "-maxdepth", "2", "(", "-name", "x", ")"}},
+		{"/usr/bin/find", []string{"/"}, []string{"/", "-maxdepth", "2"}},
+		{"find", []string{".", "-maxdepth", "5"}, []string{".", "-maxdepth", "5"}},
+		{"du", []string{"-sh", "."}, []string{"-d", "2", "-sh", "."}},
+		{"du", []string{"--max-depth=1", "."}, []string{"--max-depth=1", "."}},
+		{"tree", []string{"-L3"}, []string{"-L3"}},
+		{"tree", nil, []string{"-L", "2"}},
+		{"grep", []string{"-r", "x"}, []string{"-r", "x"}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, cmdExecutor.limitTraversal(tt.program, tt.args), "%s %v", tt.program, tt.args)
+	}
+
+	// Only the configured commands are limited
+	cmdExecutor.cfg.CommandExec.TraversalCommands = []string{"tree"}
+	assert.Equal(t, []string{"."}, cmdExecutor.limitTraversal("find", []string{"."}))
+
+	// Unknown commands fail startup
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.TraversalCommands = []string{"grep"}
+	_, err := newCommandExecutor(cfg)
+	assert.EqualError(t, err, "traversal_commands: no depth limit is known for grep")
+}
+
+// TestExecuteTraversalMaxDepth - Test that find stops at traversal_max_depth
+func TestExecuteTraversalMaxDepth(t *testing.T) {
+	if _, err := exec.LookPath("find"); err != nil {
+		t.Skip("find is not installed")
+	}
+
+	cmdExecutor, dir := newTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"find"}
+		cfg.CommandExec.TraversalMaxDepth = 1
+	})
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0755))
+
+	result, err := cmdExecutor.Execute("find . -type d", Options{})
+	require.NoError(t, err)
+	assert.Equal(t, ".\n./a\n", result.Stdout)
+}
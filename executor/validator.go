@@ -0,0 +1,19 @@
+package executor
+
+import (
+	"context"
+)
+
+// CommandValidator is a hook for custom policy checks that cannot be expressed in config
+type CommandValidator interface {
+	// Validate returns an error if the command must not be executed
+	Validate(ctx context.Context, command string, options Options) error
+}
+
+// CommandValidatorFunc adapts an ordinary function to the CommandValidator interface
+type CommandValidatorFunc func(ctx context.Context, command string, options Options) error
+
+// Validate calls f(ctx, command, options)
+func (f CommandValidatorFunc) Validate(ctx context.Context, command string, options Options) error {
+	return f(ctx, command, options)
+}
@@ -0,0 +1,55 @@
+package executor
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// denyForcePush - Validator that denies forced git pushes
+var denyForcePush = CommandValidatorFunc(func(ctx context.Context, command string, options Options) error {
+	if strings.HasPrefix(command, "git push") && strings.Contains(command, "--force") {
+		return errors.New("force push is not permitted")
+	}
+	return nil
+})
+
+// TestValidate - Test that the custom validator is consulted
+func TestValidate(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	// Test configuration
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"git"}
+
+	cmdExecutor, err := NewCommandExecutor(cfg, WithValidator(denyForcePush))
+	assert.NoError(t, err)
+
+	// Allowed by the validator
+	assert.NoError(t, cmdExecutor.Validate(context.Background(), "git push origin main", Options{}))
+
+	// Denied by the validator
+	err = cmdExecutor.Validate(context.Background(), "git push --force origin main", Options{})
+	assert.EqualError(t, err, "force push is not permitted")
+}
+
+// TestValidateWithoutValidator - Test that no validator allows everything
+func TestValidateWithoutValidator(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cmdExecutor, err := NewCommandExecutor(cfg)
+	assert.NoError(t, err)
+
+	assert.NoError(t, cmdExecutor.Validate(context.Background(), "git push --force", Options{}))
+}
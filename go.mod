@@ -6,6 +6,7 @@ toolchain go1.23.4
 
 require (
 	github.com/cockroachdb/errors v1.11.3
+	github.com/google/uuid v1.6.0
 	github.com/jinzhu/configor v1.2.2
 	github.com/mark3labs/mcp-go v0.18.0
 	github.com/stretchr/testify v1.9.0
@@ -21,7 +22,6 @@ require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/getsentry/sentry-go v0.31.1 // indirect
 	github.com/gogo/protobuf v1.3.2 // indirect
-	github.com/google/uuid v1.6.0 // indirect
 	github.com/kr/pretty v0.3.1 // indirect
 	github.com/kr/text v0.2.0 // indirect
 	github.com/pkg/errors v0.9.1 // indirect
@@ -1,20 +1,18 @@
 package logger
 
 import (
+	"github.com/cockroachdb/errors"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 // InitLogger initializes the global logger
-func InitLogger(debug bool, logPath string) error {
-	var config zap.Config
-
-	if debug {
-		config = zap.NewDevelopmentConfig()
-		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
-		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
-	} else {
-		config = zap.NewProductionConfig()
+// Only 1 in every debugSampleRate debug entries is written (1 or less logs all of them)
+// logFormat (json or console) and logLevel override the defaults implied by debug when set
+func InitLogger(debug bool, logPath string, debugSampleRate int, logFormat string, logLevel string) error {
+	config, err := newConfig(debug, logFormat, logLevel)
+	if err != nil {
+		return err
 	}
 
 	noLogs := len(logPath) == 0
@@ -34,6 +32,9 @@ func InitLogger(debug bool, logPath string) error {
 	logger, err := config.Build(
 		zap.AddCaller(),
 		zap.AddStacktrace(zapcore.ErrorLevel),
+		zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return newDebugSampler(core, debugSampleRate)
+		}),
 	)
 	if err != nil {
 		return err
@@ -43,11 +44,49 @@ func InitLogger(debug bool, logPath string) error {
 
 	zap.S().Infow("Logger initialized",
 		"debug", debug,
-		"log_path", logPath)
+		"log_format", config.Encoding,
+		"log_level", config.Level.String(),
+		"log_path", logPath,
+		"debug_sample_rate", debugSampleRate)
 
 	return nil
 }
 
+// newConfig builds the zap configuration for the debug flag, format and level
+func newConfig(debug bool, logFormat string, logLevel string) (zap.Config, error) {
+	var config zap.Config
+
+	if debug {
+		config = zap.NewDevelopmentConfig()
+		config.EncoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		config.Level = zap.NewAtomicLevelAt(zapcore.DebugLevel)
+	} else {
+		config = zap.NewProductionConfig()
+	}
+
+	switch logFormat {
+	case "":
+	case "json":
+		config.Encoding = "json"
+		// Color codes do not belong in structured logs
+		config.EncoderConfig.EncodeLevel = zapcore.LowercaseLevelEncoder
+	case "console":
+		config.Encoding = "console"
+	default:
+		return config, errors.Newf("invalid log_format: %s (expected json or console)", logFormat)
+	}
+
+	if logLevel != "" {
+		level, err := zapcore.ParseLevel(logLevel)
+		if err != nil {
+			return config, errors.Wrap(err, "invalid log_level")
+		}
+		config.Level = zap.NewAtomicLevelAt(level)
+	}
+
+	return config, nil
+}
+
 // Sync flushes any buffered log entries
 func Sync() error {
 	if err := zap.S().Sync(); err != nil {
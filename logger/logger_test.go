@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// TestDebugSampler - Test that only 1 in N debug entries are logged
+func TestDebugSampler(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(newDebugSampler(core, 4)).Sugar()
+
+	for i := 0; i < 100; i++ {
+		logger.Debugw("executing command", "i", i)
+	}
+	for i := 0; i < 10; i++ {
+		logger.Warnw("command not allowed", "i", i)
+		logger.Errorw("failed to execute command", "i", i)
+	}
+
+	assert.Equal(t, 25, logs.FilterLevelExact(zapcore.DebugLevel).Len())
+	assert.Equal(t, 10, logs.FilterLevelExact(zapcore.WarnLevel).Len())
+	assert.Equal(t, 10, logs.FilterLevelExact(zapcore.ErrorLevel).Len())
+}
+
+// TestDebugSamplerWith - Test that child loggers share the sampling counter
+func TestDebugSamplerWith(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(newDebugSampler(core, 2))
+	child := logger.With(zap.String("component", "executor"))
+
+	logger.Debug("parent")
+	child.Debug("child")
+	logger.Debug("parent")
+	child.Debug("child")
+
+	assert.Equal(t, 2, logs.Len())
+	assert.Equal(t, 2, logs.FilterMessage("parent").Len())
+}
+
+// TestDebugSamplerDisabled - Test that a rate of 1 logs everything
+func TestDebugSamplerDisabled(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(newDebugSampler(core, 1))
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("executing command")
+	}
+
+	assert.Equal(t, 10, logs.Len())
+}
+
+// TestNewConfig - Test the format and level selection
+func TestNewConfig(t *testing.T) {
+	tests := []struct {
+		name         string
+		debug        bool
+		logFormat    string
+		logLevel     string
+		wantEncoding string
+		wantLevel    zapcore.Level
+	}{
+		{name: "production defaults", wantEncoding: "json", wantLevel: zapcore.InfoLevel},
+		{name: "debug defaults", debug: true, wantEncoding: "console", wantLevel: zapcore.DebugLevel},
+		{name: "console with level", logFormat: "console", logLevel: "warn", wantEncoding: "console", wantLevel: zapcore.WarnLevel},
+		{name: "json while debugging", debug: true, logFormat: "json", wantEncoding: "json", wantLevel: zapcore.DebugLevel},
+		{name: "level overrides debug", debug: true, logLevel: "error", wantEncoding: "console", wantLevel: zapcore.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := newConfig(tt.debug, tt.logFormat, tt.logLevel)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantEncoding, config.Encoding)
+			assert.Equal(t, tt.wantLevel, config.Level.Level())
+		})
+	}
+}
+
+// TestNewConfigInvalid - Test that unknown formats and levels are rejected
+func TestNewConfigInvalid(t *testing.T) {
+	_, err := newConfig(false, "xml", "")
+	assert.ErrorContains(t, err, "invalid log_format")
+
+	_, err = newConfig(false, "", "verbose")
+	assert.ErrorContains(t, err, "invalid log_level")
+}
+
+// TestInitLoggerFormatAndLevel - Test that the log file honors the requested format and level
+func TestInitLoggerFormatAndLevel(t *testing.T) {
+	defer zap.ReplaceGlobals(zap.L())
+
+	logPath := filepath.Join(t.TempDir(), "server.log")
+	require.NoError(t, InitLogger(false, logPath, 1, "json", "warn"))
+
+	zap.S().Infow("filtered out")
+	zap.S().Warnw("kept", "key", "value")
+	require.NoError(t, Sync())
+
+	data, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 1)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, "value", entry["key"])
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// debugSampler is a zapcore.Core that only passes 1 in every rate debug entries.
+// Entries at info level and above are always logged.
+type debugSampler struct {
+	zapcore.Core
+	rate    uint64
+	counter *atomic.Uint64
+}
+
+// newDebugSampler wraps the core with debug sampling; rates of 1 or less disable sampling
+func newDebugSampler(core zapcore.Core, rate int) zapcore.Core {
+	if rate <= 1 {
+		return core
+	}
+
+	return &debugSampler{
+		Core:    core,
+		rate:    uint64(rate),
+		counter: &atomic.Uint64{},
+	}
+}
+
+// With implements zapcore.Core, sharing the counter with the parent
+func (s *debugSampler) With(fields []zapcore.Field) zapcore.Core {
+	return &debugSampler{
+		Core:    s.Core.With(fields),
+		rate:    s.rate,
+		counter: s.counter,
+	}
+}
+
+// Check implements zapcore.Core
+func (s *debugSampler) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if ent.Level == zapcore.DebugLevel && s.Core.Enabled(ent.Level) {
+		if (s.counter.Add(1)-1)%s.rate != 0 {
+			return ce
+		}
+	}
+
+	return s.Core.Check(ent, ce)
+}
@@ -0,0 +1,214 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Async job states
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobCompleted = "completed"
+)
+
+const (
+	defaultMaxAsyncJobs       = 8
+	defaultMaxQueuedAsyncJobs = 32
+	defaultAsyncJobRetention  = 10 * time.Minute
+
+	// async_jobs_full_mode values
+	asyncJobsFullModeQueue  = "queue"
+	asyncJobsFullModeReject = "reject"
+)
+
+// asyncJob is a command running in the background
+type asyncJob struct {
+	ID        string               `json:"job_id"`
+	Command   string               `json:"command"`
+	Status    string               `json:"status"`
+	Result    *types.CommandResult `json:"result,omitempty"`
+	expiresAt time.Time
+}
+
+// jobRegistry runs async jobs with a cap on how many run at once and keeps
+// their results until the retention period passes
+type jobRegistry struct {
+	mu        sync.Mutex
+	jobs      map[string]*asyncJob
+	slots     chan struct{}
+	queue     bool
+	queued    int
+	maxQueued int
+	retention time.Duration
+	now       func() time.Time
+}
+
+// newJobRegistry creates a job registry from the configuration
+func newJobRegistry(cfg *config.Config) *jobRegistry {
+	maxJobs := cfg.CommandExec.MaxAsyncJobs
+	if maxJobs <= 0 {
+		maxJobs = defaultMaxAsyncJobs
+	}
+
+	maxQueued := cfg.CommandExec.MaxQueuedAsyncJobs
+	if maxQueued <= 0 {
+		maxQueued = defaultMaxQueuedAsyncJobs
+	}
+
+	retention := time.Duration(cfg.CommandExec.AsyncJobRetentionSeconds) * time.Second
+	if retention <= 0 {
+		retention = defaultAsyncJobRetention
+	}
+
+	mode := cfg.CommandExec.AsyncJobsFullMode
+	if mode != asyncJobsFullModeQueue && mode != asyncJobsFullModeReject && mode != "" {
+		zap.S().Warnw("Invalid async_jobs_full_mode setting, using default 'reject'",
+			"value", mode)
+	}
+
+	return &jobRegistry{
+		jobs:      make(map[string]*asyncJob),
+		slots:     make(chan struct{}, maxJobs),
+		queue:     mode == asyncJobsFullModeQueue,
+		maxQueued: maxQueued,
+		retention: retention,
+		now:       time.Now,
+	}
+}
+
+// start runs the job in the background and returns its initial state. When
+// every slot is taken the job is queued or rejected, depending on the mode;
+// once the queue is full as well, it is rejected in either mode.
+func (r *jobRegistry) start(command string, run func() types.CommandResult) (asyncJob, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return asyncJob{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictLocked()
+
+	job := &asyncJob{ID: hex.EncodeToString(idBytes), Command: command, Status: jobRunning}
+	queuedAt := r.now()
+	select {
+	case r.slots <- struct{}{}:
+	default:
+		if !r.queue {
+			return asyncJob{}, fmt.Errorf("too many async jobs running (max %d); wait for a job to finish or run the command synchronously", cap(r.slots))
+		}
+		if r.queued >= r.maxQueued {
+			return asyncJob{}, fmt.Errorf("async job queue is full (max %d queued); wait for a job to finish or run the command synchronously", r.maxQueued)
+		}
+		r.queued++
+		job.Status = jobQueued
+	}
+	r.jobs[job.ID] = job
+
+	go func() {
+		// Wait for a slot to free up
+		var queueWait time.Duration
+		if job.Status == jobQueued {
+			r.slots <- struct{}{}
+			queueWait = r.dequeue(job).Sub(queuedAt)
+		}
+		defer func() { <-r.slots }()
+
+		result := run()
+
+		// Report queueing separately from execution time when jobs can queue
+		if r.queue {
+			result.QueueWaitMs = queueWait.Milliseconds()
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		job.Status = jobCompleted
+		job.Result = &result
+		job.expiresAt = r.now().Add(r.retention)
+	}()
+
+	return *job, nil
+}
+
+// dequeue marks a queued job running under the lock and returns the time of the change
+func (r *jobRegistry) dequeue(job *asyncJob) time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.queued--
+	job.Status = jobRunning
+	return r.now()
+}
+
+// get returns a snapshot of the job, if it exists and has not expired
+func (r *jobRegistry) get(id string) (asyncJob, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.evictLocked()
+
+	job, ok := r.jobs[id]
+	if !ok {
+		return asyncJob{}, false
+	}
+	return *job, true
+}
+
+// evictLocked removes completed jobs past their retention; the caller must hold the lock
+func (r *jobRegistry) evictLocked() {
+	now := r.now()
+	for id, job := range r.jobs {
+		if job.Status == jobCompleted && now.After(job.expiresAt) {
+			delete(r.jobs, id)
+		}
+	}
+}
+
+// RegisterJobStatusTool registers the tool that reports async job results
+func RegisterJobStatusTool(mcpServer *server.MCPServer, jobs *jobRegistry) error {
+	zap.S().Debugw("registering job_status tool")
+
+	jobStatusTool := mcp.NewTool("job_status",
+		mcp.WithDescription("Get the status and, once completed, the result of a command started with async: true"),
+		mcp.WithString("job_id",
+			mcp.Description("The job ID returned by command_exec"),
+			mcp.Required(),
+		),
+	)
+
+	mcpServer.AddTool(jobStatusTool, newJobStatusHandler(jobs))
+
+	return nil
+}
+
+// newJobStatusHandler creates the handler for the job_status tool
+func newJobStatusHandler(jobs *jobRegistry) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jobID, _ := request.Params.Arguments["job_id"].(string)
+
+		job, ok := jobs.get(jobID)
+		if !ok {
+			return mcp.NewToolResultError(fmt.Sprintf("unknown job: %s (results are kept for a limited time)", jobID)), nil
+		}
+
+		jsonBytes, err := json.Marshal(job)
+		if err != nil {
+			zap.S().Errorw("failed to marshal job to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal job to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
@@ -0,0 +1,217 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// blockingJob returns a job body that runs until release is closed
+func blockingJob(release chan struct{}) func() types.CommandResult {
+	return func() types.CommandResult {
+		<-release
+		return types.CommandResult{Stdout: "done"}
+	}
+}
+
+// waitForStatus waits until the job reaches the given status
+func waitForStatus(t *testing.T, jobs *jobRegistry, id string, status string) asyncJob {
+	t.Helper()
+	var job asyncJob
+	require.Eventually(t, func() bool {
+		var ok bool
+		job, ok = jobs.get(id)
+		return ok && job.Status == status
+	}, 5*time.Second, 10*time.Millisecond)
+	return job
+}
+
+// TestJobRegistryCapReject - Test that jobs beyond the cap are rejected
+func TestJobRegistryCapReject(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.MaxAsyncJobs = 1
+	jobs := newJobRegistry(cfg)
+
+	release := make(chan struct{})
+	first, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+	assert.Equal(t, jobRunning, first.Status)
+
+	_, err = jobs.start("sleep 10", blockingJob(release))
+	assert.ErrorContains(t, err, "too many async jobs running (max 1)")
+
+	// A completed job frees its slot
+	close(release)
+	waitForStatus(t, jobs, first.ID, jobCompleted)
+	require.Eventually(t, func() bool { return len(jobs.slots) == 0 }, 5*time.Second, 10*time.Millisecond)
+
+	_, err = jobs.start("echo", func() types.CommandResult { return types.CommandResult{} })
+	assert.NoError(t, err)
+}
+
+// TestJobRegistryCapQueue - Test that jobs beyond the cap wait for a slot in queue mode
+func TestJobRegistryCapQueue(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.MaxAsyncJobs = 1
+	cfg.CommandExec.AsyncJobsFullMode = asyncJobsFullModeQueue
+	jobs := newJobRegistry(cfg)
+
+	release := make(chan struct{})
+	first, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+
+	second, err := jobs.start("echo", func() types.CommandResult { return types.CommandResult{Stdout: "second"} })
+	require.NoError(t, err)
+	assert.Equal(t, jobQueued, second.Status)
+
+	close(release)
+	waitForStatus(t, jobs, first.ID, jobCompleted)
+	job := waitForStatus(t, jobs, second.ID, jobCompleted)
+	assert.Equal(t, "second", job.Result.Stdout)
+}
+
+// TestJobRegistryQueueFull - Test that queue mode rejects jobs once the queue is full
+func TestJobRegistryQueueFull(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.MaxAsyncJobs = 1
+	cfg.CommandExec.AsyncJobsFullMode = asyncJobsFullModeQueue
+	cfg.CommandExec.MaxQueuedAsyncJobs = 1
+	jobs := newJobRegistry(cfg)
+
+	release := make(chan struct{})
+	first, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+	second, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+	assert.Equal(t, jobQueued, second.Status)
+
+	_, err = jobs.start("sleep 10", blockingJob(release))
+	assert.ErrorContains(t, err, "async job queue is full (max 1 queued)")
+
+	// A job leaving the queue makes room for another
+	close(release)
+	waitForStatus(t, jobs, first.ID, jobCompleted)
+	waitForStatus(t, jobs, second.ID, jobCompleted)
+
+	third, err := jobs.start("echo", func() types.CommandResult { return types.CommandResult{} })
+	require.NoError(t, err)
+	waitForStatus(t, jobs, third.ID, jobCompleted)
+}
+
+// TestJobRegistryQueueWait - Test that a job waiting behind another reports its queue wait
+func TestJobRegistryQueueWait(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.MaxAsyncJobs = 1
+	cfg.CommandExec.AsyncJobsFullMode = asyncJobsFullModeQueue
+	jobs := newJobRegistry(cfg)
+
+	release := make(chan struct{})
+	first, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+	second, err := jobs.start("echo", func() types.CommandResult { return types.CommandResult{} })
+	require.NoError(t, err)
+
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	job := waitForStatus(t, jobs, first.ID, jobCompleted)
+	assert.Zero(t, job.Result.QueueWaitMs)
+	job = waitForStatus(t, jobs, second.ID, jobCompleted)
+	assert.GreaterOrEqual(t, job.Result.QueueWaitMs, int64(50))
+}
+
+// TestJobRegistryRetention - Test that completed jobs are evicted after the retention period
+func TestJobRegistryRetention(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.AsyncJobRetentionSeconds = 60
+	jobs := newJobRegistry(cfg)
+
+	now := time.Now()
+	jobs.now = func() time.Time { return now }
+
+	release := make(chan struct{})
+	running, err := jobs.start("sleep 10", blockingJob(release))
+	require.NoError(t, err)
+	finished, err := jobs.start("echo", func() types.CommandResult { return types.CommandResult{} })
+	require.NoError(t, err)
+	waitForStatus(t, jobs, finished.ID, jobCompleted)
+
+	// Still kept within the retention period
+	jobs.mu.Lock()
+	now = now.Add(59 * time.Second)
+	jobs.mu.Unlock()
+	_, ok := jobs.get(finished.ID)
+	assert.True(t, ok)
+
+	jobs.mu.Lock()
+	now = now.Add(2 * time.Second)
+	jobs.mu.Unlock()
+	_, ok = jobs.get(finished.ID)
+	assert.False(t, ok)
+
+	// Running jobs are never evicted
+	_, ok = jobs.get(running.ID)
+	assert.True(t, ok)
+	close(release)
+}
+
+// TestCommandExecAsync - Test running a command asynchronously and polling job_status
+func TestCommandExecAsync(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo", "cd"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	jobs := newJobRegistry(cfg)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, jobs, nil)
+
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "echo hello", "async": true})
+	require.False(t, result.IsError)
+
+	var started asyncJob
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &started))
+	assert.NotEmpty(t, started.ID)
+
+	statusHandler := newJobStatusHandler(jobs)
+	var job asyncJob
+	require.Eventually(t, func() bool {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = map[string]interface{}{"job_id": started.ID}
+		result, err := statusHandler(context.Background(), request)
+		require.NoError(t, err)
+		require.False(t, result.IsError)
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &job))
+		return job.Status == jobCompleted
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NotNil(t, job.Result)
+	assert.Equal(t, "hello\n", job.Result.Stdout)
+
+	// Unknown jobs are reported as errors
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"job_id": "missing"}
+	result, err = statusHandler(context.Background(), request)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+
+	// cd and streaming cannot run in the background
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "cd /", "async": true})
+	assert.True(t, result.IsError)
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "echo hi", "async": true, "stream": true})
+	assert.True(t, result.IsError)
+}
@@ -5,30 +5,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"time"
 
+	"github.com/cnosuke/mcp-command-exec/config"
 	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"go.uber.org/zap"
 )
 
 // RegisterCommandExecTool registers the command execution tool
-func RegisterCommandExecTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor) error {
+func RegisterCommandExecTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, outputs *outputStore, budget *sessionBudget, jobs *jobRegistry, policy *sessionPolicy) error {
 	zap.S().Debugw("registering command_exec tool")
 
-	// Generate description for the command execution tool
-	description := fmt.Sprint(
-		"Execute a system command from a predefined allowed list.",
-		"Recommended to specify the directory to execute the command in using the `working_dir` parameter.",
-		"Allowed commands: ",
-		strings.Join(cmdExecutor.GetAllowedCommands(), ", "))
-
 	// Tool definition
 	commandExecTool := mcp.NewTool("command_exec",
-		mcp.WithDescription(description),
+		mcp.WithDescription(commandExecDescription(cmdExecutor, cfg)),
 		mcp.WithString("command",
-			mcp.Required(),
-			mcp.Description("The command to execute"),
+			mcp.Description("The command to execute (either command or command_template is required)"),
+		),
+		mcp.WithString("command_template",
+			mcp.Description("Command with {name} placeholders; each placeholder is replaced by the matching params value as a single literal argument"),
+		),
+		mcp.WithObject("params",
+			mcp.Description("Values substituted into command_template"),
 		),
 		mcp.WithString("working_dir",
 			mcp.Description("Optional working directory for this command only"),
@@ -36,25 +38,134 @@ func RegisterCommandExecTool(mcpServer *server.MCPServer, cmdExecutor executor.C
 		mcp.WithObject("env",
 			mcp.Description("Optional environment variables for this command only"),
 		),
+		mcp.WithObject("secret_refs",
+			mcp.Description("Optional environment variables resolved from the server's secret source, mapping names to secret references; values are never logged and are redacted from the output"),
+		),
+		mcp.WithBoolean("stream",
+			mcp.Description("Stream output as notifications/command_exec/output notifications, ending with a notifications/command_exec/exit notification"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Run the command in the background and return a job ID; poll the result with the job_status tool"),
+		),
+		mcp.WithString("stdin",
+			mcp.Description("Optional standard input for the command (only used when the server's stdin_mode is 'provided')"),
+		),
+		mcp.WithBoolean("trim_output",
+			mcp.Description("Remove trailing newlines and whitespace from stdout and stderr"),
+		),
+		mcp.WithBoolean("capture_stdout",
+			mcp.Description("Capture stdout (default true); set false to discard it"),
+		),
+		mcp.WithBoolean("capture_stderr",
+			mcp.Description("Capture stderr (default true); set false to discard it"),
+		),
+		mcp.WithString("merge_order",
+			mcp.Description("Also return stdout and stderr merged in 'combined': 'interleaved' (as written), 'stdout_first', or 'stderr_first'"),
+			mcp.Enum("interleaved", "stdout_first", "stderr_first"),
+		),
+		mcp.WithNumber("timeout_seconds",
+			mcp.Description("Kill the command after this many seconds, overriding timeout_seconds; its output up to then is returned with exit code 124"),
+		),
+		mcp.WithNumber("max_output_lines",
+			mcp.Description("Keep at most this many lines of stdout and of stderr, overriding max_output_lines"),
+		),
+		mcp.WithString("output_lines_keep",
+			mcp.Description("Which lines max_output_lines keeps: 'head' (the first) or 'tail' (the last)"),
+			mcp.Enum("head", "tail"),
+		),
+		mcp.WithBoolean("echo_command",
+			mcp.Description("Prepend a '$ <command>' line to stdout, like a shell session transcript"),
+		),
+		mcp.WithBoolean("parse_json",
+			mcp.Description("When the command succeeds and its stdout is valid JSON, also return it as structured data in parsed_json"),
+		),
+		mcp.WithBoolean("hex_dump",
+			mcp.Description("Return stdout and stderr as hex+ASCII dumps (like hexdump -C) to inspect binary output"),
+		),
+		mcp.WithBoolean("profile",
+			mcp.Description("Include the time spent in each phase (resolving the binary, validation, process start, run) in timings"),
+		),
+		mcp.WithBoolean("explain",
+			mcp.Description("Include diagnostics on how the command was resolved: the binary path and the PATH it ran with"),
+		),
+		mcp.WithBoolean("persistent_shell",
+			mcp.Description("Run the command line in this session's long-lived bash process, so exported variables, sourced scripts, and cd carry over to later calls. Command separators, substitutions and redirections are rejected (only when enabled by the server)"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label for the result, so it can be fetched again with last_result"),
+		),
+		mcp.WithBoolean("scratch_dir",
+			mcp.Description("Run the command in a new empty directory under the server's scratch_root, removed afterwards; its path is returned as scratch_dir"),
+		),
+		mcp.WithBoolean("keep_scratch",
+			mcp.Description("Keep the scratch directory instead of removing it after the command"),
+		),
+		mcp.WithString("tee_file",
+			mcp.Description("Optional file that also receives the command output (must be within allowed directories)"),
+		),
 	)
 
 	// Add tool handler
-	mcpServer.AddTool(commandExecTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	mcpServer.AddTool(commandExecTool, newCommandExecHandler(cmdExecutor, cfg, outputs, budget, jobs, policy))
+
+	return nil
+}
+
+// commandExecDescription generates the tool description, listing the allowed
+// commands with their command_descriptions entries so clients know what each is for
+func commandExecDescription(cmdExecutor executor.CommandExecutor, cfg *config.Config) string {
+	commands := cmdExecutor.GetAllowedCommands()
+	entries := make([]string, len(commands))
+	for i, name := range commands {
+		entries[i] = name
+		if desc := cfg.CommandExec.CommandDescriptions[name]; desc != "" {
+			entries[i] = fmt.Sprintf("%s (%s)", name, desc)
+		}
+	}
+
+	return fmt.Sprint(
+		"Execute a system command from a predefined allowed list.",
+		"Recommended to specify the directory to execute the command in using the `working_dir` parameter.",
+		"Allowed commands: ",
+		strings.Join(entries, ", "))
+}
+
+// newCommandExecHandler creates the handler for the command execution tool
+func newCommandExecHandler(cmdExecutor executor.CommandExecutor, cfg *config.Config, outputs *outputStore, budget *sessionBudget, jobs *jobRegistry, policy *sessionPolicy) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		// Identify this call in every log entry and in the result
+		executionID := uuid.NewString()
+		logger := zap.S().With("execution_id", executionID)
+
 		// Extract parameters from the request
 		var command string
 		var workingDir string
 		var env map[string]string
+		var teeFile string
+		var stdin string
+		var args []string
 
 		// Get command parameter
 		if commandVal, ok := request.Params.Arguments["command"].(string); ok {
 			command = commandVal
 		}
+		rawCommand := command
 
 		// Get working_dir parameter
 		if workingDirVal, ok := request.Params.Arguments["working_dir"].(string); ok {
 			workingDir = workingDirVal
 		}
 
+		// Get stdin parameter
+		if stdinVal, ok := request.Params.Arguments["stdin"].(string); ok {
+			stdin = stdinVal
+		}
+
+		// Get tee_file parameter
+		if teeFileVal, ok := request.Params.Arguments["tee_file"].(string); ok {
+			teeFile = teeFileVal
+		}
+
 		// Get env parameter
 		if envVal, ok := request.Params.Arguments["env"].(map[string]interface{}); ok {
 			env = make(map[string]string)
@@ -65,53 +176,354 @@ func RegisterCommandExecTool(mcpServer *server.MCPServer, cmdExecutor executor.C
 			}
 		}
 
-		zap.S().Debugw("executing command_exec",
+		// Get secret_refs parameter
+		var secretRefs map[string]string
+		if secretRefsVal, ok := request.Params.Arguments["secret_refs"].(map[string]interface{}); ok {
+			secretRefs = make(map[string]string)
+			for k, v := range secretRefsVal {
+				if strVal, ok := v.(string); ok {
+					secretRefs[k] = strVal
+				}
+			}
+		}
+
+		// Expand command_template into literal arguments
+		if commandTemplate, ok := request.Params.Arguments["command_template"].(string); ok && commandTemplate != "" {
+			if command != "" {
+				return mcp.NewToolResultError("specify either command or command_template, not both"), nil
+			}
+
+			params := make(map[string]string)
+			if paramsVal, ok := request.Params.Arguments["params"].(map[string]interface{}); ok {
+				for k, v := range paramsVal {
+					strVal, ok := v.(string)
+					if !ok {
+						return mcp.NewToolResultError(fmt.Sprintf("template param must be a string: %s", k)), nil
+					}
+					params[k] = strVal
+				}
+			}
+
+			argv, err := executor.ExpandCommandTemplate(commandTemplate, params)
+			if err != nil {
+				logger.Warnw("failed to expand command template",
+					"command_template", commandTemplate,
+					"error", err)
+				return mcp.NewToolResultError(fmt.Sprintf("invalid command template: %s", err.Error())), nil
+			}
+
+			command = strings.Join(argv, " ")
+			args = argv[1:]
+			rawCommand = commandTemplate
+		}
+
+		// Validate and execute the same normalized form
+		command = executor.NormalizeCommand(command)
+
+		logger.Debugw("executing command_exec",
 			"command", command)
 
 		// Check for empty command
 		if command == "" {
-			zap.S().Warnw("empty command provided")
+			logger.Warnw("empty command provided")
 			return mcp.NewToolResultError("empty command provided"), nil
 		}
 
-		// Check if the command is in the allowed list
-		if !cmdExecutor.IsCommandAllowed(command) {
-			zap.S().Warnw("command not allowed",
-				"command", command)
-			return mcp.NewToolResultError(fmt.Sprintf("command not allowed: %s", command)), nil
+		// Expand aliases and check the command against the session's allowlist and directories
+		command, denial := checkCommand(ctx, cmdExecutor, cfg, policy, command, args == nil, workingDir, env)
+		if denial != "" {
+			logger.Warnw("command denied",
+				"command", command,
+				"reason", denial)
+			return mcp.NewToolResultError(denial), nil
 		}
 
-		// Execute command
 		options := executor.Options{
-			WorkingDir: workingDir,
-			Env:        env,
+			WorkingDir:  workingDir,
+			Env:         env,
+			TeeFile:     teeFile,
+			Args:        args,
+			Stdin:       stdin,
+			SecretRefs:  secretRefs,
+			RawCommand:  rawCommand,
+			ExecutionID: executionID,
 		}
 
+		// Trim trailing whitespace from the output
+		if trimVal, ok := request.Params.Arguments["trim_output"].(bool); ok {
+			options.TrimOutput = trimVal
+		}
+
+		// Discard streams the caller does not need
+		if captureVal, ok := request.Params.Arguments["capture_stdout"].(bool); ok {
+			options.DiscardStdout = !captureVal
+		}
+		if captureVal, ok := request.Params.Arguments["capture_stderr"].(bool); ok {
+			options.DiscardStderr = !captureVal
+		}
+
+		// Run in the session's persistent shell
+		if shellVal, ok := request.Params.Arguments["persistent_shell"].(bool); ok {
+			options.PersistentShell = shellVal
+		}
+
+		// Run in a throwaway scratch directory
+		if scratchVal, ok := request.Params.Arguments["scratch_dir"].(bool); ok {
+			options.ScratchDir = scratchVal
+		}
+		if keepVal, ok := request.Params.Arguments["keep_scratch"].(bool); ok {
+			options.KeepScratch = keepVal
+		}
+
+		// Tag the result for last_result
+		if labelVal, ok := request.Params.Arguments["label"].(string); ok {
+			options.Label = labelVal
+		}
+
+		// Return JSON output as structured data
+		if parseVal, ok := request.Params.Arguments["parse_json"].(bool); ok {
+			options.ParseJSON = parseVal
+		}
+
+		// Show the output as a hex dump
+		if hexVal, ok := request.Params.Arguments["hex_dump"].(bool); ok {
+			options.HexDump = hexVal
+		}
+
+		// Include per-phase timings
+		if profileVal, ok := request.Params.Arguments["profile"].(bool); ok {
+			options.Profile = profileVal
+		}
+
+		// Include resolution diagnostics
+		if explainVal, ok := request.Params.Arguments["explain"].(bool); ok {
+			options.Explain = explainVal
+		}
+
+		// Merge stdout and stderr into a combined output
+		if mergeVal, ok := request.Params.Arguments["merge_order"].(string); ok {
+			options.MergeOrder = mergeVal
+		}
+
+		// Kill the command once it runs past its timeout
+		if timeoutVal, ok := request.Params.Arguments["timeout_seconds"].(float64); ok && timeoutVal > 0 {
+			options.Timeout = time.Duration(timeoutVal * float64(time.Second))
+		}
+
+		// Cap the number of output lines
+		if linesVal, ok := request.Params.Arguments["max_output_lines"].(float64); ok {
+			options.MaxOutputLines = int(linesVal)
+		}
+		if keepVal, ok := request.Params.Arguments["output_lines_keep"].(string); ok {
+			options.OutputLinesKeep = keepVal
+		}
+
+		// Prefix stdout with the command for transcripts
+		if echoVal, ok := request.Params.Arguments["echo_command"].(bool); ok {
+			options.EchoCommand = echoVal
+		}
+
+		// Stream output to the client as it is produced
+		if streamVal, ok := request.Params.Arguments["stream"].(bool); ok && streamVal {
+			var progressToken mcp.ProgressToken
+			if request.Params.Meta != nil {
+				progressToken = request.Params.Meta.ProgressToken
+			}
+			options.Stream = newNotificationSink(ctx, progressToken)
+		}
+
+		// Consult the custom validator, if any
+		if err := cmdExecutor.Validate(ctx, command, options); err != nil {
+			return mcp.NewToolResultError(validatorDenialMessage(cfg, err)), nil
+		}
+
+		// Check the session time budget
+		sessionID := sessionIDFromContext(ctx)
+		options.SessionID = sessionID
+		options.SessionPolicyVersion = policy.version(ctx)
+		if budget.exhausted(sessionID) {
+			logger.Warnw("session time budget exhausted",
+				"session_id", sessionID,
+				"command", command)
+			return mcp.NewToolResultError(budget.exhaustedMessage()), nil
+		}
+
+		// Run in the background and return the job ID right away
+		if asyncVal, ok := request.Params.Arguments["async"].(bool); ok && asyncVal {
+			return startAsyncJob(cmdExecutor, cfg, outputs, budget, jobs, sessionID, command, options), nil
+		}
+
+		// Execute command
+		startedAt := time.Now()
 		result, err := cmdExecutor.Execute(command, options)
+		budget.add(sessionID, time.Since(startedAt))
+
+		// Offload large output to a resource instead of inlining it
+		storeLargeOutput(&result, cfg.CommandExec.InlineOutputLimit, outputs)
 
 		// Error handling
 		if err != nil {
-			zap.S().Errorw("failed to execute command",
+			logger.Errorw("failed to execute command",
 				"command", command,
 				"error", err)
 
 			// Return response even if there is an error
 			jsonBytes, jsonErr := json.Marshal(result)
 			if jsonErr != nil {
-				zap.S().Errorw("failed to marshal result to JSON", "error", jsonErr)
+				logger.Errorw("failed to marshal result to JSON", "error", jsonErr)
 				return mcp.NewToolResultText(fmt.Sprintf("Command failed: %s", err.Error())), nil
 			}
-			return mcp.NewToolResultText(string(jsonBytes)), nil
+			return commandResultText(cfg, result, jsonBytes), nil
 		}
 
 		// Convert execution result to JSON and return
 		jsonBytes, err := json.Marshal(result)
 		if err != nil {
-			zap.S().Errorw("failed to marshal result to JSON", "error", err)
+			logger.Errorw("failed to marshal result to JSON", "error", err)
 			return mcp.NewToolResultError("failed to marshal result to JSON"), nil
 		}
-		return mcp.NewToolResultText(string(jsonBytes)), nil
+		return commandResultText(cfg, result, jsonBytes), nil
+	}
+}
+
+// checkCommand expands the command's aliases, unless its arguments were given
+// separately, and checks the result against the calling session's allowlist
+// and directories. It returns the command that would run and, if it is not
+// allowed, the message denying it. command_exec and precheck both use it, so
+// they cannot disagree.
+func checkCommand(ctx context.Context, cmdExecutor executor.CommandExecutor, cfg *config.Config, policy *sessionPolicy, command string, expandAliases bool, workingDir string, env map[string]string) (string, string) {
+	// Expand configured aliases; the expanded program must be allowed
+	if expandAliases && len(cfg.CommandExec.Aliases) > 0 {
+		expanded, err := executor.ExpandAliases(command, cfg.CommandExec.Aliases, cfg.CommandExec.MaxAliasDepth)
+		if err != nil {
+			return command, err.Error()
+		}
+		command = expanded
+	}
+
+	// Check if the command is in the allowed list
+	if !policy.isCommandAllowed(ctx, cmdExecutor, command) {
+		return command, notAllowedMessage(cfg, policy.allowedCommands(ctx, cmdExecutor), command)
+	}
+
+	// Hold the session to the directories of its resolved policy, if it has any
+	if policy.restrictsDirectories(ctx) {
+		dir, err := commandDir(cmdExecutor, command, workingDir, env)
+		if err != nil {
+			return command, err.Error()
+		}
+		if !policy.isDirectoryAllowed(ctx, dir) {
+			return command, fmt.Sprintf("directory not allowed for this session: %s", dir)
+		}
+	}
+
+	return command, ""
+}
+
+// commandResultText returns the JSON result of a command, as a tool error when
+// it exited nonzero and nonzero_exit_is_error is set
+func commandResultText(cfg *config.Config, result types.CommandResult, jsonBytes []byte) *mcp.CallToolResult {
+	if cfg.CommandExec.NonzeroExitIsError && result.ExitCode != 0 {
+		return mcp.NewToolResultError(string(jsonBytes))
+	}
+	return mcp.NewToolResultText(string(jsonBytes))
+}
+
+// startAsyncJob starts the command as an async job and returns the job's initial state
+func startAsyncJob(cmdExecutor executor.CommandExecutor, cfg *config.Config, outputs *outputStore, budget *sessionBudget, jobs *jobRegistry, sessionID string, command string, options executor.Options) *mcp.CallToolResult {
+	if jobs == nil {
+		return mcp.NewToolResultError("async execution is not available")
+	}
+
+	// Notifications cannot outlive the request, and cd changes state other calls depend on
+	if options.Stream != nil {
+		return mcp.NewToolResultError("stream cannot be combined with async")
+	}
+	if parts := strings.Fields(command); parts[0] == "cd" {
+		return mcp.NewToolResultError("cd cannot run asynchronously")
+	}
+
+	job, err := jobs.start(command, func() types.CommandResult {
+		startedAt := time.Now()
+		result, err := cmdExecutor.Execute(command, options)
+		budget.add(sessionID, time.Since(startedAt))
+
+		if err != nil && result.Error == "" {
+			result.Error = err.Error()
+		}
+		storeLargeOutput(&result, cfg.CommandExec.InlineOutputLimit, outputs)
+		return result
 	})
+	if err != nil {
+		zap.S().Warnw("failed to start async job",
+			"command", command,
+			"error", err)
+		return mcp.NewToolResultError(err.Error())
+	}
 
-	return nil
+	zap.S().Infow("started async job",
+		"job_id", job.ID,
+		"execution_id", options.ExecutionID,
+		"command", command,
+		"status", job.Status)
+
+	jsonBytes, err := json.Marshal(job)
+	if err != nil {
+		zap.S().Errorw("failed to marshal job to JSON", "error", err)
+		return mcp.NewToolResultError("failed to marshal job to JSON")
+	}
+	return mcp.NewToolResultText(string(jsonBytes))
+}
+
+// notAllowedMessage builds the denial message for a command outside the allowlist
+func notAllowedMessage(cfg *config.Config, allowed []string, command string) string {
+	message := fmt.Sprintf("command not allowed: %s", command)
+	parts := strings.Fields(command)
+
+	if cfg.CommandExec.VerboseDenials {
+		message = fmt.Sprintf("%s ('%s' is not in the allowed command list; allowed commands: %s)",
+			message, parts[0], strings.Join(allowed, ", "))
+	}
+
+	// Point out the allowed command the caller most likely meant
+	if cfg.CommandExec.SuggestOnDenial {
+		if suggestion, ok := closestCommand(parts[0], allowed); ok {
+			message = fmt.Sprintf("%s (did you mean: %s?)", message, suggestion)
+		}
+	}
+
+	return message
+}
+
+// validatorDenialMessage builds the denial message for a command rejected by the custom validator
+func validatorDenialMessage(cfg *config.Config, err error) string {
+	message := fmt.Sprintf("command denied: %s", err.Error())
+	if !cfg.CommandExec.VerboseDenials {
+		return message
+	}
+
+	return fmt.Sprintf("%s (rejected by a custom validation policy after passing the allowlist)", message)
+}
+
+// storeLargeOutput replaces output above the limit with resource links
+func storeLargeOutput(result *types.CommandResult, limit int, outputs *outputStore) {
+	if limit <= 0 || outputs == nil || len(result.Stdout)+len(result.Stderr) <= limit {
+		return
+	}
+
+	id, err := outputs.put(result.Stdout, result.Stderr)
+	if err != nil {
+		zap.S().Errorw("failed to store command output, returning it inline", "error", err)
+		return
+	}
+
+	zap.S().Debugw("stored large command output",
+		"id", id,
+		"stdout_bytes", len(result.Stdout),
+		"stderr_bytes", len(result.Stderr))
+
+	result.Stdout = ""
+	result.Stderr = ""
+	result.StdoutURI = outputURI(id, "stdout")
+	result.StderrURI = outputURI(id, "stderr")
 }
@@ -0,0 +1,350 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// callCommandExec - Invoke the command_exec handler with the given arguments
+func callCommandExec(t *testing.T, handler server.ToolHandlerFunc, args map[string]interface{}) *mcp.CallToolResult {
+	t.Helper()
+
+	request := mcp.CallToolRequest{}
+	request.Params.Name = "command_exec"
+	request.Params.Arguments = args
+
+	result, err := handler(context.Background(), request)
+	require.NoError(t, err)
+	require.NotNil(t, result)
+
+	return result
+}
+
+// resultText - Extract the text content of a tool result
+func resultText(t *testing.T, result *mcp.CallToolResult) string {
+	t.Helper()
+
+	require.NotEmpty(t, result.Content)
+	text, ok := result.Content[0].(mcp.TextContent)
+	require.True(t, ok)
+
+	return text.Text
+}
+
+// TestCommandExecDescription - Test that command descriptions appear in the tool description
+func TestCommandExecDescription(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"git", "ls"}
+	cfg.CommandExec.CommandDescriptions = map[string]string{"git": "version control - status, diff, log"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	description := commandExecDescription(cmdExecutor, cfg)
+	assert.Contains(t, description, "Allowed commands: git (version control - status, diff, log), ls")
+}
+
+// TestCommandExecValidatorDenial - Test that validator errors become denial results
+func TestCommandExecValidatorDenial(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"ls"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	validator := executor.CommandValidatorFunc(func(ctx context.Context, command string, options executor.Options) error {
+		if strings.Contains(command, "-R") {
+			return errors.New("recursive listing is not permitted")
+		}
+		return nil
+	})
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg, executor.WithValidator(validator))
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	// Denied by the validator
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "ls -R"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command denied: recursive listing is not permitted", resultText(t, result))
+
+	// Passes the validator and executes
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "ls"})
+	assert.False(t, result.IsError)
+	assert.Contains(t, resultText(t, result), `"exit_code":0`)
+}
+
+// TestCommandExecInlineOutputLimit - Test that large output is returned as resource links
+func TestCommandExecInlineOutputLimit(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.InlineOutputLimit = 10
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	outputs := newOutputStore(cfg)
+	handler := newCommandExecHandler(cmdExecutor, cfg, outputs, nil, nil, nil)
+
+	// Below the threshold the output is inline
+	var small types.CommandResult
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "echo short"})
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &small))
+	assert.Equal(t, "short\n", small.Stdout)
+	assert.Empty(t, small.StdoutURI)
+
+	// Above the threshold the output is stored and linked
+	var large types.CommandResult
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "echo this output is too long"})
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &large))
+	assert.Empty(t, large.Stdout)
+	require.NotEmpty(t, large.StdoutURI)
+
+	// The link serves the full output
+	request := mcp.ReadResourceRequest{}
+	request.Params.URI = large.StdoutURI
+	request.Params.Arguments = map[string]interface{}{
+		"id":     strings.TrimSuffix(strings.TrimPrefix(large.StdoutURI, "command-output://"), "/stdout"),
+		"stream": "stdout",
+	}
+	contents, err := newOutputResourceHandler(outputs)(context.Background(), request)
+	require.NoError(t, err)
+	require.Len(t, contents, 1)
+	assert.Equal(t, "this output is too long\n", contents[0].(mcp.TextResourceContents).Text)
+}
+
+// TestCommandExecTemplate - Test that template params are passed as literal arguments
+func TestCommandExecTemplate(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	// Whitespace and metacharacters in the value stay in one argument
+	var execResult types.CommandResult
+	result := callCommandExec(t, handler, map[string]interface{}{
+		"command_template": "echo {value}",
+		"params":           map[string]interface{}{"value": "a  b; rm -rf /"},
+	})
+	require.False(t, result.IsError)
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &execResult))
+	assert.Equal(t, "a  b; rm -rf /\n", execResult.Stdout)
+
+	// The program is still checked against the allowlist
+	result = callCommandExec(t, handler, map[string]interface{}{
+		"command_template": "rm {path}",
+		"params":           map[string]interface{}{"path": "/tmp/x"},
+	})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: rm /tmp/x", resultText(t, result))
+}
+
+// TestCommandExecVerboseDenials - Test denial messages with and without policy context
+func TestCommandExecVerboseDenials(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"git", "ls"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	// Terse by default
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "rm -rf /"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: rm -rf /", resultText(t, result))
+
+	// Policy context when enabled
+	cfg.CommandExec.VerboseDenials = true
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "rm -rf /"})
+	assert.True(t, result.IsError)
+	assert.Equal(t,
+		"command not allowed: rm -rf / ('rm' is not in the allowed command list; allowed commands: git, ls)",
+		resultText(t, result))
+}
+
+// TestCommandExecNormalizesCommand - Test that padded and doubly-spaced commands are validated and run in one form
+func TestCommandExecNormalizesCommand(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	var validated []string
+	validator := executor.CommandValidatorFunc(func(ctx context.Context, command string, options executor.Options) error {
+		validated = append(validated, command)
+		return nil
+	})
+	cmdExecutor, err := executor.NewCommandExecutor(cfg, executor.WithValidator(validator))
+	require.NoError(t, err)
+
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "  echo   a \t b  "})
+	require.False(t, result.IsError)
+
+	var commandResult types.CommandResult
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+	assert.Equal(t, "echo a b", commandResult.Command)
+	assert.Equal(t, "a b\n", commandResult.Stdout)
+	assert.Equal(t, []string{"echo a b"}, validated)
+
+	// Whitespace-only commands are empty
+	result = callCommandExec(t, handler, map[string]interface{}{"command": " \t "})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "empty command provided", resultText(t, result))
+}
+
+// TestCommandExecExecutionID - Test that each call returns a unique execution ID
+func TestCommandExecExecutionID(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 3; i++ {
+		result := callCommandExec(t, handler, map[string]interface{}{"command": "echo hi"})
+		require.False(t, result.IsError)
+
+		var commandResult types.CommandResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+		require.NotEmpty(t, commandResult.ExecutionID)
+		assert.False(t, seen[commandResult.ExecutionID])
+		seen[commandResult.ExecutionID] = true
+	}
+}
+
+// TestCommandExecAliases - Test that aliases expand before the allowlist check
+func TestCommandExecAliases(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.Aliases = map[string]string{
+		"greet": "say hello",
+		"say":   "echo",
+		"loop":  "again",
+		"again": "loop",
+		"wipe":  "rm -rf",
+	}
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "  greet   world"})
+	require.False(t, result.IsError)
+	var commandResult types.CommandResult
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+	assert.Equal(t, "echo hello world", commandResult.Command)
+	assert.Equal(t, "  greet   world", commandResult.RawCommand)
+	assert.Equal(t, "hello world\n", commandResult.Stdout)
+
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "loop"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "alias cycle detected at loop", resultText(t, result))
+
+	// Aliases cannot reach programs outside the allowlist
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "wipe /"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: rm -rf /", resultText(t, result))
+}
+
+// TestCommandExecNonzeroExitIsError - Test that nonzero_exit_is_error turns failing commands into tool errors
+func TestCommandExecNonzeroExitIsError(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	for _, asError := range []bool{false, true} {
+		cfg := &config.Config{}
+		cfg.CommandExec.AllowedCommands = []string{"sh"}
+		cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+		cfg.CommandExec.NonzeroExitIsError = asError
+
+		cmdExecutor, err := executor.NewCommandExecutor(cfg)
+		require.NoError(t, err)
+		handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+		// The result is returned either way, only the error flag differs
+		result := callCommandExec(t, handler, map[string]interface{}{"command": "sh -c false"})
+		assert.Equal(t, asError, result.IsError)
+		var commandResult types.CommandResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+		assert.Equal(t, 1, commandResult.ExitCode)
+
+		// Successful commands are never errors
+		result = callCommandExec(t, handler, map[string]interface{}{"command": "sh -c true"})
+		assert.False(t, result.IsError)
+	}
+}
+
+// TestCommandExecTimeout - Test that the timeout_seconds argument kills a long command
+func TestCommandExecTimeout(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"sleep"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "sleep 5", "timeout_seconds": 0.2})
+	var commandResult types.CommandResult
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+	assert.Equal(t, 124, commandResult.ExitCode)
+	assert.Equal(t, "command timed out after 200ms", commandResult.Error)
+}
@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// RegisterDirDiffTool registers the tool that compares two directories
+func RegisterDirDiffTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, policy *sessionPolicy) error {
+	zap.S().Debugw("registering dir_diff tool")
+
+	dirDiffTool := mcp.NewTool("dir_diff",
+		mcp.WithDescription("Compare the files in two allowed directories by content and list those added, removed, and changed, without running diff"),
+		mcp.WithString("from",
+			mcp.Description("The directory to compare from"),
+			mcp.Required(),
+		),
+		mcp.WithString("to",
+			mcp.Description("The directory to compare to; files only here are added, files only in from are removed"),
+			mcp.Required(),
+		),
+	)
+	mcpServer.AddTool(dirDiffTool, newDirDiffHandler(cmdExecutor, policy))
+
+	return nil
+}
+
+// newDirDiffHandler creates the handler for the dir_diff tool
+func newDirDiffHandler(cmdExecutor executor.CommandExecutor, policy *sessionPolicy) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		from, _ := request.Params.Arguments["from"].(string)
+		to, _ := request.Params.Arguments["to"].(string)
+
+		for _, dir := range []string{from, to} {
+			if dir != "" && !filepath.IsAbs(dir) {
+				dir = filepath.Join(cmdExecutor.GetCurrentWorkingDir(), dir)
+			}
+			if !policy.isDirectoryAllowed(ctx, dir) {
+				return mcp.NewToolResultError(fmt.Sprintf("directory not allowed for this session: %s", dir)), nil
+			}
+		}
+
+		diff, err := cmdExecutor.DiffDirs(from, to)
+		if err != nil {
+			zap.S().Warnw("failed to diff directories",
+				"from", from,
+				"to", to,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		jsonBytes, err := json.Marshal(diff)
+		if err != nil {
+			zap.S().Errorw("failed to marshal result to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal result to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
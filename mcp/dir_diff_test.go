@@ -0,0 +1,46 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestDirDiffTool - Test diffing two directories through the tool, within the session's directories
+func TestDirDiffTool(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, nil)
+	dir := cfg.CommandExec.DefaultWorkingDir
+	for _, sub := range []string{"a", "b"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, sub), 0755))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a", "old.txt"), []byte("old"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "b", "new.txt"), []byte("new"), 0644))
+
+	policy := newSessionPolicy(cfg, fakePolicyResolver{
+		"scoped": {AllowedDirs: []string{filepath.Join(dir, "a")}},
+	})
+	handler := newDirDiffHandler(cmdExecutor, policy)
+
+	result := callInSession(t, handler, "dev", map[string]interface{}{"from": "a", "to": "b"})
+	require.False(t, result.IsError, resultText(t, result))
+	var diff executor.DirDiff
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &diff))
+	assert.Equal(t, []string{"new.txt"}, diff.Added)
+	assert.Equal(t, []string{"old.txt"}, diff.Removed)
+	assert.Empty(t, diff.Changed)
+
+	// Both directories must be allowed for the session
+	result = callInSession(t, handler, "scoped", map[string]interface{}{"from": "a", "to": "b"})
+	require.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "directory not allowed for this session")
+
+	// Executor errors are returned as tool errors
+	result = callInSession(t, handler, "dev", map[string]interface{}{"from": "a", "to": "missing"})
+	require.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "no such file or directory")
+}
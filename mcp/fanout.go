@@ -0,0 +1,176 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// defaultFanoutMaxParallel is the parallelism cap when fanout_max_parallel is not set
+const defaultFanoutMaxParallel = 4
+
+// fanoutResult is the command_fanout response
+type fanoutResult struct {
+	Results map[string]types.CommandResult `json:"results"`
+	Skipped []string                       `json:"skipped,omitempty"`
+}
+
+// RegisterFanoutTool registers the tool that runs one command in several directories
+func RegisterFanoutTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, budget *sessionBudget, policy *sessionPolicy) error {
+	zap.S().Debugw("registering command_fanout tool")
+
+	fanoutTool := mcp.NewTool("command_fanout",
+		mcp.WithDescription("Run the same command in each of several directories (e.g. the projects of a monorepo) and return the result for each directory"),
+		mcp.WithString("command",
+			mcp.Description("The command to run in every directory"),
+			mcp.Required(),
+		),
+		mcp.WithArray("dirs",
+			mcp.Description("The directories to run the command in; each must be allowed"),
+			mcp.Items(map[string]interface{}{"type": "string"}),
+			mcp.Required(),
+		),
+		mcp.WithNumber("parallelism",
+			mcp.Description(fmt.Sprintf("How many directories to run at once (default and maximum %d)", fanoutMaxParallel(cfg))),
+		),
+		mcp.WithBoolean("stop_on_failure",
+			mcp.Description("Start no further directories once one fails; directories not run are listed in skipped"),
+		),
+	)
+	mcpServer.AddTool(fanoutTool, newFanoutHandler(cmdExecutor, cfg, budget, policy))
+
+	return nil
+}
+
+// fanoutMaxParallel returns the configured cap on parallel directories
+func fanoutMaxParallel(cfg *config.Config) int {
+	if cfg.CommandExec.FanoutMaxParallel > 0 {
+		return cfg.CommandExec.FanoutMaxParallel
+	}
+	return defaultFanoutMaxParallel
+}
+
+// newFanoutHandler creates the handler for the command_fanout tool
+func newFanoutHandler(cmdExecutor executor.CommandExecutor, cfg *config.Config, budget *sessionBudget, policy *sessionPolicy) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		command, _ := request.Params.Arguments["command"].(string)
+		command = executor.NormalizeCommand(command)
+		if command == "" {
+			return mcp.NewToolResultError("empty command provided"), nil
+		}
+
+		// Collect the directories, each once
+		dirsVal, _ := request.Params.Arguments["dirs"].([]interface{})
+		if len(dirsVal) == 0 {
+			return mcp.NewToolResultError("dirs must list at least one directory"), nil
+		}
+		dirs := make([]string, 0, len(dirsVal))
+		seen := make(map[string]bool, len(dirsVal))
+		for _, v := range dirsVal {
+			dir, ok := v.(string)
+			if !ok || dir == "" {
+				return mcp.NewToolResultError("dirs must be non-empty strings"), nil
+			}
+			if seen[dir] {
+				return mcp.NewToolResultError(fmt.Sprintf("directory listed twice: %s", dir)), nil
+			}
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+
+		// The command and every directory are checked before anything runs
+		if !policy.isCommandAllowed(ctx, cmdExecutor, command) {
+			return mcp.NewToolResultError(notAllowedMessage(cfg, policy.allowedCommands(ctx, cmdExecutor), command)), nil
+		}
+		for _, dir := range dirs {
+			if !policy.isDirectoryAllowed(ctx, dir) {
+				return mcp.NewToolResultError(fmt.Sprintf("directory not allowed for this session: %s", dir)), nil
+			}
+			if err := cmdExecutor.Validate(ctx, command, executor.Options{WorkingDir: dir}); err != nil {
+				return mcp.NewToolResultError(validatorDenialMessage(cfg, err)), nil
+			}
+		}
+
+		sessionID := sessionIDFromContext(ctx)
+		if budget.exhausted(sessionID) {
+			return mcp.NewToolResultError(budget.exhaustedMessage()), nil
+		}
+
+		parallelism := fanoutMaxParallel(cfg)
+		if parallelVal, ok := request.Params.Arguments["parallelism"].(float64); ok && parallelVal >= 1 && int(parallelVal) < parallelism {
+			parallelism = int(parallelVal)
+		}
+		stopOnFailure, _ := request.Params.Arguments["stop_on_failure"].(bool)
+
+		zap.S().Debugw("executing command_fanout",
+			"command", command,
+			"dirs", dirs,
+			"parallelism", parallelism)
+
+		options := executor.Options{SessionID: sessionID, SessionPolicyVersion: policy.version(ctx)}
+		result := runFanout(cmdExecutor, budget, options, command, dirs, parallelism, stopOnFailure)
+
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			zap.S().Errorw("failed to marshal result to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal result to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
+
+// runFanout runs the command in each directory with the given options, at
+// most parallelism at a time, in the order given. With stopOnFailure, directories not started by
+// the time one fails are skipped.
+func runFanout(cmdExecutor executor.CommandExecutor, budget *sessionBudget, options executor.Options, command string, dirs []string, parallelism int, stopOnFailure bool) fanoutResult {
+	result := fanoutResult{Results: make(map[string]types.CommandResult, len(dirs))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	failed := false
+	slots := make(chan struct{}, parallelism)
+	for _, dir := range dirs {
+		slots <- struct{}{}
+
+		mu.Lock()
+		if failed && stopOnFailure {
+			result.Skipped = append(result.Skipped, dir)
+			mu.Unlock()
+			<-slots
+			continue
+		}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			dirOptions := options
+			dirOptions.WorkingDir = dir
+
+			startedAt := time.Now()
+			dirResult, _ := cmdExecutor.Execute(command, dirOptions)
+			budget.add(options.SessionID, time.Since(startedAt))
+
+			mu.Lock()
+			defer mu.Unlock()
+			result.Results[dir] = dirResult
+			if !dirResult.Success {
+				failed = true
+			}
+		}(dir)
+	}
+	wg.Wait()
+
+	return result
+}
@@ -0,0 +1,86 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// newFanoutTestHandler - Create a command_fanout handler over two project directories
+func newFanoutTestHandler(t *testing.T) (string, string, func(args map[string]interface{}) fanoutResult) {
+	t.Helper()
+
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	root := t.TempDir()
+	a := filepath.Join(root, "a")
+	b := filepath.Join(root, "b")
+	require.NoError(t, os.Mkdir(a, 0755))
+	require.NoError(t, os.Mkdir(b, 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(a, "go.mod"), nil, 0644))
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"sh", "ls"}
+	cfg.CommandExec.DefaultWorkingDir = root
+	cfg.CommandExec.AllowedDirs = []string{root}
+	cfg.CommandExec.PathBehavior = "prepend"
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newFanoutHandler(cmdExecutor, cfg, nil, nil)
+
+	return a, b, func(args map[string]interface{}) fanoutResult {
+		t.Helper()
+		result := callCommandExec(t, handler, args)
+		require.False(t, result.IsError, resultText(t, result))
+		var fanout fanoutResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &fanout))
+		return fanout
+	}
+}
+
+// TestCommandFanout - Test that the command runs in each directory in parallel
+func TestCommandFanout(t *testing.T) {
+	a, b, call := newFanoutTestHandler(t)
+
+	// Each run waits for the other to start, so both only succeed when run in parallel
+	script := "touch started; for i in $(seq 50); do [ -e ../a/started ] && [ -e ../b/started ] && exit 0; sleep 0.1; done; exit 1\n"
+	for _, dir := range []string{a, b} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "wait.sh"), []byte(script), 0644))
+	}
+
+	fanout := call(map[string]interface{}{"command": "sh wait.sh", "dirs": []interface{}{a, b}})
+	require.Len(t, fanout.Results, 2)
+	assert.True(t, fanout.Results[a].Success)
+	assert.True(t, fanout.Results[b].Success)
+	assert.Equal(t, a, fanout.Results[a].WorkingDir)
+	assert.Equal(t, b, fanout.Results[b].WorkingDir)
+	assert.Empty(t, fanout.Skipped)
+}
+
+// TestCommandFanoutStopOnFailure - Test run-all and stop-on-first-failure
+func TestCommandFanoutStopOnFailure(t *testing.T) {
+	a, b, call := newFanoutTestHandler(t)
+
+	// Only a has a go.mod, so the check fails in a missing directory first
+	missing := filepath.Join(filepath.Dir(a), "missing")
+	fanout := call(map[string]interface{}{"command": "ls go.mod", "dirs": []interface{}{missing, b, a}, "parallelism": 1.0})
+	require.Len(t, fanout.Results, 3)
+	assert.False(t, fanout.Results[missing].Success)
+	assert.False(t, fanout.Results[b].Success)
+	assert.Equal(t, "go.mod\n", fanout.Results[a].Stdout)
+
+	fanout = call(map[string]interface{}{"command": "ls go.mod", "dirs": []interface{}{b, a}, "parallelism": 1.0, "stop_on_failure": true})
+	require.Len(t, fanout.Results, 1)
+	assert.False(t, fanout.Results[b].Success)
+	assert.Equal(t, []string{a}, fanout.Skipped)
+}
@@ -0,0 +1,53 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// RegisterLastResultTool registers the tool returning a recent command result without re-running it
+func RegisterLastResultTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, outputs *outputStore) error {
+	zap.S().Debugw("registering last_result tool")
+
+	lastResultTool := mcp.NewTool("last_result",
+		mcp.WithDescription("Return the most recent command result again, without re-running the command (useful for non-idempotent commands like git pull)"),
+		mcp.WithString("label",
+			mcp.Description("Only consider results of command_exec calls made with this label"),
+		),
+		mcp.WithString("command",
+			mcp.Description("Only consider results of this command line or program (e.g. 'git')"),
+		),
+	)
+	mcpServer.AddTool(lastResultTool, newLastResultHandler(cmdExecutor, cfg, outputs))
+
+	return nil
+}
+
+// newLastResultHandler creates the handler for the last_result tool
+func newLastResultHandler(cmdExecutor executor.CommandExecutor, cfg *config.Config, outputs *outputStore) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		label, _ := request.Params.Arguments["label"].(string)
+		command, _ := request.Params.Arguments["command"].(string)
+
+		result, ok := cmdExecutor.LastResult(label, command)
+		if !ok {
+			return mcp.NewToolResultError("no matching result in the history"), nil
+		}
+
+		// Offload large output the same way command_exec does
+		storeLargeOutput(&result, cfg.CommandExec.InlineOutputLimit, outputs)
+
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			zap.S().Errorw("failed to marshal result to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal result to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
@@ -0,0 +1,57 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestLastResultTool - Test fetching the last result and filtering by label
+func TestLastResultTool(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	execHandler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+	lastHandler := newLastResultHandler(cmdExecutor, cfg, nil)
+
+	callLastResult := func(args map[string]interface{}) *mcp.CallToolResult {
+		request := mcp.CallToolRequest{}
+		request.Params.Arguments = args
+		result, err := lastHandler(context.Background(), request)
+		require.NoError(t, err)
+		return result
+	}
+
+	// Nothing has run yet
+	assert.True(t, callLastResult(map[string]interface{}{}).IsError)
+
+	require.False(t, callCommandExec(t, execHandler, map[string]interface{}{"command": "echo pulled", "label": "pull"}).IsError)
+	require.False(t, callCommandExec(t, execHandler, map[string]interface{}{"command": "echo later"}).IsError)
+
+	var last types.CommandResult
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, callLastResult(map[string]interface{}{}))), &last))
+	assert.Equal(t, "later\n", last.Stdout)
+
+	require.NoError(t, json.Unmarshal([]byte(resultText(t, callLastResult(map[string]interface{}{"label": "pull"}))), &last))
+	assert.Equal(t, "pulled\n", last.Stdout)
+	assert.Equal(t, "pull", last.Label)
+
+	assert.True(t, callLastResult(map[string]interface{}{"label": "missing"}).IsError)
+}
@@ -0,0 +1,162 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+const (
+	// outputURITemplate is the resource template for stored command output
+	outputURITemplate = "command-output://{id}/{stream}"
+
+	defaultOutputRetention  = 10 * time.Minute
+	defaultMaxStoredOutputs = 100
+)
+
+// storedOutput is command output kept for later retrieval
+type storedOutput struct {
+	stdout    string
+	stderr    string
+	expiresAt time.Time
+}
+
+// outputStore keeps large command outputs for retrieval as resources
+type outputStore struct {
+	mu         sync.Mutex
+	entries    map[string]storedOutput
+	order      []string
+	retention  time.Duration
+	maxEntries int
+	now        func() time.Time
+}
+
+// newOutputStore creates a new output store from the configuration
+func newOutputStore(cfg *config.Config) *outputStore {
+	retention := time.Duration(cfg.CommandExec.OutputRetentionSeconds) * time.Second
+	if retention <= 0 {
+		retention = defaultOutputRetention
+	}
+
+	maxEntries := cfg.CommandExec.MaxStoredOutputs
+	if maxEntries <= 0 {
+		maxEntries = defaultMaxStoredOutputs
+	}
+
+	return &outputStore{
+		entries:    make(map[string]storedOutput),
+		retention:  retention,
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+// put stores the output and returns its ID
+func (s *outputStore) put(stdout, stderr string) (string, error) {
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(idBytes)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked()
+
+	// Make room by dropping the oldest entries
+	for len(s.order) >= s.maxEntries {
+		delete(s.entries, s.order[0])
+		s.order = s.order[1:]
+	}
+
+	s.entries[id] = storedOutput{
+		stdout:    stdout,
+		stderr:    stderr,
+		expiresAt: s.now().Add(s.retention),
+	}
+	s.order = append(s.order, id)
+
+	return id, nil
+}
+
+// get returns the stored output for the ID, if it has not expired
+func (s *outputStore) get(id string) (storedOutput, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictLocked()
+
+	output, ok := s.entries[id]
+	return output, ok
+}
+
+// evictLocked removes expired entries; the caller must hold the lock
+func (s *outputStore) evictLocked() {
+	now := s.now()
+	kept := s.order[:0]
+	for _, id := range s.order {
+		if now.After(s.entries[id].expiresAt) {
+			delete(s.entries, id)
+			continue
+		}
+		kept = append(kept, id)
+	}
+	s.order = kept
+}
+
+// outputURI returns the resource URI for a stream of stored output
+func outputURI(id, stream string) string {
+	return fmt.Sprintf("command-output://%s/%s", id, stream)
+}
+
+// registerOutputResource registers the resource template that serves stored output
+func registerOutputResource(mcpServer *server.MCPServer, outputs *outputStore) {
+	zap.S().Debugw("registering command output resource")
+
+	template := mcp.NewResourceTemplate(outputURITemplate, "Command output",
+		mcp.WithTemplateDescription("Output of a command that was too large to return inline"),
+		mcp.WithTemplateMIMEType("text/plain"),
+	)
+
+	mcpServer.AddResourceTemplate(template, newOutputResourceHandler(outputs))
+}
+
+// newOutputResourceHandler creates the handler that serves stored output
+func newOutputResourceHandler(outputs *outputStore) server.ResourceTemplateHandlerFunc {
+	return func(ctx context.Context, request mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+		id, _ := request.Params.Arguments["id"].(string)
+		stream, _ := request.Params.Arguments["stream"].(string)
+
+		output, ok := outputs.get(id)
+		if !ok {
+			return nil, fmt.Errorf("output not found or expired: %s", id)
+		}
+
+		var text string
+		switch stream {
+		case "stdout":
+			text = output.stdout
+		case "stderr":
+			text = output.stderr
+		default:
+			return nil, fmt.Errorf("unknown output stream: %s", stream)
+		}
+
+		return []mcp.ResourceContents{
+			mcp.TextResourceContents{
+				URI:      request.Params.URI,
+				MIMEType: "text/plain",
+				Text:     text,
+			},
+		}, nil
+	}
+}
@@ -0,0 +1,54 @@
+package mcp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestOutputStoreExpiry - Test that stored output expires after the retention period
+func TestOutputStoreExpiry(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.OutputRetentionSeconds = 60
+
+	now := time.Unix(1000, 0)
+	outputs := newOutputStore(cfg)
+	outputs.now = func() time.Time { return now }
+
+	id, err := outputs.put("out", "err")
+	require.NoError(t, err)
+
+	output, ok := outputs.get(id)
+	assert.True(t, ok)
+	assert.Equal(t, "out", output.stdout)
+	assert.Equal(t, "err", output.stderr)
+
+	now = now.Add(61 * time.Second)
+	_, ok = outputs.get(id)
+	assert.False(t, ok)
+}
+
+// TestOutputStoreEviction - Test that the oldest output is evicted when the store is full
+func TestOutputStoreEviction(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.MaxStoredOutputs = 2
+
+	outputs := newOutputStore(cfg)
+
+	first, err := outputs.put("1", "")
+	require.NoError(t, err)
+	second, err := outputs.put("2", "")
+	require.NoError(t, err)
+	third, err := outputs.put("3", "")
+	require.NoError(t, err)
+
+	_, ok := outputs.get(first)
+	assert.False(t, ok)
+	_, ok = outputs.get(second)
+	assert.True(t, ok)
+	_, ok = outputs.get(third)
+	assert.True(t, ok)
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// precheckResult - Result of the precheck tool
+type precheckResult struct {
+	Command      string `json:"command"`
+	Allowed      bool   `json:"allowed"`
+	Reason       string `json:"reason,omitempty"`
+	Builtin      bool   `json:"builtin,omitempty"`
+	BinaryPath   string `json:"binary_path,omitempty"`
+	ResolveError string `json:"resolve_error,omitempty"`
+}
+
+// RegisterPrecheckTool registers the tool that checks a command without running it
+func RegisterPrecheckTool(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, policy *sessionPolicy) error {
+	zap.S().Debugw("registering precheck tool")
+
+	precheckTool := mcp.NewTool("precheck",
+		mcp.WithDescription("Check whether a command would be allowed and which binary it resolves to, without running it"),
+		mcp.WithString("command",
+			mcp.Description("The command to check"),
+			mcp.Required(),
+		),
+		mcp.WithString("working_dir",
+			mcp.Description("Optional working directory the command would run in"),
+		),
+	)
+
+	mcpServer.AddTool(precheckTool, newPrecheckHandler(cmdExecutor, cfg, policy))
+
+	return nil
+}
+
+// newPrecheckHandler creates the handler for the precheck tool
+func newPrecheckHandler(cmdExecutor executor.CommandExecutor, cfg *config.Config, policy *sessionPolicy) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		command, _ := request.Params.Arguments["command"].(string)
+		command = executor.NormalizeCommand(command)
+		if command == "" {
+			return mcp.NewToolResultError("empty command provided"), nil
+		}
+
+		var options executor.Options
+		if workingDirVal, ok := request.Params.Arguments["working_dir"].(string); ok {
+			options.WorkingDir = workingDirVal
+		}
+
+		// Allow verdict: the same checks command_exec makes, then the custom validator
+		command, denial := checkCommand(ctx, cmdExecutor, cfg, policy, command, true, options.WorkingDir, nil)
+		result := precheckResult{Command: command}
+		allowlisted := denial == ""
+		if !allowlisted {
+			result.Reason = denial
+		} else if err := cmdExecutor.Validate(ctx, command, options); err != nil {
+			result.Reason = err.Error()
+		} else {
+			result.Allowed = true
+		}
+
+		// Resolution is only reported for allowed commands; built-ins never run a binary
+		if allowlisted {
+			if cmdExecutor.IsBuiltinCommand(command) {
+				result.Builtin = true
+			} else if binaryPath, err := cmdExecutor.ResolveBinaryPath(command); err != nil {
+				result.ResolveError = err.Error()
+			} else {
+				result.BinaryPath = binaryPath
+			}
+		}
+
+		jsonBytes, err := json.Marshal(result)
+		if err != nil {
+			zap.S().Errorw("failed to marshal result to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal result to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
@@ -0,0 +1,153 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestPrecheck - Test the allow verdict and binary resolution
+func TestPrecheck(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"ls", "no-such-command", "pwd"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newPrecheckHandler(cmdExecutor, cfg, nil)
+
+	tests := []struct {
+		name    string
+		command string
+		want    precheckResult
+	}{
+		{
+			name:    "allowed and resolvable",
+			command: "ls -la",
+			want:    precheckResult{Command: "ls -la", Allowed: true},
+		},
+		{
+			name:    "allowed and unresolvable",
+			command: "no-such-command",
+			want: precheckResult{
+				Command:      "no-such-command",
+				Allowed:      true,
+				ResolveError: "command not found: no-such-command",
+			},
+		},
+		{
+			name:    "disallowed",
+			command: "rm -rf /",
+			want: precheckResult{
+				Command: "rm -rf /",
+				Reason:  "command not allowed: rm -rf /",
+			},
+		},
+		{
+			name:    "built-in",
+			command: "pwd",
+			want:    precheckResult{Command: "pwd", Allowed: true, Builtin: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callCommandExec(t, handler, map[string]interface{}{"command": tt.command})
+			require.False(t, result.IsError)
+
+			var got precheckResult
+			require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &got))
+
+			// The resolved path depends on the host, so only check that one was found
+			if tt.want.Allowed && tt.want.ResolveError == "" && !tt.want.Builtin {
+				assert.NotEmpty(t, got.BinaryPath)
+				got.BinaryPath = ""
+			}
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestPrecheckMatchesCommandExec - Test that precheck reports built-ins, aliases, and session directories as command_exec handles them
+func TestPrecheckMatchesCommandExec(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"ls", "cat"}
+		cfg.CommandExec.UseBuiltinLs = true
+		cfg.CommandExec.Aliases = map[string]string{"ll": "ls -l", "rmall": "rm -rf"}
+	})
+	tenant := filepath.Join(cfg.CommandExec.DefaultWorkingDir, "tenant")
+	require.NoError(t, os.Mkdir(tenant, 0755))
+	policy := newSessionPolicy(cfg, fakePolicyResolver{
+		"dev": {AllowedCommands: []string{"ls"}, AllowedDirs: []string{tenant}},
+	})
+	precheck := newPrecheckHandler(cmdExecutor, cfg, policy)
+	commandExec := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+
+	tests := []struct {
+		name    string
+		session string
+		args    map[string]interface{}
+		want    precheckResult
+	}{
+		{
+			name:    "built-in ls",
+			session: "guest",
+			args:    map[string]interface{}{"command": "ls"},
+			want:    precheckResult{Command: "ls", Allowed: true, Builtin: true},
+		},
+		{
+			name:    "alias to a built-in",
+			session: "guest",
+			args:    map[string]interface{}{"command": "ll"},
+			want:    precheckResult{Command: "ls -l", Allowed: true, Builtin: true},
+		},
+		{
+			name:    "alias to a disallowed program",
+			session: "guest",
+			args:    map[string]interface{}{"command": "rmall x"},
+			want:    precheckResult{Command: "rm -rf x", Reason: "command not allowed: rm -rf x"},
+		},
+		{
+			name:    "outside the session's directories",
+			session: "dev",
+			args:    map[string]interface{}{"command": "ls"},
+			want:    precheckResult{Command: "ls", Reason: "directory not allowed for this session: " + cfg.CommandExec.DefaultWorkingDir},
+		},
+		{
+			name:    "inside the session's directories",
+			session: "dev",
+			args:    map[string]interface{}{"command": "ls", "working_dir": tenant},
+			want:    precheckResult{Command: "ls", Allowed: true, Builtin: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := callInSession(t, precheck, tt.session, tt.args)
+			require.False(t, result.IsError)
+
+			var got precheckResult
+			require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &got))
+			assert.Equal(t, tt.want, got)
+
+			// command_exec denies exactly what precheck does, with the same message
+			result = callInSession(t, commandExec, tt.session, tt.args)
+			assert.Equal(t, !tt.want.Allowed, result.IsError)
+			if !tt.want.Allowed {
+				assert.Equal(t, tt.want.Reason, resultText(t, result))
+			}
+		})
+	}
+}
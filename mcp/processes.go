@@ -0,0 +1,61 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// RegisterProcessTools registers the tools that list and kill running commands
+func RegisterProcessTools(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor) error {
+	zap.S().Debugw("registering list_processes and kill_process tools")
+
+	listProcessesTool := mcp.NewTool("list_processes",
+		mcp.WithDescription("List the commands currently running, with their execution ID, PID, start time, and session"),
+	)
+	mcpServer.AddTool(listProcessesTool, newListProcessesHandler(cmdExecutor))
+
+	killProcessTool := mcp.NewTool("kill_process",
+		mcp.WithDescription("Send the configured kill signal to a running command"),
+		mcp.WithString("id",
+			mcp.Description("The execution ID reported by list_processes"),
+			mcp.Required(),
+		),
+	)
+	mcpServer.AddTool(killProcessTool, newKillProcessHandler(cmdExecutor))
+
+	return nil
+}
+
+// newListProcessesHandler creates the handler for the list_processes tool
+func newListProcessesHandler(cmdExecutor executor.CommandExecutor) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		jsonBytes, err := json.Marshal(cmdExecutor.ListProcesses())
+		if err != nil {
+			zap.S().Errorw("failed to marshal processes to JSON", "error", err)
+			return mcp.NewToolResultError("failed to marshal processes to JSON"), nil
+		}
+		return mcp.NewToolResultText(string(jsonBytes)), nil
+	}
+}
+
+// newKillProcessHandler creates the handler for the kill_process tool
+func newKillProcessHandler(cmdExecutor executor.CommandExecutor) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		id, _ := request.Params.Arguments["id"].(string)
+
+		if err := cmdExecutor.KillProcess(id); err != nil {
+			zap.S().Warnw("failed to kill process",
+				"id", id,
+				"error", err)
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		return mcp.NewToolResultText(fmt.Sprintf("signaled process %s", id)), nil
+	}
+}
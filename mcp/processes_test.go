@@ -0,0 +1,72 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestListAndKillProcessTools - Test listing a running command and killing it through the tools
+func TestListAndKillProcessTools(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"sleep"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	execHandler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+	listHandler := newListProcessesHandler(cmdExecutor)
+	killHandler := newKillProcessHandler(cmdExecutor)
+
+	done := make(chan *mcp.CallToolResult, 1)
+	go func() {
+		done <- callCommandExec(t, execHandler, map[string]interface{}{"command": "sleep 30"})
+	}()
+
+	var procs []executor.RunningProcess
+	require.Eventually(t, func() bool {
+		result, err := listHandler(context.Background(), mcp.CallToolRequest{})
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &procs))
+		return len(procs) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, "sleep 30", procs[0].Command)
+
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = map[string]interface{}{"id": procs[0].ID}
+	result, err := killHandler(context.Background(), request)
+	require.NoError(t, err)
+	assert.False(t, result.IsError)
+
+	select {
+	case result := <-done:
+		// Processes are listed and killed by the execution ID their result carries
+		var commandResult types.CommandResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+		assert.Equal(t, "signal: terminated", commandResult.Error)
+		assert.Equal(t, procs[0].ID, commandResult.ExecutionID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("killed command did not return")
+	}
+
+	// Unknown IDs are reported as errors
+	request.Params.Arguments = map[string]interface{}{"id": "missing"}
+	result, err = killHandler(context.Background(), request)
+	require.NoError(t, err)
+	assert.True(t, result.IsError)
+}
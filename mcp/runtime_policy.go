@@ -0,0 +1,155 @@
+package mcp
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// runtime_policy_scope values
+const (
+	runtimePolicyScopeServer  = "server"
+	runtimePolicyScopeSession = "session"
+)
+
+// sessionPolicy holds what applies only to some sessions: allowlist changes made
+// at runtime by the session itself, and policies from a SessionPolicyResolver
+type sessionPolicy struct {
+	mu        sync.Mutex
+	overrides map[string]map[string]bool
+	resolver  SessionPolicyResolver
+}
+
+// newSessionPolicy creates the per-session policy, or nil if changes are
+// server-scoped and there is no resolver
+func newSessionPolicy(cfg *config.Config, resolver SessionPolicyResolver) *sessionPolicy {
+	scope := cfg.CommandExec.RuntimePolicyScope
+	if scope != runtimePolicyScopeSession {
+		if scope != runtimePolicyScopeServer && scope != "" {
+			zap.S().Warnw("Invalid runtime_policy_scope setting, using default 'server'",
+				"value", scope)
+		}
+		if resolver == nil {
+			return nil
+		}
+		return &sessionPolicy{resolver: resolver}
+	}
+
+	return &sessionPolicy{overrides: make(map[string]map[string]bool), resolver: resolver}
+}
+
+// sessionScoped reports whether runtime allowlist changes apply only to the session making them
+func (p *sessionPolicy) sessionScoped() bool {
+	return p != nil && p.overrides != nil
+}
+
+// isCommandAllowed checks the session's overrides, then its resolved policy,
+// before the executor's allowlist
+func (p *sessionPolicy) isCommandAllowed(ctx context.Context, cmdExecutor executor.CommandExecutor, command string) bool {
+	if p != nil {
+		if parts := strings.Fields(command); len(parts) > 0 {
+			p.mu.Lock()
+			allowed, ok := p.overrides[sessionIDFromContext(ctx)][parts[0]]
+			p.mu.Unlock()
+			if ok {
+				return allowed
+			}
+
+			resolved, err := p.resolve(ctx)
+			if err != nil {
+				return false
+			}
+			if resolved != nil {
+				return slices.Contains(resolved.AllowedCommands, parts[0])
+			}
+		}
+	}
+
+	return cmdExecutor.IsCommandAllowed(command)
+}
+
+// set records whether the program is allowed for the session
+func (p *sessionPolicy) set(sessionID string, name string, allowed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.overrides[sessionID] == nil {
+		p.overrides[sessionID] = make(map[string]bool)
+	}
+	p.overrides[sessionID][name] = allowed
+}
+
+// RegisterRuntimePolicyTools registers the tools that change the allowlist at runtime,
+// if allow_runtime_policy_changes is enabled
+func RegisterRuntimePolicyTools(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, policy *sessionPolicy) error {
+	if !cfg.CommandExec.AllowRuntimePolicyChanges {
+		return nil
+	}
+
+	zap.S().Debugw("registering allow_command and disallow_command tools")
+
+	allowCommandTool := mcp.NewTool("allow_command",
+		mcp.WithDescription("Add a program to the allowed command list without restarting the server"),
+		mcp.WithString("command",
+			mcp.Description("The program name to allow"),
+			mcp.Required(),
+		),
+	)
+	mcpServer.AddTool(allowCommandTool, newPolicyChangeHandler(cmdExecutor, policy, true))
+
+	disallowCommandTool := mcp.NewTool("disallow_command",
+		mcp.WithDescription("Remove a program from the allowed command list without restarting the server"),
+		mcp.WithString("command",
+			mcp.Description("The program name to disallow"),
+			mcp.Required(),
+		),
+	)
+	mcpServer.AddTool(disallowCommandTool, newPolicyChangeHandler(cmdExecutor, policy, false))
+
+	return nil
+}
+
+// newPolicyChangeHandler creates the handler for allow_command (allow) or disallow_command
+func newPolicyChangeHandler(cmdExecutor executor.CommandExecutor, policy *sessionPolicy, allow bool) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		name, _ := request.Params.Arguments["command"].(string)
+		if name == "" || len(strings.Fields(name)) != 1 || strings.TrimSpace(name) != name {
+			return mcp.NewToolResultError("command must be a single program name"), nil
+		}
+
+		action := "disallow"
+		if allow {
+			action = "allow"
+		}
+
+		sessionID := sessionIDFromContext(ctx)
+		scope := runtimePolicyScopeServer
+		changed := true
+		if policy.sessionScoped() {
+			scope = runtimePolicyScopeSession
+			policy.set(sessionID, name, allow)
+		} else if allow {
+			changed = cmdExecutor.AllowCommand(name)
+		} else {
+			changed = cmdExecutor.DisallowCommand(name)
+		}
+
+		// Record every change made by the operator
+		zap.S().Infow("allowed command list changed",
+			"action", action,
+			"command", name,
+			"scope", scope,
+			"session_id", sessionID,
+			"changed", changed)
+
+		return mcp.NewToolResultText(fmt.Sprintf("%sed %s (%s scope)", action, name, scope)), nil
+	}
+}
@@ -0,0 +1,142 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// testSession - Minimal client session identified by ID
+type testSession struct {
+	id string
+}
+
+func (s testSession) Initialize()                                         {}
+func (s testSession) Initialized() bool                                   { return true }
+func (s testSession) NotificationChannel() chan<- mcp.JSONRPCNotification { return nil }
+func (s testSession) SessionID() string                                   { return s.id }
+
+// callInSession - Invoke a handler as the given session
+func callInSession(t *testing.T, handler server.ToolHandlerFunc, sessionID string, args map[string]interface{}) *mcp.CallToolResult {
+	t.Helper()
+
+	ctx := server.NewMCPServer("test", "0.0.0").WithContext(context.Background(), testSession{id: sessionID})
+	request := mcp.CallToolRequest{}
+	request.Params.Arguments = args
+
+	result, err := handler(ctx, request)
+	require.NoError(t, err)
+	return result
+}
+
+// newPolicyTestExecutor - Create an executor that only allows ls
+func newPolicyTestExecutor(t *testing.T, modify func(cfg *config.Config)) (executor.CommandExecutor, *config.Config) {
+	t.Helper()
+
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"ls"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.AllowRuntimePolicyChanges = true
+	if modify != nil {
+		modify(cfg)
+	}
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	return cmdExecutor, cfg
+}
+
+// TestRuntimePolicyServerScope - Test allowing a command at runtime and then running it
+func TestRuntimePolicyServerScope(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, nil)
+	policy := newSessionPolicy(cfg, nil)
+	require.Nil(t, policy)
+
+	execHandler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+	allowHandler := newPolicyChangeHandler(cmdExecutor, policy, true)
+	disallowHandler := newPolicyChangeHandler(cmdExecutor, policy, false)
+
+	result := callCommandExec(t, execHandler, map[string]interface{}{"command": "echo hi"})
+	assert.True(t, result.IsError)
+
+	result = callInSession(t, allowHandler, "a", map[string]interface{}{"command": "echo"})
+	require.False(t, result.IsError)
+	assert.Equal(t, "allowed echo (server scope)", resultText(t, result))
+	assert.Contains(t, cmdExecutor.GetAllowedCommands(), "echo")
+
+	// Every session sees the change
+	result = callInSession(t, execHandler, "b", map[string]interface{}{"command": "echo hi"})
+	require.False(t, result.IsError)
+	assert.Contains(t, resultText(t, result), `"stdout":"hi\n"`)
+
+	result = callInSession(t, disallowHandler, "a", map[string]interface{}{"command": "echo"})
+	require.False(t, result.IsError)
+	result = callCommandExec(t, execHandler, map[string]interface{}{"command": "echo hi"})
+	assert.True(t, result.IsError)
+
+	// Only single program names are accepted
+	result = callInSession(t, allowHandler, "a", map[string]interface{}{"command": "rm -rf"})
+	assert.True(t, result.IsError)
+}
+
+// TestRuntimePolicySessionScope - Test that session-scoped changes apply only to the session making them
+func TestRuntimePolicySessionScope(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.RuntimePolicyScope = runtimePolicyScopeSession
+	})
+	policy := newSessionPolicy(cfg, nil)
+	require.NotNil(t, policy)
+
+	execHandler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+	allowHandler := newPolicyChangeHandler(cmdExecutor, policy, true)
+	disallowHandler := newPolicyChangeHandler(cmdExecutor, policy, false)
+
+	result := callInSession(t, allowHandler, "a", map[string]interface{}{"command": "echo"})
+	require.False(t, result.IsError)
+	assert.Equal(t, "allowed echo (session scope)", resultText(t, result))
+
+	result = callInSession(t, execHandler, "a", map[string]interface{}{"command": "echo hi"})
+	assert.False(t, result.IsError)
+	result = callInSession(t, execHandler, "b", map[string]interface{}{"command": "echo hi"})
+	assert.True(t, result.IsError)
+
+	// Disallowing overrides the configured allowlist for the session only
+	result = callInSession(t, disallowHandler, "a", map[string]interface{}{"command": "ls"})
+	require.False(t, result.IsError)
+	result = callInSession(t, execHandler, "a", map[string]interface{}{"command": "ls"})
+	assert.True(t, result.IsError)
+	result = callInSession(t, execHandler, "b", map[string]interface{}{"command": "ls"})
+	assert.False(t, result.IsError)
+
+	// The server-wide allowlist is untouched
+	assert.Equal(t, []string{"ls"}, cmdExecutor.GetAllowedCommands())
+}
+
+// TestRuntimePolicyToolsGated - Test that the tools are only registered when enabled
+func TestRuntimePolicyToolsGated(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowRuntimePolicyChanges = false
+	})
+
+	mcpServer := server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	require.NoError(t, RegisterRuntimePolicyTools(mcpServer, cmdExecutor, cfg, nil))
+
+	response := mcpServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	resp, ok := response.(mcp.JSONRPCResponse)
+	require.True(t, ok)
+	listResult, ok := resp.Result.(mcp.ListToolsResult)
+	require.True(t, ok)
+	assert.Empty(t, listResult.Tools)
+}
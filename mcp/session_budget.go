@@ -0,0 +1,112 @@
+package mcp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// sessionBudget tracks the cumulative execution time of each MCP session
+type sessionBudget struct {
+	mu         sync.Mutex
+	budget     time.Duration
+	used       map[string]time.Duration
+	allowReset bool
+}
+
+// newSessionBudget creates a session budget from the configuration, or nil if unlimited
+func newSessionBudget(cfg *config.Config) *sessionBudget {
+	if cfg.CommandExec.SessionTimeBudgetSeconds <= 0 {
+		return nil
+	}
+
+	return &sessionBudget{
+		budget:     time.Duration(cfg.CommandExec.SessionTimeBudgetSeconds) * time.Second,
+		used:       make(map[string]time.Duration),
+		allowReset: cfg.CommandExec.AllowSessionReset,
+	}
+}
+
+// exhausted reports whether the session has used up its budget
+func (b *sessionBudget) exhausted(sessionID string) bool {
+	if b == nil {
+		return false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.used[sessionID] >= b.budget
+}
+
+// exhaustedMessage returns the error reported once the session has used up its
+// budget, pointing at reset_session only when that tool is registered
+func (b *sessionBudget) exhaustedMessage() string {
+	if b.allowReset {
+		return "session time budget exhausted; call reset_session to continue"
+	}
+	return "session time budget exhausted"
+}
+
+// add records execution time against the session
+func (b *sessionBudget) add(sessionID string, d time.Duration) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.used[sessionID] += d
+}
+
+// reset clears the execution time recorded for the session
+func (b *sessionBudget) reset(sessionID string) {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	delete(b.used, sessionID)
+}
+
+// sessionIDFromContext returns the ID of the MCP session making the request
+func sessionIDFromContext(ctx context.Context) string {
+	if session := server.ClientSessionFromContext(ctx); session != nil {
+		return session.SessionID()
+	}
+	return ""
+}
+
+// RegisterResetSessionTool registers the tool that resets per-session accounting.
+// The tool lets a session lift its own budget, so it is an operator opt-in.
+func RegisterResetSessionTool(mcpServer *server.MCPServer, cfg *config.Config, budget *sessionBudget) error {
+	if !cfg.CommandExec.AllowSessionReset {
+		return nil
+	}
+
+	zap.S().Debugw("registering reset_session tool")
+
+	resetSessionTool := mcp.NewTool("reset_session",
+		mcp.WithDescription("Reset the execution time used by the current session against its time budget"),
+	)
+
+	mcpServer.AddTool(resetSessionTool, newResetSessionHandler(budget))
+
+	return nil
+}
+
+// newResetSessionHandler creates the handler for the reset_session tool
+func newResetSessionHandler(budget *sessionBudget) server.ToolHandlerFunc {
+	return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		sessionID := sessionIDFromContext(ctx)
+		budget.reset(sessionID)
+
+		zap.S().Infow("session reset", "session_id", sessionID)
+		return mcp.NewToolResultText("session reset"), nil
+	}
+}
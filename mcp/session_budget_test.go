@@ -0,0 +1,109 @@
+package mcp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestSessionBudget - Test accounting per session
+func TestSessionBudget(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.SessionTimeBudgetSeconds = 10
+	budget := newSessionBudget(cfg)
+
+	budget.add("a", 6*time.Second)
+	assert.False(t, budget.exhausted("a"))
+	budget.add("a", 4*time.Second)
+	assert.True(t, budget.exhausted("a"))
+
+	// Other sessions are unaffected
+	assert.False(t, budget.exhausted("b"))
+
+	budget.reset("a")
+	assert.False(t, budget.exhausted("a"))
+
+	// No budget configured means unlimited
+	assert.Nil(t, newSessionBudget(&config.Config{}))
+	assert.False(t, (*sessionBudget)(nil).exhausted("a"))
+}
+
+// TestCommandExecSessionBudget - Test that commands are rejected once the budget is used up
+func TestCommandExecSessionBudget(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"sleep"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+
+	budget := &sessionBudget{budget: 150 * time.Millisecond, used: make(map[string]time.Duration), allowReset: true}
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, budget, nil, nil)
+
+	// Time accumulates across calls until the budget trips
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "sleep 0.1"})
+	assert.False(t, result.IsError)
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "sleep 0.1"})
+	assert.False(t, result.IsError)
+
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "sleep 0.1"})
+	assert.True(t, result.IsError)
+	assert.Contains(t, resultText(t, result), "session time budget exhausted")
+
+	// reset_session restores the budget
+	_, err = newResetSessionHandler(budget)(context.Background(), mcp.CallToolRequest{})
+	require.NoError(t, err)
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "sleep 0.1"})
+	assert.False(t, result.IsError)
+}
+
+// TestResetSessionToolGated - Test that the budget survives without the operator opt-in
+func TestResetSessionToolGated(t *testing.T) {
+	cfg := &config.Config{}
+	cfg.CommandExec.SessionTimeBudgetSeconds = 1
+	budget := newSessionBudget(cfg)
+	budget.add("", 2*time.Second)
+	require.True(t, budget.exhausted(""))
+	assert.NotContains(t, budget.exhaustedMessage(), "reset_session")
+
+	// reset_session is not offered, so the session cannot clear its own budget
+	mcpServer := server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	require.NoError(t, RegisterResetSessionTool(mcpServer, cfg, budget))
+
+	response := mcpServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	resp, ok := response.(mcp.JSONRPCResponse)
+	require.True(t, ok)
+	listResult, ok := resp.Result.(mcp.ListToolsResult)
+	require.True(t, ok)
+	assert.Empty(t, listResult.Tools)
+
+	response = mcpServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":2,"method":"tools/call","params":{"name":"reset_session"}}`))
+	_, ok = response.(mcp.JSONRPCError)
+	assert.True(t, ok)
+	assert.True(t, budget.exhausted(""))
+
+	// With the opt-in, the tool is registered and mentioned in the error
+	cfg.CommandExec.AllowSessionReset = true
+	budget = newSessionBudget(cfg)
+	assert.Contains(t, budget.exhaustedMessage(), "reset_session")
+
+	mcpServer = server.NewMCPServer("test", "0.0.0", server.WithToolCapabilities(true))
+	require.NoError(t, RegisterResetSessionTool(mcpServer, cfg, budget))
+	response = mcpServer.HandleMessage(context.Background(), []byte(`{"jsonrpc":"2.0","id":1,"method":"tools/list"}`))
+	listResult = response.(mcp.JSONRPCResponse).Result.(mcp.ListToolsResult)
+	require.Len(t, listResult.Tools, 1)
+	assert.Equal(t, "reset_session", listResult.Tools[0].Name)
+}
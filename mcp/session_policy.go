@@ -0,0 +1,128 @@
+package mcp
+
+import (
+	"context"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"go.uber.org/zap"
+)
+
+// SessionPolicy is the allowlist and directories a client session is held to
+// instead of the global configuration
+type SessionPolicy struct {
+	// AllowedCommands are the programs the session may run
+	AllowedCommands []string
+
+	// AllowedDirs, when set, are the directories the session's commands may
+	// run in and cd into, on top of the global allowed_dirs
+	AllowedDirs []string
+}
+
+// SessionPolicyResolver maps a client session to its policy, for multi-tenant
+// setups where clients get different allowlists. It is consulted on every call
+// with the call's context, which carries the session and whatever metadata the
+// transport attached to it (e.g. a role claim). A nil policy means the session
+// falls back to the global configuration; an error denies the call.
+type SessionPolicyResolver interface {
+	ResolveSessionPolicy(ctx context.Context, sessionID string) (*SessionPolicy, error)
+}
+
+// resolve returns the policy of the calling session, or nil if it has none
+func (p *sessionPolicy) resolve(ctx context.Context) (*SessionPolicy, error) {
+	if p == nil || p.resolver == nil {
+		return nil, nil
+	}
+
+	sessionID := sessionIDFromContext(ctx)
+	resolved, err := p.resolver.ResolveSessionPolicy(ctx, sessionID)
+	if err != nil {
+		zap.S().Warnw("failed to resolve session policy",
+			"session_id", sessionID,
+			"error", err)
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// allowedCommands returns the programs the calling session may run, as shown
+// in denials, so one tenant never sees another's allowlist
+func (p *sessionPolicy) allowedCommands(ctx context.Context, cmdExecutor executor.CommandExecutor) []string {
+	if resolved, err := p.resolve(ctx); err != nil {
+		return nil
+	} else if resolved != nil {
+		return resolved.AllowedCommands
+	}
+	return cmdExecutor.GetAllowedCommands()
+}
+
+// version identifies the calling session's own policy, its runtime overrides
+// and resolved policy, for the result's policy_version; "" means the session
+// is governed by the server's policy alone
+func (p *sessionPolicy) version(ctx context.Context) string {
+	if p == nil {
+		return ""
+	}
+
+	sections := make(map[string][]string)
+	p.mu.Lock()
+	for name, allowed := range p.overrides[sessionIDFromContext(ctx)] {
+		sections["overrides"] = append(sections["overrides"], name+"="+strconv.FormatBool(allowed))
+	}
+	p.mu.Unlock()
+
+	if resolved, err := p.resolve(ctx); err == nil && resolved != nil {
+		sections["allowed_commands"] = resolved.AllowedCommands
+		sections["allowed_dirs"] = resolved.AllowedDirs
+	}
+
+	if len(sections) == 0 {
+		return ""
+	}
+	return executor.PolicyHash(sections)
+}
+
+// restrictsDirectories reports whether the calling session's policy limits
+// the directories it may use
+func (p *sessionPolicy) restrictsDirectories(ctx context.Context) bool {
+	resolved, err := p.resolve(ctx)
+	return err == nil && resolved != nil && len(resolved.AllowedDirs) > 0
+}
+
+// isDirectoryAllowed checks dir against the calling session's allowed
+// directories, if its policy has any
+func (p *sessionPolicy) isDirectoryAllowed(ctx context.Context, dir string) bool {
+	resolved, err := p.resolve(ctx)
+	if err != nil {
+		return false
+	}
+	if resolved == nil || len(resolved.AllowedDirs) == 0 {
+		return true
+	}
+
+	dir = filepath.Clean(dir)
+	for _, allowedDir := range resolved.AllowedDirs {
+		rel, err := filepath.Rel(filepath.Clean(allowedDir), dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return true
+		}
+	}
+	return false
+}
+
+// commandDir returns the directory a call's session policy must allow: the
+// target of cd, as the executor resolves it, or else the directory the command
+// runs in
+func commandDir(cmdExecutor executor.CommandExecutor, command string, workingDir string, env map[string]string) (string, error) {
+	parts := strings.Fields(command)
+	if len(parts) > 0 && parts[0] == "cd" {
+		return cmdExecutor.ChangeDirectoryTarget(command, env)
+	}
+
+	if workingDir != "" {
+		return workingDir, nil
+	}
+	return cmdExecutor.GetCurrentWorkingDir(), nil
+}
@@ -0,0 +1,157 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/types"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePolicyResolver - SessionPolicyResolver with a fixed policy per session
+type fakePolicyResolver map[string]*SessionPolicy
+
+func (r fakePolicyResolver) ResolveSessionPolicy(ctx context.Context, sessionID string) (*SessionPolicy, error) {
+	if sessionID == "broken" {
+		return nil, errors.New("claims unavailable")
+	}
+	return r[sessionID], nil
+}
+
+// TestSessionPolicyResolver - Test that sessions resolving to different policies get different allowlists
+func TestSessionPolicyResolver(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.VerboseDenials = true
+	})
+	policy := newSessionPolicy(cfg, fakePolicyResolver{
+		"dev": {AllowedCommands: []string{"echo"}},
+		"ops": {AllowedCommands: []string{"ls", "pwd"}},
+	})
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+
+	result := callInSession(t, handler, "dev", map[string]interface{}{"command": "echo hi"})
+	assert.False(t, result.IsError)
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "ls"})
+	require.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: ls ('ls' is not in the allowed command list; allowed commands: echo)", resultText(t, result))
+
+	result = callInSession(t, handler, "ops", map[string]interface{}{"command": "ls"})
+	assert.False(t, result.IsError)
+	result = callInSession(t, handler, "ops", map[string]interface{}{"command": "echo hi"})
+	assert.True(t, result.IsError)
+
+	// Sessions without a policy fall back to the configuration; errors deny
+	result = callInSession(t, handler, "guest", map[string]interface{}{"command": "ls"})
+	assert.False(t, result.IsError)
+	result = callInSession(t, handler, "guest", map[string]interface{}{"command": "echo hi"})
+	assert.True(t, result.IsError)
+	result = callInSession(t, handler, "broken", map[string]interface{}{"command": "ls"})
+	assert.True(t, result.IsError)
+}
+
+// TestSessionPolicyAllowedDirs - Test that a session's directories limit where it runs and cds
+func TestSessionPolicyAllowedDirs(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"ls", "cd"}
+	})
+	root := cfg.CommandExec.DefaultWorkingDir
+	tenant := filepath.Join(root, "tenant")
+	require.NoError(t, os.Mkdir(tenant, 0755))
+
+	policy := newSessionPolicy(cfg, fakePolicyResolver{
+		"dev": {AllowedCommands: []string{"ls", "cd"}, AllowedDirs: []string{tenant}},
+	})
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+
+	// The current directory is outside the session's directories
+	result := callInSession(t, handler, "dev", map[string]interface{}{"command": "ls"})
+	require.True(t, result.IsError)
+	assert.Equal(t, "directory not allowed for this session: "+root, resultText(t, result))
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "ls", "working_dir": tenant})
+	assert.False(t, result.IsError)
+
+	// cd is checked on its target
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "cd .."})
+	assert.True(t, result.IsError)
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "cd ~", "env": map[string]interface{}{"HOME": root}})
+	require.True(t, result.IsError)
+	assert.Equal(t, "directory not allowed for this session: "+root, resultText(t, result))
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "cd tenant"})
+	require.False(t, result.IsError)
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "ls"})
+	assert.False(t, result.IsError)
+
+	// cd - and a bare cd are checked on where they lead
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "cd -"})
+	require.True(t, result.IsError)
+	assert.Equal(t, "directory not allowed for this session: "+root, resultText(t, result))
+	result = callInSession(t, handler, "dev", map[string]interface{}{"command": "cd", "env": map[string]interface{}{"HOME": tenant}})
+	assert.False(t, result.IsError)
+
+	// Other sessions keep the global directories
+	result = callInSession(t, handler, "guest", map[string]interface{}{"command": "cd " + root})
+	assert.False(t, result.IsError)
+}
+
+// TestSessionPolicyVersion - Test that sessions with their own policy report a different policy_version
+func TestSessionPolicyVersion(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, nil)
+	policy := newSessionPolicy(cfg, fakePolicyResolver{
+		"dev": {AllowedCommands: []string{"ls"}},
+		"ops": {AllowedCommands: []string{"ls", "pwd"}},
+	})
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+
+	versions := make(map[string]string)
+	for _, sessionID := range []string{"dev", "ops", "guest"} {
+		result := callInSession(t, handler, sessionID, map[string]interface{}{"command": "ls"})
+		require.False(t, result.IsError)
+
+		var commandResult types.CommandResult
+		require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+		require.NotEmpty(t, commandResult.PolicyVersion)
+		versions[sessionID] = commandResult.PolicyVersion
+	}
+	assert.NotEqual(t, versions["dev"], versions["ops"])
+	assert.NotEqual(t, versions["dev"], versions["guest"])
+}
+
+// TestHomeNavigationWithoutSessionDirs - Test that cd home, ~ and - work when no session policy limits directories
+func TestHomeNavigationWithoutSessionDirs(t *testing.T) {
+	cmdExecutor, cfg := newPolicyTestExecutor(t, func(cfg *config.Config) {
+		cfg.CommandExec.AllowedCommands = []string{"cd"}
+	})
+	root := cfg.CommandExec.DefaultWorkingDir
+	home := filepath.Join(root, "home")
+	require.NoError(t, os.MkdirAll(filepath.Join(home, "x"), 0755))
+	env := map[string]interface{}{"HOME": home}
+
+	for _, policy := range []*sessionPolicy{nil, newSessionPolicy(cfg, fakePolicyResolver{"dev": {AllowedCommands: []string{"cd"}}})} {
+		handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, policy)
+
+		tests := []struct {
+			command string
+			want    string
+		}{
+			{"cd", home},
+			{"cd ~/x", filepath.Join(home, "x")},
+			{"cd -", home},
+			{"cd ~", home},
+			{"cd " + root, root},
+		}
+		for _, tt := range tests {
+			result := callInSession(t, handler, "dev", map[string]interface{}{"command": tt.command, "env": env})
+			require.False(t, result.IsError, tt.command)
+
+			var commandResult types.CommandResult
+			require.NoError(t, json.Unmarshal([]byte(resultText(t, result)), &commandResult))
+			assert.Equal(t, tt.want, commandResult.WorkingDir, tt.command)
+		}
+	}
+}
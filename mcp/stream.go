@@ -0,0 +1,80 @@
+package mcp
+
+import (
+	"context"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"go.uber.org/zap"
+)
+
+// Notification methods used in streaming mode
+const (
+	outputNotification = "notifications/command_exec/output"
+	exitNotification   = "notifications/command_exec/exit"
+
+	// progressNotification is the standard MCP progress notification
+	progressNotification = "notifications/progress"
+)
+
+// notificationSink forwards streamed command output to the client as notifications
+type notificationSink struct {
+	send func(method string, params map[string]any) error
+
+	// progressToken is the token the client supplied to receive progress, if any
+	progressToken mcp.ProgressToken
+}
+
+// newNotificationSink creates a sink that notifies the client of the current request
+func newNotificationSink(ctx context.Context, progressToken mcp.ProgressToken) *notificationSink {
+	mcpServer := server.ServerFromContext(ctx)
+	return &notificationSink{
+		progressToken: progressToken,
+		send: func(method string, params map[string]any) error {
+			if mcpServer == nil {
+				return nil
+			}
+			return mcpServer.SendNotificationToClient(ctx, method, params)
+		},
+	}
+}
+
+// Output implements executor.StreamSink
+func (s *notificationSink) Output(stream string, data []byte) {
+	if err := s.send(outputNotification, map[string]any{
+		"stream": stream,
+		"data":   string(data),
+	}); err != nil {
+		zap.S().Warnw("failed to send output notification", "error", err)
+	}
+}
+
+// Exit implements executor.StreamSink
+func (s *notificationSink) Exit(event executor.ExitEvent) {
+	if err := s.send(exitNotification, map[string]any{
+		"exit_code":        event.ExitCode,
+		"duration_ms":      event.DurationMs,
+		"signal":           event.Signal,
+		"stdout_truncated": event.StdoutTruncated,
+		"stderr_truncated": event.StderrTruncated,
+	}); err != nil {
+		zap.S().Warnw("failed to send exit notification", "error", err)
+	}
+}
+
+// Progress implements executor.ProgressSink. Progress is only sent when the
+// client asked for it with a progress token.
+func (s *notificationSink) Progress(percent float64) {
+	if s.progressToken == nil {
+		return
+	}
+
+	if err := s.send(progressNotification, map[string]any{
+		"progressToken": s.progressToken,
+		"progress":      percent,
+		"total":         100,
+	}); err != nil {
+		zap.S().Warnw("failed to send progress notification", "error", err)
+	}
+}
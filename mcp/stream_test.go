@@ -0,0 +1,67 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sentNotification - Notification captured by a test sink
+type sentNotification struct {
+	method string
+	params map[string]any
+}
+
+// TestNotificationSink - Test the notifications sent in streaming mode
+func TestNotificationSink(t *testing.T) {
+	var sent []sentNotification
+	sink := &notificationSink{
+		send: func(method string, params map[string]any) error {
+			sent = append(sent, sentNotification{method: method, params: params})
+			return nil
+		},
+	}
+
+	sink.Output(executor.StreamStdout, []byte("hello\n"))
+	sink.Exit(executor.ExitEvent{ExitCode: 137, DurationMs: 42, Signal: "killed"})
+
+	require.Len(t, sent, 2)
+	assert.Equal(t, outputNotification, sent[0].method)
+	assert.Equal(t, map[string]any{"stream": "stdout", "data": "hello\n"}, sent[0].params)
+
+	assert.Equal(t, exitNotification, sent[1].method)
+	assert.Equal(t, map[string]any{
+		"exit_code":        137,
+		"duration_ms":      int64(42),
+		"signal":           "killed",
+		"stdout_truncated": false,
+		"stderr_truncated": false,
+	}, sent[1].params)
+}
+
+// TestNotificationSinkProgress - Test that progress is only sent with a progress token
+func TestNotificationSinkProgress(t *testing.T) {
+	var sent []sentNotification
+	send := func(method string, params map[string]any) error {
+		sent = append(sent, sentNotification{method: method, params: params})
+		return nil
+	}
+
+	// Without a token the client did not ask for progress
+	sink := &notificationSink{send: send}
+	sink.Progress(50)
+	assert.Empty(t, sent)
+
+	sink = &notificationSink{send: send, progressToken: "tok-1"}
+	sink.Progress(42.5)
+
+	require.Len(t, sent, 1)
+	assert.Equal(t, progressNotification, sent[0].method)
+	assert.Equal(t, map[string]any{
+		"progressToken": "tok-1",
+		"progress":      42.5,
+		"total":         100,
+	}, sent[0].params)
+}
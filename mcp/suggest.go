@@ -0,0 +1,48 @@
+package mcp
+
+// maxSuggestionDistance is the largest edit distance still treated as a typo
+const maxSuggestionDistance = 2
+
+// closestCommand returns the allowed command closest to name, if it is close enough to be a likely typo
+func closestCommand(name string, allowed []string) (string, bool) {
+	best := ""
+	bestDistance := maxSuggestionDistance + 1
+	for _, candidate := range allowed {
+		distance := levenshtein(name, candidate)
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	// Very short names are within a couple of edits of almost anything
+	if best == "" || bestDistance >= len([]rune(name)) {
+		return "", false
+	}
+	return best, true
+}
+
+// levenshtein returns the edit distance between a and b
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+
+	prev := make([]int, len(rb)+1)
+	curr := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		curr[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+
+	return prev[len(rb)]
+}
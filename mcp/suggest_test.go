@@ -0,0 +1,69 @@
+package mcp
+
+import (
+	"testing"
+
+	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest"
+)
+
+// TestLevenshtein - Test edit distances
+func TestLevenshtein(t *testing.T) {
+	assert.Equal(t, 0, levenshtein("git", "git"))
+	assert.Equal(t, 2, levenshtein("gti", "git"))
+	assert.Equal(t, 1, levenshtein("gitt", "git"))
+	assert.Equal(t, 3, levenshtein("", "git"))
+	assert.Equal(t, 3, levenshtein("kitten", "sitting"))
+}
+
+// TestClosestCommand - Test that near misses get a suggestion and far misses do not
+func TestClosestCommand(t *testing.T) {
+	allowed := []string{"git", "ls", "cat", "make"}
+
+	suggestion, ok := closestCommand("gti", allowed)
+	assert.True(t, ok)
+	assert.Equal(t, "git", suggestion)
+
+	suggestion, ok = closestCommand("mkae", allowed)
+	assert.True(t, ok)
+	assert.Equal(t, "make", suggestion)
+
+	_, ok = closestCommand("python", allowed)
+	assert.False(t, ok)
+
+	// Short names are not matched against everything
+	_, ok = closestCommand("rm", allowed)
+	assert.False(t, ok)
+}
+
+// TestCommandExecSuggestOnDenial - Test the did-you-mean hint in denial messages
+func TestCommandExecSuggestOnDenial(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"git", "ls"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+
+	cmdExecutor, err := executor.NewCommandExecutor(cfg)
+	require.NoError(t, err)
+	handler := newCommandExecHandler(cmdExecutor, cfg, nil, nil, nil, nil)
+
+	// No hint unless enabled
+	result := callCommandExec(t, handler, map[string]interface{}{"command": "gti status"})
+	assert.Equal(t, "command not allowed: gti status", resultText(t, result))
+
+	cfg.CommandExec.SuggestOnDenial = true
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "gti status"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: gti status (did you mean: git?)", resultText(t, result))
+
+	result = callCommandExec(t, handler, map[string]interface{}{"command": "python -V"})
+	assert.True(t, result.IsError)
+	assert.Equal(t, "command not allowed: python -V", resultText(t, result))
+}
@@ -1,14 +1,63 @@
 package mcp
 
 import (
+	"github.com/cnosuke/mcp-command-exec/config"
 	"github.com/cnosuke/mcp-command-exec/executor"
 	"github.com/mark3labs/mcp-go/server"
 )
 
-// RegisterAllTools registers all tools to the server
-func RegisterAllTools(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor) error {
+// RegisterAllTools registers all tools to the server. resolver, if not nil,
+// gives sessions their own allowlist and directories.
+func RegisterAllTools(mcpServer *server.MCPServer, cmdExecutor executor.CommandExecutor, cfg *config.Config, resolver SessionPolicyResolver) error {
+	// Register the resource serving outputs too large to return inline
+	outputs := newOutputStore(cfg)
+	registerOutputResource(mcpServer, outputs)
+
 	// Register the command execution tool
-	if err := RegisterCommandExecTool(mcpServer, cmdExecutor); err != nil {
+	budget := newSessionBudget(cfg)
+	jobs := newJobRegistry(cfg)
+	policy := newSessionPolicy(cfg, resolver)
+	if err := RegisterCommandExecTool(mcpServer, cmdExecutor, cfg, outputs, budget, jobs, policy); err != nil {
+		return err
+	}
+
+	// Register the tool running one command across several directories
+	if err := RegisterFanoutTool(mcpServer, cmdExecutor, cfg, budget, policy); err != nil {
+		return err
+	}
+
+	// Register the tool comparing two directories
+	if err := RegisterDirDiffTool(mcpServer, cmdExecutor, policy); err != nil {
+		return err
+	}
+
+	// Register the async job status tool
+	if err := RegisterJobStatusTool(mcpServer, jobs); err != nil {
+		return err
+	}
+
+	// Register the session reset tool
+	if err := RegisterResetSessionTool(mcpServer, cfg, budget); err != nil {
+		return err
+	}
+
+	// Register the precheck tool
+	if err := RegisterPrecheckTool(mcpServer, cmdExecutor, cfg, policy); err != nil {
+		return err
+	}
+
+	// Register the operator tools that change the allowlist at runtime
+	if err := RegisterRuntimePolicyTools(mcpServer, cmdExecutor, cfg, policy); err != nil {
+		return err
+	}
+
+	// Register the tools for inspecting and killing running commands
+	if err := RegisterProcessTools(mcpServer, cmdExecutor); err != nil {
+		return err
+	}
+
+	// Register the tool returning earlier results without re-running commands
+	if err := RegisterLastResultTool(mcpServer, cmdExecutor, cfg, outputs); err != nil {
 		return err
 	}
 
@@ -2,6 +2,7 @@ package server
 
 import (
 	"context"
+	"sync"
 
 	"github.com/cnosuke/mcp-command-exec/config"
 	"github.com/cnosuke/mcp-command-exec/executor"
@@ -16,8 +17,15 @@ import (
 type Server struct {
 	mcpServer   *mcpserver.MCPServer
 	cmdExecutor executor.CommandExecutor
+	cfg         *config.Config
 	name        string
 	version     string
+
+	policyResolver mcp.SessionPolicyResolver
+
+	shutdownMu    sync.Mutex
+	shutdownHooks []func() error
+	shutdownOnce  sync.Once
 }
 
 // NewServer creates a new server instance
@@ -59,18 +67,40 @@ func NewServer(cfg *config.Config, name, version string) (*Server, error) {
 	s := &Server{
 		mcpServer:   mcpServer,
 		cmdExecutor: cmdExecutor,
+		cfg:         cfg,
 		name:        name,
 		version:     version,
 	}
 
+	// Don't leave session shells running after the server exits
+	if cfg.CommandExec.PersistentShell {
+		s.AddShutdownHook(func() error {
+			cmdExecutor.CloseShells()
+			return nil
+		})
+	}
+
+	// Keep a record of the in-memory history for post-mortem analysis
+	if path := cfg.CommandExec.HistoryExportPath; path != "" {
+		s.AddShutdownHook(func() error {
+			return cmdExecutor.ExportHistory(path)
+		})
+	}
+
 	return s, nil
 }
 
 // Start starts the server
 func (s *Server) Start() error {
+	// Run operator-defined setup before any client connects
+	if err := s.runStartupCommands(); err != nil {
+		zap.S().Errorw("startup commands failed", "error", err)
+		return err
+	}
+
 	// Register tools
 	zap.S().Debugw("registering tools")
-	if err := mcp.RegisterAllTools(s.mcpServer, s.cmdExecutor); err != nil {
+	if err := mcp.RegisterAllTools(s.mcpServer, s.cmdExecutor, s.cfg, s.policyResolver); err != nil {
 		zap.S().Errorw("failed to register tools", "error", err)
 		return errors.Wrap(err, "failed to register tools")
 	}
@@ -86,3 +116,37 @@ func (s *Server) Start() error {
 	zap.S().Infow("server shutting down")
 	return nil
 }
+
+// SetSessionPolicyResolver gives each client session its own allowlist and
+// directories; sessions it resolves no policy for use the configuration.
+// It must be called before Start.
+func (s *Server) SetSessionPolicyResolver(resolver mcp.SessionPolicyResolver) {
+	s.policyResolver = resolver
+}
+
+// AddShutdownHook registers a function that flushes state when the server shuts down
+func (s *Server) AddShutdownHook(hook func() error) {
+	s.shutdownMu.Lock()
+	defer s.shutdownMu.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, hook)
+}
+
+// Shutdown runs the registered shutdown hooks once, in reverse registration order
+func (s *Server) Shutdown() error {
+	var err error
+	s.shutdownOnce.Do(func() {
+		zap.S().Infow("running shutdown hooks")
+
+		s.shutdownMu.Lock()
+		hooks := s.shutdownHooks
+		s.shutdownMu.Unlock()
+
+		for i := len(hooks) - 1; i >= 0; i-- {
+			if hookErr := hooks[i](); hookErr != nil {
+				zap.S().Errorw("shutdown hook failed", "error", hookErr)
+				err = errors.CombineErrors(err, hookErr)
+			}
+		}
+	})
+	return err
+}
@@ -1,16 +1,25 @@
 package server
 
 import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
 	"testing"
 
 	"github.com/cnosuke/mcp-command-exec/config"
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cnosuke/mcp-command-exec/types"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zaptest"
 )
 
-// TestNewCommandExecutorServer - Test initialization of CommandExecutorServer
-func TestNewCommandExecutorServer(t *testing.T) {
+// TestNewServer - Test initialization of Server
+func TestNewServer(t *testing.T) {
 	// Set up test logger
 	logger := zaptest.NewLogger(t)
 	zap.ReplaceGlobals(logger)
@@ -20,12 +29,12 @@ func TestNewCommandExecutorServer(t *testing.T) {
 	cfg.CommandExec.AllowedCommands = []string{"ls", "echo"}
 
 	// Create server
-	server, err := NewCommandExecutorServer(cfg)
+	server, err := NewServer(cfg, "test", "0.0.1")
 
 	// Assertions
 	assert.NoError(t, err)
 	assert.NotNil(t, server)
-	assert.Equal(t, []string{"ls", "echo"}, server.AllowedCommands)
+	assert.Equal(t, []string{"ls", "echo"}, server.cmdExecutor.GetAllowedCommands())
 }
 
 // TestSetupServerComponents - Test server setup logic
@@ -39,12 +48,150 @@ func TestSetupServerComponents(t *testing.T) {
 	cfg.CommandExec.AllowedCommands = []string{"ls", "echo"}
 
 	// Create and test server
-	commandExecutorServer, err := NewCommandExecutorServer(cfg)
+	server, err := NewServer(cfg, "test", "0.0.1")
 	assert.NoError(t, err)
-	assert.NotNil(t, commandExecutorServer)
+	assert.NotNil(t, server)
 
 	// Test command validation functionality
-	assert.True(t, commandExecutorServer.IsCommandAllowed("ls -la"))
-	assert.True(t, commandExecutorServer.IsCommandAllowed("echo test"))
-	assert.False(t, commandExecutorServer.IsCommandAllowed("rm -rf"))
+	assert.True(t, server.cmdExecutor.IsCommandAllowed("ls -la"))
+	assert.True(t, server.cmdExecutor.IsCommandAllowed("echo test"))
+	assert.False(t, server.cmdExecutor.IsCommandAllowed("rm -rf"))
+}
+
+// TestShutdown - Test that shutdown hooks run once in reverse order
+func TestShutdown(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	server, err := NewServer(cfg, "test", "0.0.1")
+	assert.NoError(t, err)
+
+	var calls []string
+	server.AddShutdownHook(func() error {
+		calls = append(calls, "audit")
+		return nil
+	})
+	server.AddShutdownHook(func() error {
+		calls = append(calls, "metrics")
+		return nil
+	})
+
+	assert.NoError(t, server.Shutdown())
+	assert.NoError(t, server.Shutdown())
+	assert.Equal(t, []string{"metrics", "audit"}, calls)
+}
+
+// TestShutdownExportsHistory - Test that the command history is written to history_export_path on shutdown
+func TestShutdownExportsHistory(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	dir := t.TempDir()
+	exportPath := filepath.Join(dir, "history.jsonl")
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.HistoryExportPath = exportPath
+
+	server, err := NewServer(cfg, "test", "0.0.1")
+	require.NoError(t, err)
+
+	_, err = server.cmdExecutor.Execute("echo API_TOKEN=hunter2 LANG=C", executor.Options{})
+	require.NoError(t, err)
+	_, err = server.cmdExecutor.Execute("echo second", executor.Options{})
+	require.NoError(t, err)
+
+	require.NoError(t, server.Shutdown())
+
+	data, err := os.ReadFile(exportPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	require.Len(t, lines, 2)
+
+	var first, second types.CommandResult
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &first))
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Equal(t, "echo API_TOKEN=[REDACTED] LANG=C", first.Command)
+	assert.Equal(t, "API_TOKEN=[REDACTED] LANG=C\n", first.Stdout)
+	assert.Equal(t, "second\n", second.Stdout)
+	assert.NotContains(t, string(data), "hunter2")
+
+	// Only the export itself is left in the directory
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "history.jsonl", entries[0].Name())
+}
+
+// TestRunStartupCommands - Test that startup commands run outside the allowlist
+func TestRunStartupCommands(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"ls"}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.StartupCommands = []config.StartupCommand{
+		{Command: "touch warmed", WorkingDir: dir, Required: true},
+		// Optional failures do not abort startup
+		{Command: "false"},
+	}
+
+	server, err := NewServer(cfg, "test", "0.0.1")
+	require.NoError(t, err)
+
+	require.NoError(t, server.runStartupCommands())
+	assert.FileExists(t, filepath.Join(dir, "warmed"))
+}
+
+// TestStartRequiredStartupCommandFails - Test that a failing required startup command aborts startup
+func TestStartRequiredStartupCommandFails(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	dir := t.TempDir()
+	cfg := &config.Config{}
+	cfg.CommandExec.DefaultWorkingDir = dir
+	cfg.CommandExec.StartupCommands = []config.StartupCommand{
+		{Command: "false", Required: true},
+		{Command: "touch never-run"},
+	}
+
+	server, err := NewServer(cfg, "test", "0.0.1")
+	require.NoError(t, err)
+
+	err = server.Start()
+	assert.ErrorContains(t, err, "required startup command failed: false")
+	assert.NoFileExists(t, filepath.Join(dir, "never-run"))
+}
+
+// TestShutdownClosesShells - Test that persistent shells are stopped on shutdown
+func TestShutdownClosesShells(t *testing.T) {
+	// Set up test logger
+	logger := zaptest.NewLogger(t)
+	zap.ReplaceGlobals(logger)
+
+	cfg := &config.Config{}
+	cfg.CommandExec.AllowedCommands = []string{"echo"}
+	cfg.CommandExec.DefaultWorkingDir = t.TempDir()
+	cfg.CommandExec.AllowShell = true
+	cfg.CommandExec.PersistentShell = true
+
+	server, err := NewServer(cfg, "test", "0.0.1")
+	require.NoError(t, err)
+
+	result, err := server.cmdExecutor.Execute("echo $$", executor.Options{PersistentShell: true, SessionID: "s1"})
+	require.NoError(t, err)
+	pid, err := strconv.Atoi(strings.TrimSpace(result.Stdout))
+	require.NoError(t, err)
+	require.NoError(t, syscall.Kill(pid, 0))
+
+	require.NoError(t, server.Shutdown())
+	assert.ErrorIs(t, syscall.Kill(pid, 0), syscall.ESRCH)
 }
@@ -0,0 +1,55 @@
+package server
+
+import (
+	"time"
+
+	"github.com/cnosuke/mcp-command-exec/executor"
+	"github.com/cockroachdb/errors"
+	"go.uber.org/zap"
+)
+
+// defaultStartupTimeout applies to startup commands without timeout_seconds
+const defaultStartupTimeout = 60 * time.Second
+
+// runStartupCommands runs the configured startup commands in order. They are
+// operator-defined, so they bypass the allowlist. A failing required command
+// aborts startup; other failures are only logged.
+func (s *Server) runStartupCommands() error {
+	for _, startup := range s.cfg.CommandExec.StartupCommands {
+		timeout := time.Duration(startup.TimeoutSeconds) * time.Second
+		if timeout <= 0 {
+			timeout = defaultStartupTimeout
+		}
+
+		zap.S().Infow("running startup command",
+			"command", startup.Command,
+			"working_dir", startup.WorkingDir,
+			"timeout", timeout,
+			"required", startup.Required)
+
+		result, err := s.cmdExecutor.Execute(startup.Command, executor.Options{
+			WorkingDir: startup.WorkingDir,
+			Timeout:    timeout,
+		})
+
+		zap.S().Infow("startup command finished",
+			"command", startup.Command,
+			"exit_code", result.ExitCode,
+			"stdout", result.Stdout,
+			"stderr", result.Stderr,
+			"error", result.Error)
+
+		if err == nil {
+			continue
+		}
+
+		if startup.Required {
+			return errors.Wrapf(err, "required startup command failed: %s", startup.Command)
+		}
+		zap.S().Warnw("startup command failed, continuing",
+			"command", startup.Command,
+			"error", err)
+	}
+
+	return nil
+}
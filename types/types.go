@@ -1,13 +1,69 @@
 package types
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // CommandResult - Structure for command execution results
 type CommandResult struct {
-	Command    string `json:"command"`
-	WorkingDir string `json:"working_dir"`
-	Stdout     string `json:"stdout"`
-	Stderr     string `json:"stderr"`
-	ExitCode   int    `json:"exit_code"`
-	Error      string `json:"error,omitempty"`
+	Command                 string            `json:"command"`
+	RawCommand              string            `json:"raw_command,omitempty"`
+	WorkingDir              string            `json:"working_dir"`
+	Stdout                  string            `json:"stdout"`
+	Stderr                  string            `json:"stderr"`
+	Combined                string            `json:"combined,omitempty"`
+	ExitCode                int               `json:"exit_code"`
+	Error                   string            `json:"error,omitempty"`
+	StdoutURI               string            `json:"stdout_uri,omitempty"`
+	StderrURI               string            `json:"stderr_uri,omitempty"`
+	ChangedFiles            []string          `json:"changed_files,omitempty"`
+	StdoutTruncated         bool              `json:"stdout_truncated,omitempty"`
+	StderrTruncated         bool              `json:"stderr_truncated,omitempty"`
+	MaxRSSBytes             int64             `json:"max_rss_bytes,omitempty"`
+	WorkingDirChanged       bool              `json:"working_dir_changed,omitempty"`
+	PreviousWorkingDir      string            `json:"previous_working_dir,omitempty"`
+	QueueWaitMs             int64             `json:"queue_wait_ms,omitempty"`
+	WorkingDirReset         bool              `json:"working_dir_reset,omitempty"`
+	ScratchDir              string            `json:"scratch_dir,omitempty"`
+	ExecutionID             string            `json:"execution_id,omitempty"`
+	Label                   string            `json:"label,omitempty"`
+	Success                 bool              `json:"success"`
+	Note                    string            `json:"note,omitempty"`
+	Explain                 *ExplainTrace     `json:"explain,omitempty"`
+	Entries                 []FileEntry       `json:"entries,omitempty"`
+	MemoryLimitExceeded     bool              `json:"memory_limit_exceeded,omitempty"`
+	ParsedJSON              json.RawMessage   `json:"parsed_json,omitempty"`
+	PossibleSecretsDetected bool              `json:"possible_secrets_detected,omitempty"`
+	StartFailed             bool              `json:"start_failed,omitempty"`
+	PolicyVersion           string            `json:"policy_version,omitempty"`
+	Signal                  string            `json:"signal,omitempty"`
+	TerminatedBySignal      bool              `json:"terminated_by_signal,omitempty"`
+	Timings                 *ExecutionTimings `json:"timings,omitempty"`
+}
+
+// ExplainTrace - Diagnostics on how a command was resolved and run, returned when requested
+type ExplainTrace struct {
+	BinaryPath string `json:"binary_path"`
+	Path       string `json:"path"`
+}
+
+// ExecutionTimings - Time spent in each phase of running a command, in microseconds, returned when requested
+type ExecutionTimings struct {
+	ResolveUs  int64 `json:"resolve_us"`
+	ValidateUs int64 `json:"validate_us"`
+	StartUs    int64 `json:"start_us"`
+	RunUs      int64 `json:"run_us"`
+	TotalUs    int64 `json:"total_us"`
+}
+
+// FileEntry - A directory entry listed by the built-in ls
+type FileEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Mode    string    `json:"mode"`
+	IsDir   bool      `json:"is_dir"`
+	ModTime time.Time `json:"mtime"`
 }
 
 // CommandExecutor defines the interface for command execution